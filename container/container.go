@@ -0,0 +1,255 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// Package container implements the file format shared by the saved transducers.
+//
+// A file consists of a header, a payload and a checksum:
+//
+//	magic    [4]byte  "MAST"
+//	version  uint16
+//	kind     uint8    output kind of the transducer
+//	flags    uint8
+//	count    uint16   number of sections
+//	sections [count]int64
+//	payload
+//	checksum uint32   CRC32C of all the preceding bytes
+//
+// All the integers are little endian. The lengths of the sections are counted in units defined by
+// the kind, e.g. the number of instructions of a program.
+package container
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// Version is the version of the file format.
+const Version = 1
+
+var magic = [4]byte{'M', 'A', 'S', 'T'}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrBadMagic is returned when an input is not a transducer file.
+	ErrBadMagic = errors.New("container: bad magic")
+	// ErrVersion is returned when an input has an unsupported format version.
+	ErrVersion = errors.New("container: unsupported version")
+	// ErrKind is returned when an input holds a transducer of another kind.
+	ErrKind = errors.New("container: unexpected kind")
+	// ErrChecksum is returned when an input is corrupted.
+	ErrChecksum = errors.New("container: checksum mismatch")
+)
+
+// Kind represents an output kind of a transducer.
+type Kind uint8
+
+// Kinds of transducers.
+const (
+	KindSI   Kind = iota + 1 // si, string to int
+	KindSS                   // ss, string to string
+	KindSI32                 // si32, string to int32
+	KindMAST                 // mast, string to a generic output
+)
+
+var kindName = [...]string{
+	KindSI:   "si",
+	KindSS:   "ss",
+	KindSI32: "si32",
+	KindMAST: "mast",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindName) && kindName[k] != "" {
+		return kindName[k]
+	}
+	return fmt.Sprintf("kind(%d)", k)
+}
+
+// Header represents a header of a file.
+type Header struct {
+	Kind     Kind
+	Flags    uint8
+	Sections []int64
+}
+
+// Len returns the number of bytes of the encoded header.
+func (h Header) Len() int {
+	return 10 + 8*len(h.Sections)
+}
+
+// Writer writes a file. The payload is written by Write and the checksum by Close.
+type Writer struct {
+	w   io.Writer
+	crc hash.Hash32
+	n   int64
+}
+
+// NewWriter writes the header to w and returns a writer of the payload.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	if len(h.Sections) > 0xFFFF {
+		return nil, fmt.Errorf("container: too many sections, %d", len(h.Sections))
+	}
+	cw := &Writer{w: w, crc: crc32.New(castagnoli)}
+	buf := make([]byte, h.Len())
+	copy(buf, magic[:])
+	binary.LittleEndian.PutUint16(buf[4:], Version)
+	buf[6], buf[7] = byte(h.Kind), h.Flags
+	binary.LittleEndian.PutUint16(buf[8:], uint16(len(h.Sections)))
+	for i, v := range h.Sections {
+		binary.LittleEndian.PutUint64(buf[10+8*i:], uint64(v))
+	}
+	if _, err := cw.Write(buf); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Write writes a part of the payload.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	n, err = w.w.Write(p)
+	w.crc.Write(p[:n])
+	w.n += int64(n)
+	return
+}
+
+// Close writes the checksum. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], w.crc.Sum32())
+	n, err := w.w.Write(buf[:])
+	w.n += int64(n)
+	return err
+}
+
+// Len returns the number of bytes written.
+func (w *Writer) Len() int64 {
+	return w.n
+}
+
+// Reader reads a file. The payload is read by Read and the checksum is verified by Close.
+type Reader struct {
+	Header
+	r   io.Reader
+	crc hash.Hash32
+}
+
+// NewReader reads the header from r and returns a reader of the payload.
+// It returns ErrBadMagic, ErrVersion or ErrKind if the header is not of a file of the kind.
+// The reader reads exactly the bytes of the file from r.
+func NewReader(r io.Reader, kind Kind) (*Reader, error) {
+	cr := &Reader{r: r, crc: crc32.New(castagnoli)}
+	var buf [10]byte
+	if _, err := io.ReadFull(cr, buf[:4]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadMagic
+		}
+		return nil, err
+	}
+	if [4]byte{buf[0], buf[1], buf[2], buf[3]} != magic {
+		return nil, ErrBadMagic
+	}
+	if _, err := io.ReadFull(cr, buf[4:]); err != nil {
+		return nil, unexpected(err)
+	}
+	if v := binary.LittleEndian.Uint16(buf[4:]); v != Version {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, v)
+	}
+	cr.Kind, cr.Flags = Kind(buf[6]), buf[7]
+	if cr.Kind != kind {
+		return nil, fmt.Errorf("%w: %v, expected %v", ErrKind, cr.Kind, kind)
+	}
+	cr.Sections = make([]int64, binary.LittleEndian.Uint16(buf[8:]))
+	for i := range cr.Sections {
+		if _, err := io.ReadFull(cr, buf[:8]); err != nil {
+			return nil, unexpected(err)
+		}
+		cr.Sections[i] = int64(binary.LittleEndian.Uint64(buf[:8]))
+	}
+	return cr, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Read reads a part of the payload.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.crc.Write(p[:n])
+	return
+}
+
+// maxChunk is the maximum size of a buffer allocated at once by ReadSection, so that a corrupted
+// length fails at the end of the input rather than by a huge allocation.
+const maxChunk = 1 << 20
+
+// ReadSection reads count elements of size bytes of the payload.
+func (r *Reader) ReadSection(count int64, size int) ([]byte, error) {
+	if count < 0 || size <= 0 || count > math.MaxInt/int64(size) {
+		return nil, fmt.Errorf("container: invalid section length %d", count)
+	}
+	n := int(count) * size
+	buf := make([]byte, 0, min(n, maxChunk))
+	for len(buf) < n {
+		c := min(n-len(buf), maxChunk)
+		buf = append(buf, make([]byte, c)...)
+		if _, err := io.ReadFull(r, buf[len(buf)-c:]); err != nil {
+			return nil, unexpected(err)
+		}
+	}
+	return buf, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ReadByte reads a byte of the payload.
+func (r *Reader) ReadByte() (byte, error) {
+	var buf [1]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+// VerifyChecksum returns ErrChecksum unless a whole file b ends with the checksum of the preceding
+// bytes, for a file which is not read by a Reader, e.g. a memory mapped one.
+func VerifyChecksum(b []byte) error {
+	if len(b) < 4 || crc32.Checksum(b[:len(b)-4], castagnoli) != binary.LittleEndian.Uint32(b[len(b)-4:]) {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// Close reads the checksum and returns ErrChecksum if it does not match. It does not close the
+// underlying reader.
+func (r *Reader) Close() error {
+	sum := r.crc.Sum32()
+	var buf [4]byte
+	if _, err := io.ReadFull(r.r, buf[:]); err != nil {
+		return unexpected(err)
+	}
+	if binary.LittleEndian.Uint32(buf[:]) != sum {
+		return ErrChecksum
+	}
+	return nil
+}
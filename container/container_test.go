@@ -0,0 +1,126 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package container
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func write(t *testing.T, h Header, payload string) []byte {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, h)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := io.WriteString(w, payload); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Len() != int64(b.Len()) {
+		t.Errorf("got length %v, expected %v", w.Len(), b.Len())
+	}
+	return b.Bytes()
+}
+
+func TestReadWrite01(t *testing.T) {
+	h := Header{Kind: KindSS, Flags: 3, Sections: []int64{5, 1 << 40}}
+	b := write(t, h, "hello")
+	r, err := NewReader(bytes.NewReader(append(b, "rest"...)), KindSS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(r.Header, h) {
+		t.Errorf("got %+v, expected %+v", r.Header, h)
+	}
+	payload := make([]byte, 5)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(payload) != "hello" {
+		t.Errorf("got %q, expected hello", payload)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReadErrors01(t *testing.T) {
+	b := write(t, Header{Kind: KindSI32, Sections: []int64{3}}, "abc")
+	corrupt := func(i int, v byte) []byte {
+		c := append([]byte(nil), b...)
+		c[i] = v
+		return c
+	}
+	testdata := []struct {
+		name  string
+		input []byte
+		kind  Kind
+		err   error
+	}{
+		{name: "empty", input: nil, kind: KindSI32, err: ErrBadMagic},
+		{name: "magic", input: corrupt(0, 'X'), kind: KindSI32, err: ErrBadMagic},
+		{name: "version", input: corrupt(4, 9), kind: KindSI32, err: ErrVersion},
+		{name: "kind", input: b, kind: KindSS, err: ErrKind},
+		{name: "payload", input: corrupt(len(b)-5, 'x'), kind: KindSI32, err: ErrChecksum},
+		{name: "section", input: corrupt(10, 4), kind: KindSI32, err: ErrChecksum},
+		{name: "truncated", input: b[:len(b)-2], kind: KindSI32, err: io.ErrUnexpectedEOF},
+	}
+	for _, d := range testdata {
+		r, err := NewReader(bytes.NewReader(d.input), d.kind)
+		if err == nil {
+			_, err = io.ReadFull(r, make([]byte, 3))
+		}
+		if err == nil {
+			err = r.Close()
+		}
+		if !errors.Is(err, d.err) {
+			t.Errorf("%v: got %v, expected %v", d.name, err, d.err)
+		}
+	}
+}
+
+func TestReadSection01(t *testing.T) {
+	b := write(t, Header{Kind: KindSI, Sections: []int64{1 << 40}}, "abcdef")
+	r, err := NewReader(bytes.NewReader(b), KindSI)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := r.ReadSection(3, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "abcdef" {
+		t.Errorf("got %q, expected abcdef", got)
+	}
+	if _, err := r.ReadSection(r.Sections[0], 8); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, expected %v", err, io.ErrUnexpectedEOF)
+	}
+	if _, err := r.ReadSection(1<<62, 8); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestVerifyChecksum01(t *testing.T) {
+	b := write(t, Header{Kind: KindSI32, Sections: []int64{3}}, "abc")
+	if err := VerifyChecksum(b); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, c := range [][]byte{nil, b[:3], b[:len(b)-1], append(append([]byte(nil), b[:len(b)-5]...), b[len(b)-4:]...)} {
+		if err := VerifyChecksum(c); err != ErrChecksum {
+			t.Errorf("%q: got %v, expected %v", c, err, ErrChecksum)
+		}
+	}
+}
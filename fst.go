@@ -0,0 +1,300 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ikawaha/mast/container"
+)
+
+// FST represents a compiled finite state transducer.
+// The edges and the tails of the i-th node are edges[nodes[i].edge:nodes[i+1].edge] and
+// tails[nodes[i].tail:nodes[i+1].tail], the last node is a sentinel and the initial node is 0.
+type FST[O Output[O]] struct {
+	nodes []node
+	edges []edge[O]
+	tails []O
+}
+
+type node struct {
+	edge int
+	tail int
+}
+
+type edge[O Output[O]] struct {
+	ch   byte
+	next int
+	out  O
+}
+
+type configuration[O Output[O]] struct {
+	node int // accepting node
+	hd   int // input head
+	out  O   // output on the path
+}
+
+func (m *mast[O]) compile() (t FST[O], err error) {
+	size := len(m.states)
+	addr := func(s *state[O]) int { return size - 1 - s.ID } // the initial state comes first
+	t.nodes = make([]node, 0, size+1)
+	var edges []byte
+	for i := size - 1; i >= 0; i-- {
+		s := m.states[i]
+		t.nodes = append(t.nodes, node{edge: len(t.edges), tail: len(t.tails)})
+		edges = edges[:0]
+		for ch := range s.Trans {
+			edges = append(edges, ch)
+		}
+		sort.Slice(edges, func(i, j int) bool { return edges[i] < edges[j] })
+		for _, ch := range edges {
+			next := s.Trans[ch]
+			if next.ID >= s.ID {
+				return t, fmt.Errorf("next state is not frozen: state(%v), input(%X)", s.ID, ch)
+			}
+			t.edges = append(t.edges, edge[O]{ch: ch, next: addr(next), out: s.Output[ch]})
+		}
+		t.tails = append(t.tails, s.tails()...)
+	}
+	t.nodes = append(t.nodes, node{edge: len(t.edges), tail: len(t.tails)})
+	return
+}
+
+func (t FST[O]) transition(n int, ch byte) (e edge[O], ok bool) {
+	from, to := t.nodes[n].edge, t.nodes[n+1].edge
+	i := from + sort.Search(to-from, func(i int) bool { return t.edges[from+i].ch >= ch })
+	if i < to && t.edges[i].ch == ch {
+		return t.edges[i], true
+	}
+	return
+}
+
+func (t FST[O]) isFinal(n int) bool {
+	return t.nodes[n].tail != t.nodes[n+1].tail
+}
+
+func (t FST[O]) outputs(c configuration[O]) []O {
+	tails := t.tails[t.nodes[c.node].tail:t.nodes[c.node+1].tail]
+	outs := make([]O, 0, len(tails))
+	for _, v := range tails {
+		outs = append(outs, c.out.Cat(v))
+	}
+	return outs
+}
+
+func (t FST[O]) run(input string) (snap []configuration[O], accept bool) {
+	if len(t.nodes) < 2 {
+		return
+	}
+	var out O
+	n := 0
+	for hd := 0; ; hd++ {
+		if t.isFinal(n) {
+			snap = append(snap, configuration[O]{node: n, hd: hd, out: out})
+			accept = hd == len(input)
+		}
+		if hd == len(input) {
+			return
+		}
+		e, ok := t.transition(n, input[hd])
+		if !ok {
+			return
+		}
+		out = out.Cat(e.out)
+		n = e.next
+	}
+}
+
+// Search runs a finite state transducer for a given input and returns outputs if accepted otherwise nil.
+func (t FST[O]) Search(input string) []O {
+	snap, acc := t.run(input)
+	if !acc {
+		return nil
+	}
+	return t.outputs(snap[len(snap)-1])
+}
+
+// PrefixSearch returns the longest commom prefix keyword and it's length in given input
+// if detected otherwise -1, nil.
+func (t FST[O]) PrefixSearch(input string) (length int, output []O) {
+	snap, _ := t.run(input)
+	if len(snap) == 0 {
+		return -1, nil
+	}
+	c := snap[len(snap)-1]
+	return c.hd, t.outputs(c)
+}
+
+// CommonPrefixSearch finds keywords sharing common prefix in given input
+// and returns it's lengths and outputs. Returns nil, nil if there does not common prefix keywords.
+func (t FST[O]) CommonPrefixSearch(input string) (lens []int, outputs [][]O) {
+	snap, _ := t.run(input)
+	for _, c := range snap {
+		lens = append(lens, c.hd)
+		outputs = append(outputs, t.outputs(c))
+	}
+	return
+}
+
+// typeName returns the name of an output type which is saved with a transducer.
+func typeName[O Output[O]]() string {
+	var zero O
+	return fmt.Sprintf("%T", zero)
+}
+
+// WriteTo saves a finite state transducer. The payload consists of the name of the output type,
+// the numbers of the edges and the tails of the nodes, the edges and the tails, the integers are
+// written as uvarints and the outputs by AppendBinary.
+func (t FST[O]) WriteTo(w io.Writer) (n int64, err error) {
+	name := typeName[O]()
+	b := append(appendUvarint(nil, uint64(len(name))), name...)
+	for i := 0; i+1 < len(t.nodes); i++ {
+		b = appendUvarint(b, uint64(t.nodes[i+1].edge-t.nodes[i].edge))
+		b = appendUvarint(b, uint64(t.nodes[i+1].tail-t.nodes[i].tail))
+	}
+	for _, e := range t.edges {
+		b = appendUvarint(append(b, e.ch), uint64(e.next))
+		b = e.out.AppendBinary(b)
+	}
+	for _, v := range t.tails {
+		b = v.AppendBinary(b)
+	}
+	cw, err := container.NewWriter(w, container.Header{
+		Kind:     container.KindMAST,
+		Sections: []int64{int64(len(t.nodes)), int64(len(t.edges)), int64(len(t.tails)), int64(len(b))},
+	})
+	if err != nil {
+		return
+	}
+	defer func() { n = cw.Len() }()
+	if _, err = cw.Write(b); err != nil {
+		return
+	}
+	err = cw.Close()
+	return
+}
+
+// Read loads a finite state transducer saved by WriteTo. It returns an error if the transducer was
+// saved with another output type or if it is corrupted.
+func Read[O Output[O]](r io.Reader) (t FST[O], err error) {
+	rd, err := container.NewReader(bufio.NewReader(r), container.KindMAST)
+	if err != nil {
+		return
+	}
+	if len(rd.Sections) != 4 {
+		return t, fmt.Errorf("invalid format: sections %v", rd.Sections)
+	}
+	for _, v := range rd.Sections {
+		if v < 0 || v > rd.Sections[3] {
+			return t, fmt.Errorf("invalid format: sections %v", rd.Sections)
+		}
+	}
+	b, err := rd.ReadSection(rd.Sections[3], 1)
+	if err != nil {
+		return
+	}
+	if err = rd.Close(); err != nil {
+		return
+	}
+	d := decoder{b: b, max: len(b)}
+	if name := string(d.bytes()); d.err == nil && name != typeName[O]() {
+		return t, fmt.Errorf("invalid format: output type %s, expected %s", name, typeName[O]())
+	}
+	var nodes []node
+	if size := int(rd.Sections[0]); size > 0 {
+		nodes = make([]node, 1, size)
+		for i := 1; i < size && d.err == nil; i++ {
+			p := nodes[i-1]
+			nodes = append(nodes, node{edge: p.edge + d.int(), tail: p.tail + d.int()})
+		}
+	}
+	edges := make([]edge[O], rd.Sections[1])
+	for i := range edges {
+		edges[i] = edge[O]{ch: d.byte(), next: d.int(), out: decode[O](&d)}
+	}
+	tails := make([]O, rd.Sections[2])
+	for i := range tails {
+		tails[i] = decode[O](&d)
+	}
+	if d.err == nil && len(d.b) != 0 {
+		d.err = fmt.Errorf("%d trailing bytes", len(d.b))
+	}
+	if d.err != nil {
+		return t, fmt.Errorf("invalid format: %v", d.err)
+	}
+	u := FST[O]{nodes: nodes, edges: edges, tails: tails}
+	if err = u.Verify(); err != nil {
+		return
+	}
+	return u, nil
+}
+
+// decoder decodes a payload, the first error is kept and the following reads return zero values.
+// The integers are not greater than the length of the payload.
+type decoder struct {
+	b   []byte
+	max int
+	err error
+}
+
+func (d *decoder) fail(what string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("invalid %s", what)
+	}
+	d.b = nil
+}
+
+func (d *decoder) byte() byte {
+	if len(d.b) == 0 {
+		d.fail("byte")
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *decoder) int() int {
+	x, n := binary.Uvarint(d.b)
+	if n <= 0 || x > uint64(d.max) {
+		d.fail("integer")
+		return 0
+	}
+	d.b = d.b[n:]
+	return int(x)
+}
+
+func (d *decoder) bytes() []byte {
+	l := d.int()
+	if l > len(d.b) {
+		d.fail("length")
+		return nil
+	}
+	b := d.b[:l]
+	d.b = d.b[l:]
+	return b
+}
+
+func decode[O Output[O]](d *decoder) (o O) {
+	if d.err != nil {
+		return
+	}
+	o, n := o.Decode(d.b)
+	if n <= 0 || n > len(d.b) {
+		d.fail("output")
+		return
+	}
+	d.b = d.b[n:]
+	return
+}
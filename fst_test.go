@@ -0,0 +1,363 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestFSTSearch01(t *testing.T) {
+	inp := PairSlice[String]{
+		{"こんにちは", "hello"},
+		{"世界", "world"},
+		{"すもももももも", "peach"},
+		{"すもも", "peach"},
+		{"すもも", "もも"},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crs := []struct {
+		in  string
+		out []String
+	}{
+		{"すもも", []String{"peach", "もも"}},
+		{"こんにちわ", nil},
+		{"こんにちは", []String{"hello"}},
+		{"世界", []String{"world"}},
+		{"すもももももも", []String{"peach"}},
+		{"すも", nil},
+		{"すもう", nil},
+		{"", nil},
+	}
+	for _, cr := range crs {
+		if outs := fst.Search(cr.in); !reflect.DeepEqual(outs, cr.out) {
+			t.Errorf("input:%v, got %v, expected %v", cr.in, outs, cr.out)
+		}
+	}
+}
+
+func TestFSTSearch02(t *testing.T) {
+	fst, err := Build(PairSlice[Int64]{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outs := fst.Search(""); outs != nil {
+		t.Errorf("got %v, expected nil", outs)
+	}
+	if pos, outs := fst.PrefixSearch("abc"); pos != -1 || outs != nil {
+		t.Errorf("got %v %v, expected -1 nil", pos, outs)
+	}
+}
+
+func TestFSTSearch03(t *testing.T) {
+	inp := PairSlice[String]{
+		{"a", "xb"},
+		{"b", "y"},
+		{"a", "xa"},
+		{"b", "yy"},
+		{"a", "xc"},
+		{"b", ""},
+		{"a", "xa"},
+		{"c", "y"},
+		{"c", "x"},
+		{"ab", "xy"},
+		{"ab", "xx"},
+	}
+	crs := []struct {
+		order Order
+		outs  map[string][]String
+	}{
+		{Sorted, map[string][]String{"a": {"xa", "xb", "xc"}, "b": {"", "y", "yy"}, "c": {"x", "y"}, "ab": {"xx", "xy"}}},
+		{InsertionOrder, map[string][]String{"a": {"xb", "xa", "xc"}, "b": {"y", "yy", ""}, "c": {"y", "x"}, "ab": {"xy", "xx"}}},
+	}
+	for _, cr := range crs {
+		for i := 0; i < 10; i++ {
+			fst, err := BuildWithOrder(append(PairSlice[String](nil), inp...), cr.order)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for in, exp := range cr.outs {
+				if outs := fst.Search(in); !reflect.DeepEqual(outs, exp) {
+					t.Errorf("order %v, input: %v, got %q, expected %q", cr.order, in, outs, exp)
+				}
+			}
+		}
+	}
+}
+
+func TestFSTSearch04(t *testing.T) {
+	extremes := []Int64{math.MinInt64, math.MinInt64 + 1, -3, 0, 2, math.MaxInt64 - 1, math.MaxInt64}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		var inp PairSlice[Int64]
+		exp := map[string][]Int64{}
+		for j := 0; j < 20; j++ {
+			b := make([]byte, r.Intn(3)+1)
+			for k := range b {
+				b[k] = byte('a' + r.Intn(3))
+			}
+			out := extremes[r.Intn(len(extremes))]
+			inp = append(inp, Pair[Int64]{In: string(b), Out: out})
+		}
+		for _, p := range inp {
+			exp[p.In] = append(exp[p.In], p.Out)
+		}
+		fst := mustBuild(t, append(PairSlice[Int64](nil), inp...))
+		for in, outs := range exp {
+			sort.Slice(outs, func(i, j int) bool { return outs[i] < outs[j] })
+			var uniq []Int64
+			for k, v := range outs {
+				if k == 0 || v != outs[k-1] {
+					uniq = append(uniq, v)
+				}
+			}
+			if got := fst.Search(in); !reflect.DeepEqual(got, uniq) {
+				t.Fatalf("input:%v, pairs:%v, got %v, expected %v", in, inp, got, uniq)
+			}
+		}
+	}
+}
+
+func TestFSTPrefixSearch01(t *testing.T) {
+	inp := PairSlice[Int64]{
+		{"こんにちは", 111},
+		{"世界", 222},
+		{"すもももももも", 333},
+		{"すもも", 333},
+		{"すもも", 444},
+		{"", -1},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crs := []struct {
+		in   string
+		lens []int
+		outs [][]Int64
+	}{
+		{"すもも", []int{0, 9}, [][]Int64{{-1}, {333, 444}}},
+		{"こんにちわ", []int{0}, [][]Int64{{-1}}},
+		{"すもももももももものうち", []int{0, 9, 21}, [][]Int64{{-1}, {333, 444}, {333}}},
+	}
+	for _, cr := range crs {
+		lens, outs := fst.CommonPrefixSearch(cr.in)
+		if !reflect.DeepEqual(lens, cr.lens) || !reflect.DeepEqual(outs, cr.outs) {
+			t.Errorf("input:%v, got %v %v, expected %v %v", cr.in, lens, outs, cr.lens, cr.outs)
+		}
+		last := len(cr.lens) - 1
+		pos, out := fst.PrefixSearch(cr.in)
+		if pos != cr.lens[last] || !reflect.DeepEqual(out, cr.outs[last]) {
+			t.Errorf("input:%v, got %v %v, expected %v %v", cr.in, pos, out, cr.lens[last], cr.outs[last])
+		}
+	}
+}
+
+func TestFSTSearchRandom01(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 500; n++ {
+		var inp PairSlice[Int64]
+		exp := map[string][]Int64{}
+		for i := r.Intn(50); i > 0; i-- {
+			k := make([]byte, r.Intn(5))
+			for j := range k {
+				k[j] = byte('a' + r.Intn(3))
+			}
+			v := Int64(r.Intn(10) - 3)
+			inp = append(inp, Pair[Int64]{In: string(k), Out: v})
+			exp[string(k)] = append(exp[string(k)], v)
+		}
+		fst, err := Build(inp)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for k, v := range exp {
+			sort.Slice(v, func(i, j int) bool { return v[i] < v[j] })
+			var uniq []Int64
+			for i := range v {
+				if i == 0 || v[i] != v[i-1] {
+					uniq = append(uniq, v[i])
+				}
+			}
+			if outs := fst.Search(k); !reflect.DeepEqual(outs, uniq) {
+				t.Fatalf("input:%q, got %v, expected %v", k, outs, uniq)
+			}
+		}
+	}
+}
+
+func TestFSTSearchRandom02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 500; n++ {
+		var inp PairSlice[String]
+		exp := map[string]map[String]bool{}
+		for i := r.Intn(50); i > 0; i-- {
+			k := make([]byte, r.Intn(5))
+			for j := range k {
+				k[j] = byte('a' + r.Intn(3))
+			}
+			v := String(strconv.Itoa(r.Intn(30)))
+			inp = append(inp, Pair[String]{In: string(k), Out: v})
+			if exp[string(k)] == nil {
+				exp[string(k)] = map[String]bool{}
+			}
+			exp[string(k)][v] = true
+		}
+		fst, err := Build(inp)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for k, v := range exp {
+			outs := fst.Search(k)
+			if len(outs) != len(v) {
+				t.Fatalf("input:%q, got %v, expected %v", k, outs, v)
+			}
+			for _, o := range outs {
+				if !v[o] {
+					t.Fatalf("input:%q, got %v, expected %v", k, outs, v)
+				}
+			}
+		}
+	}
+}
+
+func TestFSTBytes01(t *testing.T) {
+	inp := PairSlice[Bytes]{
+		{"apr", Bytes{0, 3, 0}},
+		{"aug", Bytes{0, 3, 1}},
+		{"aug", Bytes{0, 3, 1}},
+		{"dec", Bytes{}},
+		{"feb", Bytes{0, 2}},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crs := []struct {
+		in  string
+		out []Bytes
+	}{
+		{"apr", []Bytes{{0, 3, 0}}},
+		{"aug", []Bytes{{0, 3, 1}}},
+		{"dec", []Bytes{nil}},
+		{"feb", []Bytes{{0, 2}}},
+		{"au", nil},
+	}
+	for _, cr := range crs {
+		outs := fst.Search(cr.in)
+		if len(outs) != len(cr.out) {
+			t.Fatalf("input:%v, got %v, expected %v", cr.in, outs, cr.out)
+		}
+		for i := range outs {
+			if !bytes.Equal(outs[i], cr.out[i]) {
+				t.Errorf("input:%v, got %v, expected %v", cr.in, outs, cr.out)
+			}
+		}
+	}
+}
+
+func TestFSTWriteTo01(t *testing.T) {
+	inp := PairSlice[String]{
+		{"feb", "28"},
+		{"feb", "29"},
+		{"jan", "31"},
+		{"jun", "30"},
+		{"", "0"},
+	}
+	org, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	n, err := org.WriteTo(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("write len: got %v, expected %v", n, b.Len())
+	}
+	rst, err := Read[String](bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(org, rst) {
+		t.Errorf("got %+v, expected %+v", rst, org)
+	}
+	for _, p := range inp {
+		if outs := rst.Search(p.In); !reflect.DeepEqual(outs, org.Search(p.In)) {
+			t.Errorf("input:%v, got %v, expected %v", p.In, outs, org.Search(p.In))
+		}
+	}
+	if _, err := Read[Int64](bytes.NewReader(b.Bytes())); err == nil {
+		t.Errorf("expected error for another output type")
+	}
+	if _, err := Read[String](bytes.NewReader(b.Bytes()[:b.Len()-1])); err == nil {
+		t.Errorf("expected error for a truncated transducer")
+	}
+}
+
+func TestFSTWriteTo02(t *testing.T) {
+	for _, org := range []FST[Bytes]{{}, mustBuild(t, PairSlice[Bytes]{{"a", Bytes("x")}, {"ab", nil}})} {
+		var b bytes.Buffer
+		if _, err := org.WriteTo(&b); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rst, err := Read[Bytes](&b)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rst.nodes) != len(org.nodes) || len(rst.edges) != len(org.edges) || len(rst.tails) != len(org.tails) {
+			t.Errorf("got %+v, expected %+v", rst, org)
+		}
+		if got, exp := rst.Search("a"), org.Search("a"); len(got) != len(exp) || len(got) > 0 && !bytes.Equal(got[0], exp[0]) {
+			t.Errorf("got %v, expected %v", got, exp)
+		}
+	}
+}
+
+func TestFSTVerify01(t *testing.T) {
+	org, err := Build(PairSlice[Int64]{{"ab", 1}, {"ac", 2}, {"b", 3}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := org.Verify(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loop := org
+	loop.edges = append([]edge[Int64](nil), org.edges...)
+	loop.edges[0].next = 0
+	unsorted := org
+	unsorted.edges = append([]edge[Int64](nil), org.edges...)
+	unsorted.edges[0].ch, unsorted.edges[1].ch = unsorted.edges[1].ch, unsorted.edges[0].ch
+	short := org
+	short.tails = org.tails[:len(org.tails)-1]
+	for _, c := range []FST[Int64]{loop, unsorted, short, {edges: org.edges}} {
+		if err := c.Verify(); err == nil {
+			t.Errorf("expected error: %+v", c)
+		}
+	}
+}
+
+func mustBuild[O Output[O]](t *testing.T, inp PairSlice[O]) FST[O] {
+	t.Helper()
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return fst
+}
@@ -0,0 +1,182 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// Package aho implements the Aho-Corasick automaton which the transducer packages share to find
+// their keys occurring anywhere in a text.
+package aho
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Boundary restricts the positions where matches may start and end.
+type Boundary int
+
+const (
+	// AnyBoundary allows matches at any byte offset.
+	AnyBoundary Boundary = iota
+	// RuneBoundary allows matches which start and end at UTF-8 rune boundaries.
+	RuneBoundary
+	// WordBoundary allows matches which start and end at rune boundaries that are not
+	// between two word characters (letters, digits or '_').
+	WordBoundary
+)
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Allows reports whether a match may start or end at the offset i of the text.
+func (b Boundary) Allows(text string, i int) bool {
+	switch b {
+	case RuneBoundary:
+		return i == len(text) || utf8.RuneStart(text[i])
+	case WordBoundary:
+		if i > 0 && i < len(text) && !utf8.RuneStart(text[i]) {
+			return false
+		}
+		if i == 0 || i == len(text) {
+			return true
+		}
+		prev, _ := utf8.DecodeLastRuneInString(text[:i])
+		next, _ := utf8.DecodeRuneInString(text[i:])
+		return !isWordRune(prev) || !isWordRune(next)
+	}
+	return true
+}
+
+// Automaton is an Aho-Corasick automaton of a set of keys. It is a trie of the keys with failure
+// links, which lead a node to the node of its longest proper suffix in the trie, and output links,
+// which lead a node to the nearest node on the failure chain which ends a key. A text is scanned
+// in one pass by following the edges of the trie and falling back on the failure links, so it
+// takes time proportional to the length of the text and the number of matches.
+type Automaton struct {
+	nodes []node
+	edges []edge // the edges of the i-th node are edges[nodes[i].edge:nodes[i+1].edge]
+}
+
+type node struct {
+	edge  int
+	fail  int // node of the longest proper suffix, the root is 0
+	dict  int // nearest node on the failure chain which ends a key, -1 if none
+	key   int // index of the key ending at the node, -1 if none
+	depth int
+}
+
+type edge struct {
+	ch   byte
+	next int
+}
+
+// New constructs an automaton of the keys, a match reports the index of its key in keys.
+// Empty keys are ignored, and a duplicated key is reported by its first index.
+func New(keys []string) *Automaton {
+	kids := [][]edge{nil}
+	a := &Automaton{nodes: []node{{dict: -1, key: -1}}}
+	for i, k := range keys {
+		n := 0
+		for j := 0; j < len(k); j++ {
+			next := -1
+			for _, e := range kids[n] {
+				if e.ch == k[j] {
+					next = e.next
+					break
+				}
+			}
+			if next < 0 {
+				next = len(a.nodes)
+				a.nodes = append(a.nodes, node{dict: -1, key: -1, depth: j + 1})
+				kids = append(kids, nil)
+				kids[n] = append(kids[n], edge{ch: k[j], next: next})
+			}
+			n = next
+		}
+		if n != 0 && a.nodes[n].key < 0 {
+			a.nodes[n].key = i
+		}
+	}
+	for i, es := range kids {
+		sort.Slice(es, func(i, j int) bool { return es[i].ch < es[j].ch })
+		a.nodes[i].edge = len(a.edges)
+		a.edges = append(a.edges, es...)
+	}
+	a.nodes = append(a.nodes, node{edge: len(a.edges)}) // sentinel
+
+	// the failure links of a node are given by the nodes of shallower depth, so they are
+	// computed in breadth first order.
+	queue := []int{0}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range a.edges[a.nodes[n].edge:a.nodes[n+1].edge] {
+			if n != 0 {
+				a.nodes[e.next].fail = a.goTo(a.nodes[n].fail, e.ch)
+			}
+			if f := a.nodes[e.next].fail; a.nodes[f].key >= 0 {
+				a.nodes[e.next].dict = f
+			} else {
+				a.nodes[e.next].dict = a.nodes[f].dict
+			}
+			queue = append(queue, e.next)
+		}
+	}
+	return a
+}
+
+// child returns the node reached from the node n by the edge labeled ch.
+func (a *Automaton) child(n int, ch byte) (next int, ok bool) {
+	from, to := a.nodes[n].edge, a.nodes[n+1].edge
+	i := from + sort.Search(to-from, func(i int) bool { return a.edges[from+i].ch >= ch })
+	if i < to && a.edges[i].ch == ch {
+		return a.edges[i].next, true
+	}
+	return 0, false
+}
+
+// goTo returns the node of the longest suffix of the string of the node n followed by ch.
+func (a *Automaton) goTo(n int, ch byte) int {
+	for {
+		if next, ok := a.child(n, ch); ok {
+			return next
+		}
+		if n == 0 {
+			return 0
+		}
+		n = a.nodes[n].fail
+	}
+}
+
+// Scan finds the keys occurring in the text and calls fn for each of them with its offsets and
+// its index, it stops when fn returns false. The matches are reported in order of their end
+// offsets, and the longer ones first for the same end offset.
+func (a *Automaton) Scan(text string, b Boundary, fn func(start, end, key int) bool) {
+	n := 0
+	for i := 0; i < len(text); i++ {
+		n = a.goTo(n, text[i])
+		end := i + 1
+		if !b.Allows(text, end) {
+			continue
+		}
+		m := n
+		if a.nodes[m].key < 0 {
+			m = a.nodes[m].dict
+		}
+		for ; m >= 0; m = a.nodes[m].dict {
+			start := end - a.nodes[m].depth
+			if !b.Allows(text, start) {
+				continue
+			}
+			if !fn(start, end, a.nodes[m].key) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package aho
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type match struct {
+	start, end, key int
+}
+
+func scan(a *Automaton, text string, b Boundary) []match {
+	var ms []match
+	a.Scan(text, b, func(start, end, key int) bool {
+		ms = append(ms, match{start, end, key})
+		return true
+	})
+	return ms
+}
+
+func TestAutomatonScan01(t *testing.T) {
+	a := New([]string{"he", "she", "his", "hers", "", "she"})
+	got := scan(a, "ushers", AnyBoundary)
+	exp := []match{{1, 4, 1}, {2, 4, 0}, {2, 6, 3}}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+	if got := scan(New(nil), "ushers", AnyBoundary); got != nil {
+		t.Errorf("got %v, expected none", got)
+	}
+
+	var n int
+	a.Scan("ushers", AnyBoundary, func(start, end, key int) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("got %v calls, expected 1", n)
+	}
+}
+
+func TestAutomatonScan02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = "abc"[r.Intn(3)]
+		}
+		return string(b)
+	}
+	for i := 0; i < 100; i++ {
+		keys := make([]string, r.Intn(20))
+		for j := range keys {
+			keys[j] = random(r.Intn(5) + 1)
+		}
+		text := random(r.Intn(100))
+		var exp []match
+		for end := 1; end <= len(text); end++ {
+			for start := 0; start < end; start++ {
+				for k, key := range keys {
+					if text[start:end] == key {
+						exp = append(exp, match{start, end, k})
+						break
+					}
+				}
+			}
+		}
+		if got := scan(New(keys), text, AnyBoundary); !reflect.DeepEqual(got, exp) {
+			t.Fatalf("keys:%v, text:%q, got %v, expected %v", keys, text, got, exp)
+		}
+	}
+}
+
+func TestBoundaryAllows01(t *testing.T) {
+	text := "cats,cat あ"
+	testdata := []struct {
+		b   Boundary
+		exp string
+	}{
+		{b: AnyBoundary, exp: "yyyyyyyyyyyyy"},
+		{b: RuneBoundary, exp: "yyyyyyyyyynny"},
+		{b: WordBoundary, exp: "ynnnyynnyynny"},
+	}
+	for _, d := range testdata {
+		var got strings.Builder
+		for i := 0; i <= len(text); i++ {
+			if d.b.Allows(text, i) {
+				got.WriteByte('y')
+			} else {
+				got.WriteByte('n')
+			}
+		}
+		if got.String() != d.exp {
+			t.Errorf("boundary %v: got %v, expected %v", d.b, got.String(), d.exp)
+		}
+	}
+}
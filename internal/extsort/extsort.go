@@ -0,0 +1,250 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// Package extsort implements the external merge sort of tab separated pairs and the temporary
+// files, which the transducer packages share to build transducers larger than the memory.
+package extsort
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxLineSize is the maximum length of a line of the input.
+const maxLineSize = 1 << 20
+
+// Split splits a line of tab separated input and output at the last tab.
+func Split(line string) (in, out string, err error) {
+	i := strings.LastIndexByte(line, '\t')
+	if i < 0 {
+		return "", "", fmt.Errorf("missing tab separator")
+	}
+	return line[:i], line[i+1:], nil
+}
+
+// Codec tells how records are parsed from the lines of the input, ordered, and stored in the
+// temporary files.
+type Codec[T any] struct {
+	Parse  func(line string) (T, error)
+	Less   func(a, b T) bool
+	Append func(b []byte, x T) []byte
+	Read   func(r *bufio.Reader) (T, error)
+}
+
+// Sort parses the non-empty lines of r into records, sorts them by external merge sort and calls
+// fn for each of them in order, the records which are not less than each other are kept in the
+// order of the input. At most size records are sorted in memory at once, the sorted chunks are
+// written to temporary files in dir which are removed before it returns.
+func Sort[T any](r io.Reader, dir string, size int, c Codec[T], fn func(x T) error) error {
+	chunks, err := sortChunks(r, dir, size, c)
+	defer func() {
+		for _, f := range chunks {
+			f.Remove()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	return merge(chunks, c, fn)
+}
+
+func sortChunks[T any](r io.Reader, dir string, size int, c Codec[T]) (chunks []*File, err error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+	xs := make([]T, 0, size)
+	flush := func() error {
+		sort.SliceStable(xs, func(i, j int) bool { return c.Less(xs[i], xs[j]) })
+		f, err := Create(dir, "mast-chunk-")
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, f)
+		var b []byte
+		for _, x := range xs {
+			b = c.Append(b[:0], x)
+			if _, err := f.Write(b); err != nil {
+				return err
+			}
+		}
+		xs = xs[:0]
+		return f.Flush()
+	}
+	for line := 1; s.Scan(); line++ {
+		if s.Text() == "" {
+			continue
+		}
+		x, err := c.Parse(s.Text())
+		if err != nil {
+			return chunks, fmt.Errorf("line %d: %v", line, err)
+		}
+		if xs = append(xs, x); len(xs) < size {
+			continue
+		}
+		if err := flush(); err != nil {
+			return chunks, err
+		}
+	}
+	if err = s.Err(); err != nil {
+		return
+	}
+	if len(xs) > 0 {
+		err = flush()
+	}
+	return
+}
+
+type cursor[T any] struct {
+	r *bufio.Reader
+	x T
+	i int // index of the chunk, which breaks ties
+}
+
+type cursorHeap[T any] struct {
+	cs   []*cursor[T]
+	less func(a, b T) bool
+}
+
+func (h cursorHeap[T]) Len() int { return len(h.cs) }
+func (h cursorHeap[T]) Less(i, j int) bool {
+	a, b := h.cs[i], h.cs[j]
+	if h.less(a.x, b.x) {
+		return true
+	}
+	return !h.less(b.x, a.x) && a.i < b.i
+}
+func (h cursorHeap[T]) Swap(i, j int)       { h.cs[i], h.cs[j] = h.cs[j], h.cs[i] }
+func (h *cursorHeap[T]) Push(x interface{}) { h.cs = append(h.cs, x.(*cursor[T])) }
+func (h *cursorHeap[T]) Pop() interface{} {
+	x := h.cs[len(h.cs)-1]
+	h.cs = h.cs[:len(h.cs)-1]
+	return x
+}
+
+func merge[T any](chunks []*File, c Codec[T], fn func(x T) error) (err error) {
+	h := &cursorHeap[T]{less: c.Less}
+	for i, f := range chunks {
+		r, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		cur := &cursor[T]{r: r, i: i}
+		if cur.x, err = c.Read(r); err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return err
+		}
+		h.cs = append(h.cs, cur)
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		cur := h.cs[0]
+		if err := fn(cur.x); err != nil {
+			return err
+		}
+		if cur.x, err = c.Read(cur.r); err != nil {
+			if err != io.EOF {
+				return err
+			}
+			heap.Pop(h)
+			continue
+		}
+		heap.Fix(h, 0)
+	}
+	return nil
+}
+
+// File is a temporary file written through a buffer.
+type File struct {
+	f *os.File
+	w *bufio.Writer
+	n int64
+}
+
+// Create creates a new temporary file in dir, see os.CreateTemp for the pattern.
+func Create(dir, pattern string) (*File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Write appends b to the file.
+func (f *File) Write(b []byte) (int, error) {
+	n, err := f.w.Write(b)
+	f.n += int64(n)
+	return n, err
+}
+
+// Len returns the number of bytes written.
+func (f *File) Len() int64 {
+	return f.n
+}
+
+// Flush writes out the buffered bytes.
+func (f *File) Flush() error {
+	return f.w.Flush()
+}
+
+// Reader flushes the file and returns a reader of the contents from the beginning.
+func (f *File) Reader() (*bufio.Reader, error) {
+	if err := f.w.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := f.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(f.f), nil
+}
+
+// ReadAt reads the contents at the offset off, the file must be flushed in advance.
+func (f *File) ReadAt(b []byte, off int64) (int, error) {
+	return f.f.ReadAt(b, off)
+}
+
+// WriteReversedTo writes the contents to w in the reverse order of units of size bytes, e.g. the
+// instructions of a program compiled backwards.
+func (f *File) WriteReversedTo(w io.Writer, size int) (n int64, err error) {
+	if err = f.w.Flush(); err != nil {
+		return
+	}
+	const bufSize = 1 << 16
+	buf := make([]byte, bufSize-bufSize%size)
+	rev := make([]byte, len(buf))
+	for off := f.n; off > 0; {
+		l := int64(len(buf))
+		if l > off {
+			l = off
+		}
+		off -= l
+		if _, err = f.f.ReadAt(buf[:l], off); err != nil {
+			return
+		}
+		for i := int64(0); i < l; i += int64(size) {
+			copy(rev[l-i-int64(size):l-i], buf[i:i+int64(size)])
+		}
+		m, e := w.Write(rev[:l])
+		if n += int64(m); e != nil {
+			return n, e
+		}
+	}
+	return
+}
+
+// Remove closes and removes the file.
+func (f *File) Remove() {
+	f.f.Close()
+	os.Remove(f.f.Name())
+}
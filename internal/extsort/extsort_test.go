@@ -0,0 +1,126 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package extsort
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type pair struct {
+	in  string
+	out int
+}
+
+var codec = Codec[pair]{
+	Parse: func(line string) (p pair, err error) {
+		in, out, err := Split(line)
+		if err != nil {
+			return p, err
+		}
+		v, err := strconv.Atoi(out)
+		return pair{in, v}, err
+	},
+	Less: func(a, b pair) bool { return a.in < b.in },
+	Append: func(b []byte, p pair) []byte {
+		var tmp [binary.MaxVarintLen64]byte
+		b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(p.in)))]...)
+		b = append(b, p.in...)
+		return append(b, tmp[:binary.PutUvarint(tmp[:], uint64(p.out))]...)
+	},
+	Read: func(r *bufio.Reader) (p pair, err error) {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return
+		}
+		in := make([]byte, size)
+		if _, err = io.ReadFull(r, in); err != nil {
+			return
+		}
+		out, err := binary.ReadUvarint(r)
+		return pair{string(in), int(out)}, err
+	},
+}
+
+func TestSort01(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var src bytes.Buffer
+	var exp []pair
+	for i := 0; i < 1000; i++ {
+		p := pair{string(rune('a' + r.Intn(26))), i}
+		exp = append(exp, p)
+		fmt.Fprintf(&src, "%s\t%d\n\n", p.in, p.out)
+	}
+	sort.SliceStable(exp, func(i, j int) bool { return exp[i].in < exp[j].in })
+	for _, size := range []int{1, 7, 1000, 2000} {
+		dir := t.TempDir()
+		var got []pair
+		if err := Sort(bytes.NewReader(src.Bytes()), dir, size, codec, func(p pair) error {
+			got = append(got, p)
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("chunk size:%v, got %v, expected %v", size, got, exp)
+		}
+		if fs, _ := os.ReadDir(dir); len(fs) != 0 {
+			t.Errorf("temporary files are left: %v", fs)
+		}
+	}
+}
+
+func TestSort02(t *testing.T) {
+	for _, src := range []string{"a\t1\nb 2\n", "a\tX\n"} {
+		err := Sort(strings.NewReader(src), t.TempDir(), 1, codec, func(pair) error { return nil })
+		if err == nil || !strings.HasPrefix(err.Error(), "line ") {
+			t.Errorf("input:%q, got %v, expected a line error", src, err)
+		}
+	}
+	stop := fmt.Errorf("stop")
+	if err := Sort(strings.NewReader("a\t1\n"), t.TempDir(), 1, codec, func(pair) error { return stop }); err != stop {
+		t.Errorf("got %v, expected %v", err, stop)
+	}
+}
+
+func TestFileWriteReversedTo01(t *testing.T) {
+	f, err := Create(t.TempDir(), "test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Remove()
+	const size = 50000
+	exp := make([]byte, 2*size)
+	for i := 0; i < size; i++ {
+		b := []byte{byte(i), byte(i >> 8)}
+		if _, err := f.Write(b); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		copy(exp[2*(size-1-i):], b)
+	}
+	var got bytes.Buffer
+	n, err := f.WriteReversedTo(&got, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != f.Len() || !bytes.Equal(got.Bytes(), exp) {
+		t.Errorf("got %v bytes, expected %v", n, f.Len())
+	}
+}
@@ -0,0 +1,250 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// Package pattern implements the matching of the keys of transducers by regular expressions and
+// glob patterns, which the transducer packages share.
+package pattern
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxStates is the number of the states of a DFA kept in the cache, the cache is cleared when it
+// is exceeded so that a pattern whose DFA is exponential does not exhaust the memory. Clearing
+// starts a new generation of states, the states of older generations are not linked from the
+// DFA nor from the new states, and they drop their transitions when a cursor steps from them.
+const maxStates = 10000
+
+// DFA is a deterministic finite automaton of a regular expression which is constructed lazily by
+// the subset construction from the program compiled by the regexp/syntax package. A state of the
+// DFA is a set of the instructions of the program and the class of the previously read rune, which
+// decides the empty width assertions. The states and their transitions on runes are built when
+// they are first visited, so a search builds only the part of the DFA which the keys reach.
+type DFA struct {
+	prog   *syntax.Prog
+	states map[string]*state
+	start  *state
+	gen    int // generation of the states in the cache
+}
+
+type state struct {
+	set   []uint32 // instructions without pending empty transitions except empty width assertions
+	prev  rune     // a representative of the class of the previously read rune, -1 at the beginning
+	next  map[rune]*state
+	match bool // accepts at the end of the input
+	gen   int
+}
+
+// Compile compiles a regular expression of the syntax accepted by the regexp package.
+func Compile(pattern string) (*DFA, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	d := &DFA{prog: prog, states: make(map[string]*state)}
+	d.start = d.state(d.add(nil, make([]bool, len(prog.Inst)), uint32(prog.Start), 0, false), -1)
+	return d, nil
+}
+
+// class returns a representative of the runes which the empty width assertions do not tell apart.
+func class(r rune) rune {
+	switch {
+	case r < 0:
+		return -1
+	case r == '\n':
+		return '\n'
+	case syntax.IsWordChar(r):
+		return 'a'
+	}
+	return ' '
+}
+
+// state returns the state of a set and a previous rune.
+func (d *DFA) state(set []uint32, prev rune) *state {
+	prev = class(prev)
+	key := make([]byte, 0, 1+4*len(set))
+	key = append(key, byte(prev))
+	for _, pc := range set {
+		key = append(key, byte(pc), byte(pc>>8), byte(pc>>16), byte(pc>>24))
+	}
+	if s, ok := d.states[string(key)]; ok {
+		return s
+	}
+	if len(d.states) >= maxStates {
+		d.states = make(map[string]*state)
+		d.gen++
+	}
+	s := &state{set: set, prev: prev, next: make(map[rune]*state), gen: d.gen}
+	for _, pc := range d.resolve(set, prev, -1) {
+		s.match = s.match || d.prog.Inst[pc].Op == syntax.InstMatch
+	}
+	d.states[string(key)] = s
+	return s
+}
+
+// add adds the instruction pc and the instructions reachable from it by empty transitions.
+// If resolve is true, empty width assertions are followed when they hold in the context ctx,
+// otherwise they are kept in the set.
+func (d *DFA) add(set []uint32, seen []bool, pc uint32, ctx syntax.EmptyOp, resolve bool) []uint32 {
+	if seen[pc] {
+		return set
+	}
+	seen[pc] = true
+	switch inst := d.prog.Inst[pc]; inst.Op {
+	case syntax.InstFail:
+	case syntax.InstNop, syntax.InstCapture:
+		set = d.add(set, seen, inst.Out, ctx, resolve)
+	case syntax.InstAlt, syntax.InstAltMatch:
+		set = d.add(set, seen, inst.Out, ctx, resolve)
+		set = d.add(set, seen, inst.Arg, ctx, resolve)
+	case syntax.InstEmptyWidth:
+		if !resolve {
+			set = append(set, pc)
+		} else if syntax.EmptyOp(inst.Arg)&^ctx == 0 {
+			set = d.add(set, seen, inst.Out, ctx, resolve)
+		}
+	default:
+		set = append(set, pc)
+	}
+	return set
+}
+
+// resolve follows the empty width assertions of the set which hold between the runes prev and next.
+func (d *DFA) resolve(set []uint32, prev, next rune) []uint32 {
+	ctx := syntax.EmptyOpContext(prev, next)
+	seen := make([]bool, len(d.prog.Inst))
+	var ret []uint32
+	for _, pc := range set {
+		ret = d.add(ret, seen, pc, ctx, true)
+	}
+	return ret
+}
+
+// step returns the state after reading the rune r. The transition is cached only between the
+// states of the current generation.
+func (d *DFA) step(s *state, r rune) *state {
+	if s.gen != d.gen {
+		s.next = nil
+	} else if n, ok := s.next[r]; ok {
+		return n
+	}
+	seen := make([]bool, len(d.prog.Inst))
+	var set []uint32
+	for _, pc := range d.resolve(s.set, s.prev, r) {
+		inst := d.prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			if inst.MatchRune(r) {
+				set = d.add(set, seen, inst.Out, 0, false)
+			}
+		}
+	}
+	n := d.state(set, r)
+	if s.gen == d.gen {
+		s.next[r] = n
+	}
+	return n
+}
+
+// Cursor represents the DFA after reading the bytes of a key, the bytes of an incomplete rune are
+// kept until the rune is completed.
+type Cursor struct {
+	s       *state
+	pending [utf8.UTFMax]byte
+	n       int
+}
+
+// Start returns the cursor at the beginning of a key.
+func (d *DFA) Start() Cursor {
+	if d.start.gen != d.gen {
+		d.start = d.state(d.start.set, -1)
+	}
+	return Cursor{s: d.start}
+}
+
+// Step returns the cursor after reading a byte of a key. It returns false if no key which starts
+// with the bytes read matches.
+func (d *DFA) Step(c Cursor, b byte) (Cursor, bool) {
+	c.pending[c.n] = b
+	c.n++
+	for c.n > 0 && utf8.FullRune(c.pending[:c.n]) {
+		r, size := utf8.DecodeRune(c.pending[:c.n])
+		c.s = d.step(c.s, r)
+		copy(c.pending[:], c.pending[size:c.n])
+		c.n -= size
+		if len(c.s.set) == 0 {
+			return c, false
+		}
+	}
+	return c, true
+}
+
+// Match reports whether the key read matches the pattern as a whole. The bytes of an incomplete
+// rune at the end of the key are read as utf8.RuneError as the regexp package does.
+func (d *DFA) Match(c Cursor) bool {
+	s := c.s
+	for i := 0; i < c.n && len(s.set) > 0; i++ {
+		s = d.step(s, utf8.RuneError)
+	}
+	return s.match
+}
+
+// GlobToRegexp translates a glob pattern to a regular expression. '*' matches any sequence of
+// characters, '?' matches any single character, '[...]' matches a character class which is
+// negated by a leading '!' or '^', and '\\' escapes the following character.
+func GlobToRegexp(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+		switch r {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '\\':
+			if i < len(pattern) {
+				r, size = utf8.DecodeRuneInString(pattern[i:])
+				i += size
+			}
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+			class := pattern[i : i+end]
+			i += end + 1
+			b.WriteByte('[')
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				b.WriteByte('^')
+				class = class[1:]
+			}
+			for _, c := range class {
+				if c == '-' {
+					b.WriteRune(c)
+					continue
+				}
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+			b.WriteByte(']')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
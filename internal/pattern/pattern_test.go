@@ -0,0 +1,120 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pattern
+
+import (
+	"math/rand"
+	"regexp"
+	"testing"
+)
+
+func match(d *DFA, key string) bool {
+	c := d.Start()
+	for i := 0; i < len(key); i++ {
+		var ok bool
+		if c, ok = d.Step(c, key[i]); !ok {
+			return false
+		}
+	}
+	return d.Match(c)
+}
+
+func TestDFA01(t *testing.T) {
+	patterns := []string{
+		"", "a", "ab*c", "(a|b)*abb", `.*b\b`, `\Ba.*`, `^a|b$`, "(?i)AB", "[^a]?c+",
+		"東.*", ".京", "a{2,3}", `(?m)a$\n?b`, `\x{fffd}+`,
+	}
+	alphabet := []string{"a", "b", "c", " ", "\n", "東", "京", "\xe4", "\xff"}
+	r := rand.New(rand.NewSource(1))
+	for _, p := range patterns {
+		d, err := Compile(p)
+		if err != nil {
+			t.Fatalf("pattern %q: unexpected error: %v", p, err)
+		}
+		re := regexp.MustCompile(`^(?:` + p + `)$`)
+		for i := 0; i < 2000; i++ {
+			var key string
+			for j := r.Intn(6); j > 0; j-- {
+				key += alphabet[r.Intn(len(alphabet))]
+			}
+			if got, exp := match(d, key), re.MatchString(key); got != exp {
+				t.Errorf("pattern %q, key %q: got %v, expected %v", p, key, got, exp)
+			}
+		}
+	}
+	if _, err := Compile("a("); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
+
+func TestDFA02(t *testing.T) {
+	// the DFA of (a|b)*a(a|b){13} has more states than the cache keeps
+	d, err := Compile("(a|b)*a(a|b){13}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	re := regexp.MustCompile(`^(?:(a|b)*a(a|b){13})$`)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		key := make([]byte, 14+r.Intn(8))
+		for j := range key {
+			key[j] = "ab"[r.Intn(2)]
+		}
+		if got, exp := match(d, string(key)), re.Match(key); got != exp {
+			t.Fatalf("key %q: got %v, expected %v", key, got, exp)
+		}
+	}
+	if d.gen == 0 {
+		t.Fatalf("expected the cache to be cleared")
+	}
+	// the states of older generations are not reachable from the start
+	d.Start()
+	seen := map[*state]bool{}
+	stack := []*state{d.start}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		if s.gen != d.gen {
+			t.Fatalf("got a state of generation %d, expected %d", s.gen, d.gen)
+		}
+		for _, n := range s.next {
+			stack = append(stack, n)
+		}
+	}
+	if len(seen) > maxStates {
+		t.Errorf("got %d reachable states, expected at most %d", len(seen), maxStates)
+	}
+}
+
+func TestGlobToRegexp01(t *testing.T) {
+	testdata := []struct {
+		glob string
+		keys map[string]bool
+	}{
+		{glob: "app*", keys: map[string]bool{"app": true, "apple": true, "ap": false}},
+		{glob: "?pple", keys: map[string]bool{"apple": true, "東pple": true, "pple": false}},
+		{glob: "appl[!e]", keys: map[string]bool{"apply": true, "apple": false}},
+		{glob: "a[l-n]ple", keys: map[string]bool{"ample": true, "apple": false}},
+		{glob: `a\*b`, keys: map[string]bool{"a*b": true, "axb": false}},
+		{glob: "a[b", keys: map[string]bool{"a[b": true}},
+	}
+	for _, d := range testdata {
+		re := regexp.MustCompile(`^(?:` + GlobToRegexp(d.glob) + `)$`)
+		for key, exp := range d.keys {
+			if got := re.MatchString(key); got != exp {
+				t.Errorf("glob %q, key %q: got %v, expected %v", d.glob, key, got, exp)
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// mast represents a Minimal Acyclic Subsequential Transducer.
+type mast[O Output[O]] struct {
+	initialState *state[O]
+	states       []*state[O]
+	finalStates  []*state[O]
+}
+
+func (m *mast[O]) addState(n *state[O]) {
+	n.ID = len(m.states)
+	m.states = append(m.states, n)
+	if n.isFinal() {
+		m.finalStates = append(m.finalStates, n)
+	}
+}
+
+// Order represents the order of the outputs of an input.
+type Order int
+
+const (
+	// Sorted orders the outputs of an input in ascending order of their tails.
+	Sorted Order = iota
+	// InsertionOrder orders the outputs of an input in the order of the given pairs.
+	InsertionOrder
+)
+
+// Build constructs a finite state transducer from given pairs, the outputs of an input are sorted.
+func Build[O Output[O]](input PairSlice[O]) (t FST[O], err error) {
+	return BuildWithOrder(input, Sorted)
+}
+
+// BuildWithOrder constructs a finite state transducer from given pairs which returns the outputs of
+// an input in the given order.
+func BuildWithOrder[O Output[O]](input PairSlice[O], order Order) (t FST[O], err error) {
+	m := buildMAST(input, order)
+	return m.compile()
+}
+
+func commonPrefixLen(a, b string) int {
+	end := len(a)
+	if end > len(b) {
+		end = len(b)
+	}
+	var i int
+	for i < end && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+func buildMAST[O Output[O]](input PairSlice[O], order Order) (m *mast[O]) {
+	if order == Sorted {
+		sort.Sort(input)
+	} else {
+		sort.SliceStable(input, func(i, j int) bool { return input[i].In < input[j].In })
+	}
+
+	const initialMASTSize = 1024
+	m = new(mast[O])
+	m.states = make([]*state[O], 0, initialMASTSize)
+	m.finalStates = make([]*state[O], 0, initialMASTSize)
+
+	ids := make(map[string]uint64)
+	id := func(k string) uint64 {
+		x, ok := ids[k]
+		if !ok {
+			x = uint64(len(ids))
+			ids[k] = x
+		}
+		return x
+	}
+	dic := make(map[uint64][]*state[O])
+	freeze := func(n *state[O], base O) *state[O] {
+		if order == Sorted {
+			n.sortTails(base)
+		}
+		h := n.hash(id)
+		for _, c := range dic[h] {
+			if c.eq(n) {
+				n.renew()
+				return c
+			}
+		}
+		s := &state[O]{}
+		*s = *n
+		n.renew()
+		m.addState(s)
+		dic[h] = append(dic[h], s)
+		return s
+	}
+
+	var zero O
+	buf := []*state[O]{newState[O]()}
+	prev := ""
+	// pathOutputs returns the outputs on the path of prev, the i-th one is the output reaching buf[i].
+	var bases []O
+	pathOutputs := func() []O {
+		bases = append(bases[:0], zero)
+		for i := 0; i < len(prev); i++ {
+			bases = append(bases, bases[i].Cat(buf[i].Output[prev[i]]))
+		}
+		return bases
+	}
+	for _, pair := range input {
+		in, out := pair.In, pair.Out
+		for len(buf) <= len(in) {
+			buf = append(buf, newState[O]())
+		}
+		prefixLen := commonPrefixLen(in, prev)
+		base := pathOutputs()
+		for i := len(prev); i > prefixLen; i-- {
+			buf[i-1].setTransition(prev[i-1], freeze(buf[i], base[i]))
+		}
+		for i, size := prefixLen+1, len(in); i <= size; i++ {
+			buf[i-1].setTransition(in[i-1], buf[i])
+		}
+		for j := 1; j < prefixLen+1; j++ {
+			outPref := buf[j-1].Output[in[j-1]].Prefix(out)
+			outSuff := buf[j-1].Output[in[j-1]].Sub(outPref)
+			buf[j-1].setOutput(in[j-1], outPref)
+			if !isZero(outSuff) {
+				for ch := range buf[j].Trans {
+					buf[j].setOutput(ch, outSuff.Cat(buf[j].Output[ch]))
+				}
+				tails := buf[j].tails()
+				buf[j].Tail = make(map[string]O, len(tails))
+				buf[j].order = nil
+				for _, t := range tails {
+					buf[j].addTail(outSuff.Cat(t))
+				}
+			}
+			out = out.Sub(outPref)
+		}
+		if prefixLen == len(in) { // in == prev or the first input is empty
+			buf[len(in)].addTail(out)
+		} else {
+			buf[prefixLen].setOutput(in[prefixLen], out)
+			buf[len(in)].addTail(zero)
+		}
+		prev = in
+	}
+	// flush the buf
+	base := pathOutputs()
+	for i := len(prev); i > 0; i-- {
+		buf[i-1].setTransition(prev[i-1], freeze(buf[i], base[i]))
+	}
+	if order == Sorted {
+		buf[0].sortTails(zero)
+	}
+	m.initialState = buf[0]
+	m.addState(buf[0])
+
+	return
+}
+
+func (m *mast[O]) run(input string) (out []O, ok bool) {
+	var o O
+	s := m.initialState
+	for i, size := 0, len(input); i < size; i++ {
+		o = o.Cat(s.Output[input[i]])
+		if s, ok = s.Trans[input[i]]; !ok {
+			return
+		}
+	}
+	for _, t := range s.tails() {
+		out = append(out, o.Cat(t))
+	}
+	return out, s.isFinal()
+}
+
+func (m *mast[O]) accept(input string) bool {
+	s := m.initialState
+	for i, size := 0, len(input); i < size; i++ {
+		var ok bool
+		if s, ok = s.Trans[input[i]]; !ok {
+			return false
+		}
+	}
+	return s.isFinal()
+}
+
+func (m *mast[O]) dot(w io.Writer) {
+	fmt.Fprintln(w, "digraph G {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+	fmt.Fprintln(w, "\tnode [shape=circle]")
+	for _, s := range m.finalStates {
+		fmt.Fprintf(w, "\t%d [peripheries = 2];\n", s.ID)
+	}
+	for _, from := range m.states {
+		for in, to := range from.Trans {
+			fmt.Fprintf(w, "\t%d -> %d [label=\"%02X/%v", from.ID, to.ID, in, from.Output[in])
+			if to.isFinal() {
+				fmt.Fprintf(w, " %v", to.tails())
+			}
+			fmt.Fprintln(w, "\"];")
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,101 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMASTBuildMAST01(t *testing.T) {
+	m := buildMAST(PairSlice[Int64]{}, Sorted)
+	if m.initialState.ID != 0 {
+		t.Errorf("got initial state id %v, expected 0\n", m.initialState.ID)
+	}
+	if len(m.states) != 1 {
+		t.Errorf("expected: initial state only, got %v\n", m.states)
+	}
+	if len(m.finalStates) != 0 {
+		t.Errorf("expected: final state is empty, got %v\n", m.finalStates)
+	}
+}
+
+func TestMASTBuildMAST02(t *testing.T) {
+	inp := PairSlice[String]{
+		{"apr", "30"},
+		{"aug", "31"},
+		{"dec", "31"},
+		{"feb", "28"},
+		{"feb", "29"},
+		{"jan", "31"},
+		{"jul", "31"},
+		{"jun", "30"},
+	}
+	m := buildMAST(inp, Sorted)
+	m.dot(os.Stdout)
+	// the states after the last input byte and "u" of "jul" and "jun" are shared.
+	if len(m.states) != 13 {
+		t.Errorf("got %v states, expected 13", len(m.states))
+	}
+}
+
+func TestMASTRun01(t *testing.T) {
+	inp := PairSlice[Int64]{
+		{"hello", 1111},
+		{"hell", 2222},
+		{"111", 111},
+		{"112", 112},
+		{"113", 122},
+		{"211", 111},
+		{"", 5},
+	}
+	m := buildMAST(inp, Sorted)
+	for _, pair := range inp {
+		out, ok := m.run(pair.In)
+		if !ok {
+			t.Errorf("expected: accept [%v]\n", pair.In)
+		}
+		if !reflect.DeepEqual(out, []Int64{pair.Out}) {
+			t.Errorf("input: %v, output: got %v, expected %v\n", pair.In, out, pair.Out)
+		}
+	}
+	if out, ok := m.run("aloha"); ok {
+		t.Errorf("expected: reject \"aloha\", %v\n", out)
+	}
+	if ok := m.accept("hel"); ok {
+		t.Errorf("expected: reject \"hel\"\n")
+	}
+}
+
+func TestMASTRun02(t *testing.T) {
+	inp := PairSlice[String]{
+		{"hello", "world"},
+		{"hello", "goodby"},
+		{"hell", "daemon"},
+	}
+	m := buildMAST(inp, Sorted)
+	crs := []struct {
+		in  string
+		out []String
+	}{
+		{"hello", []String{"goodby", "world"}},
+		{"hell", []String{"daemon"}},
+	}
+	for _, cr := range crs {
+		out, ok := m.run(cr.in)
+		if !ok {
+			t.Errorf("expected: accept [%v]\n", cr.in)
+		}
+		if !reflect.DeepEqual(out, cr.out) {
+			t.Errorf("input: %v, output: got %v, expected %v\n", cr.in, out, cr.out)
+		}
+	}
+}
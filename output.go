@@ -0,0 +1,201 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Output represents an output type of transducers. Outputs along a path are combined by Cat,
+// and the zero value of the type must be the identity of Cat. Outputs are identified by their
+// binary encodings, so the type need not be comparable, e.g. Bytes.
+type Output[O any] interface {
+	// Prefix returns the common prefix of the receiver and o.
+	Prefix(o O) O
+	// Sub returns the receiver without a prefix p which is obtained by Prefix.
+	Sub(p O) O
+	// Cat returns the concatenation of the receiver and o.
+	Cat(o O) O
+	// Less reports whether the receiver must sort before o.
+	Less(o O) bool
+	// AppendBinary appends the encoding of the receiver to b. Equal outputs must have the same
+	// encoding, and the encoding must tell its own length.
+	AppendBinary(b []byte) []byte
+	// Decode decodes an output from the head of b and returns it and the number of bytes read,
+	// n <= 0 if b does not start with a valid encoding.
+	Decode(b []byte) (o O, n int)
+}
+
+// key returns the identity of an output.
+func key[O Output[O]](o O) string {
+	var buf [binary.MaxVarintLen64]byte
+	return string(o.AppendBinary(buf[:0]))
+}
+
+// appendUvarint appends x as a uvarint.
+func appendUvarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+// isZero reports whether an output is the identity of Cat.
+func isZero[O Output[O]](o O) bool {
+	var zero O
+	return key(o) == key(zero)
+}
+
+// Int64 represents an integer output. The outputs along a path are summed up.
+type Int64 int64
+
+// Prefix returns the minimum of x and y.
+func (x Int64) Prefix(y Int64) Int64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// Sub returns x - p.
+func (x Int64) Sub(p Int64) Int64 { return x - p }
+
+// Cat returns x + y.
+func (x Int64) Cat(y Int64) Int64 { return x + y }
+
+// Less reports whether x < y.
+func (x Int64) Less(y Int64) bool { return x < y }
+
+// AppendBinary appends x as a varint.
+func (x Int64) AppendBinary(b []byte) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], int64(x))]...)
+}
+
+// Decode decodes a varint.
+func (Int64) Decode(b []byte) (Int64, int) {
+	x, n := binary.Varint(b)
+	return Int64(x), n
+}
+
+// Uint64 represents an unsigned integer output. The outputs along a path are summed up.
+type Uint64 uint64
+
+// Prefix returns the minimum of x and y.
+func (x Uint64) Prefix(y Uint64) Uint64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// Sub returns x - p.
+func (x Uint64) Sub(p Uint64) Uint64 { return x - p }
+
+// Cat returns x + y.
+func (x Uint64) Cat(y Uint64) Uint64 { return x + y }
+
+// Less reports whether x < y.
+func (x Uint64) Less(y Uint64) bool { return x < y }
+
+// AppendBinary appends x as a uvarint.
+func (x Uint64) AppendBinary(b []byte) []byte { return appendUvarint(b, uint64(x)) }
+
+// Decode decodes a uvarint.
+func (Uint64) Decode(b []byte) (Uint64, int) {
+	x, n := binary.Uvarint(b)
+	return Uint64(x), n
+}
+
+// String represents a byte string output. The outputs along a path are concatenated.
+type String string
+
+// Prefix returns the longest common prefix of x and y.
+func (x String) Prefix(y String) String {
+	end := len(x)
+	if end > len(y) {
+		end = len(y)
+	}
+	var i int
+	for i < end && x[i] == y[i] {
+		i++
+	}
+	return x[0:i]
+}
+
+// Sub returns x without the prefix p.
+func (x String) Sub(p String) String { return x[len(p):] }
+
+// Cat returns the concatenation of x and y.
+func (x String) Cat(y String) String { return x + y }
+
+// Less reports whether x < y in lexicographic order.
+func (x String) Less(y String) bool { return x < y }
+
+// AppendBinary appends x with its length.
+func (x String) AppendBinary(b []byte) []byte {
+	return append(appendUvarint(b, uint64(len(x))), x...)
+}
+
+// Decode decodes a string with its length.
+func (String) Decode(b []byte) (String, int) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 || l > uint64(len(b)-n) {
+		return "", 0
+	}
+	return String(b[n : n+int(l)]), n + int(l)
+}
+
+// Bytes represents a byte slice output. The outputs along a path are concatenated, and the
+// outputs returned by transducers must not be modified.
+type Bytes []byte
+
+// Prefix returns the longest common prefix of x and y.
+func (x Bytes) Prefix(y Bytes) Bytes {
+	end := len(x)
+	if end > len(y) {
+		end = len(y)
+	}
+	var i int
+	for i < end && x[i] == y[i] {
+		i++
+	}
+	return x[0:i:i]
+}
+
+// Sub returns x without the prefix p.
+func (x Bytes) Sub(p Bytes) Bytes { return x[len(p):] }
+
+// Cat returns the concatenation of x and y in a new slice.
+func (x Bytes) Cat(y Bytes) Bytes {
+	if len(y) == 0 {
+		return x
+	}
+	if len(x) == 0 {
+		return y
+	}
+	return append(append(make(Bytes, 0, len(x)+len(y)), x...), y...)
+}
+
+// Less reports whether x < y in lexicographic order.
+func (x Bytes) Less(y Bytes) bool { return bytes.Compare(x, y) < 0 }
+
+// AppendBinary appends x with its length.
+func (x Bytes) AppendBinary(b []byte) []byte {
+	return append(appendUvarint(b, uint64(len(x))), x...)
+}
+
+// Decode decodes a byte slice with its length, the result shares the memory of b.
+func (Bytes) Decode(b []byte) (Bytes, int) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 || l > uint64(len(b)-n) {
+		return nil, 0
+	}
+	return Bytes(b[n : n+int(l) : n+int(l)]), n + int(l)
+}
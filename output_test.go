@@ -0,0 +1,115 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInt64Output01(t *testing.T) {
+	crs := []struct {
+		x, y, prefix Int64
+	}{
+		{3, 5, 3},
+		{5, 3, 3},
+		{-2, 7, -2},
+		{0, 0, 0},
+	}
+	for _, cr := range crs {
+		p := cr.x.Prefix(cr.y)
+		if p != cr.prefix {
+			t.Errorf("prefix of %v and %v: got %v, expected %v", cr.x, cr.y, p, cr.prefix)
+		}
+		if got := p.Cat(cr.x.Sub(p)); got != cr.x {
+			t.Errorf("got %v, expected %v", got, cr.x)
+		}
+		if got := p.Cat(cr.y.Sub(p)); got != cr.y {
+			t.Errorf("got %v, expected %v", got, cr.y)
+		}
+	}
+}
+
+func TestStringOutput01(t *testing.T) {
+	crs := []struct {
+		x, y, prefix String
+	}{
+		{"hello", "help", "hel"},
+		{"hello", "", ""},
+		{"abc", "abc", "abc"},
+		{"東京", "東北", "\xe6\x9d\xb1"},
+	}
+	for _, cr := range crs {
+		p := cr.x.Prefix(cr.y)
+		if p != cr.prefix {
+			t.Errorf("prefix of %v and %v: got %q, expected %q", cr.x, cr.y, p, cr.prefix)
+		}
+		if got := p.Cat(cr.x.Sub(p)); got != cr.x {
+			t.Errorf("got %v, expected %v", got, cr.x)
+		}
+		if got := p.Cat(cr.y.Sub(p)); got != cr.y {
+			t.Errorf("got %v, expected %v", got, cr.y)
+		}
+	}
+}
+
+func TestBytesOutput01(t *testing.T) {
+	crs := []struct {
+		x, y, prefix Bytes
+	}{
+		{Bytes("hello"), Bytes("help"), Bytes("hel")},
+		{Bytes("hello"), nil, nil},
+		{Bytes{0, 1}, Bytes{0, 1}, Bytes{0, 1}},
+		{Bytes{0xff}, Bytes{0}, nil},
+	}
+	for _, cr := range crs {
+		p := cr.x.Prefix(cr.y)
+		if !bytes.Equal(p, cr.prefix) {
+			t.Errorf("prefix of %v and %v: got %v, expected %v", cr.x, cr.y, p, cr.prefix)
+		}
+		if got := p.Cat(cr.x.Sub(p)); !bytes.Equal(got, cr.x) {
+			t.Errorf("got %v, expected %v", got, cr.x)
+		}
+		if got := p.Cat(cr.y.Sub(p)); !bytes.Equal(got, cr.y) {
+			t.Errorf("got %v, expected %v", got, cr.y)
+		}
+	}
+	x := Bytes("abc")
+	if got := x[:1].Cat(Bytes("z")); !bytes.Equal(x, Bytes("abc")) || !bytes.Equal(got, Bytes("az")) {
+		t.Errorf("Cat modified the receiver: got %q %q", x, got)
+	}
+}
+
+func TestOutputEncoding01(t *testing.T) {
+	for _, x := range []Int64{0, 1, -1, 1 << 62, -1 << 63} {
+		b := x.AppendBinary([]byte{0xff})
+		if got, n := x.Decode(b[1:]); got != x || n != len(b)-1 {
+			t.Errorf("got %v %v, expected %v %v", got, n, x, len(b)-1)
+		}
+	}
+	for _, x := range []Uint64{0, 1, 1 << 63} {
+		b := x.AppendBinary(nil)
+		if got, n := x.Decode(b); got != x || n != len(b) {
+			t.Errorf("got %v %v, expected %v %v", got, n, x, len(b))
+		}
+	}
+	for _, x := range []String{"", "a", "東京"} {
+		b := x.AppendBinary(nil)
+		if got, n := x.Decode(b); got != x || n != len(b) {
+			t.Errorf("got %q %v, expected %q %v", got, n, x, len(b))
+		}
+		if _, n := x.Decode(b[:len(b)-1]); len(x) > 0 && n > 0 {
+			t.Errorf("decoded a truncated encoding of %q", x)
+		}
+	}
+	if !isZero(Bytes{}) || !isZero(Bytes(nil)) || isZero(Bytes{0}) {
+		t.Errorf("unexpected zero bytes")
+	}
+}
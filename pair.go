@@ -0,0 +1,28 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+// Pair implements a pair of input and output.
+type Pair[O Output[O]] struct {
+	In  string
+	Out O
+}
+
+// PairSlice implements a slice of input and output pairs.
+type PairSlice[O Output[O]] []Pair[O]
+
+func (ps PairSlice[O]) Len() int      { return len(ps) }
+func (ps PairSlice[O]) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps PairSlice[O]) Less(i, j int) bool {
+	if ps[i].In == ps[j].In {
+		return ps[i].Out.Less(ps[j].Out)
+	}
+	return ps[i].In < ps[j].In
+}
@@ -0,0 +1,79 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestScannerFindAll02(t *testing.T) {
+	fst, err := Build(PairSlice[String]{{"he", "1"}, {"she", "2"}, {"hers", "4"}, {"hers", "5"}, {"東京", "6"}, {"京都", "7"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := NewScanner(fst)
+	got := s.FindAll("ushers 東京都")
+	exp := []Match[String]{
+		{Start: 1, End: 4, Outputs: []String{"2"}},
+		{Start: 2, End: 4, Outputs: []String{"1"}},
+		{Start: 2, End: 6, Outputs: []String{"4", "5"}},
+		{Start: 7, End: 13, Outputs: []String{"6"}},
+		{Start: 10, End: 16, Outputs: []String{"7"}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+	got = nil
+	s.FindAllFunc("she, hers 東京", WordBoundary, func(m Match[String]) bool {
+		got = append(got, m)
+		return true
+	})
+	exp = []Match[String]{
+		{Start: 0, End: 3, Outputs: []String{"2"}},
+		{Start: 5, End: 9, Outputs: []String{"4", "5"}},
+		{Start: 10, End: 16, Outputs: []String{"6"}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestScannerFindAll01(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var inp PairSlice[Int64]
+	for i := 0; i < 500; i++ {
+		b := make([]byte, r.Intn(3)+1)
+		for j := range b {
+			b[j] = byte('a' + r.Intn(26))
+		}
+		inp = append(inp, Pair[Int64]{In: string(b), Out: Int64(r.Intn(100) - 50)})
+	}
+	fst := mustBuild(t, inp)
+	s := NewScanner(fst)
+	for i := 0; i < 20; i++ {
+		b := make([]byte, r.Intn(200))
+		for j := range b {
+			b[j] = byte('a' + r.Intn(26))
+		}
+		text := string(b)
+		var naive []Match[Int64]
+		for i := range text {
+			lens, outs := fst.CommonPrefixSearch(text[i:])
+			for j := range lens {
+				naive = append(naive, Match[Int64]{Start: i, End: i + lens[j], Outputs: outs[j]})
+			}
+		}
+		if got := s.FindAll(text); !reflect.DeepEqual(got, naive) {
+			t.Fatalf("text:%q, got %v, expected %v", text, got, naive)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// Package segment implements segmentation of a sentence into the words of a si32 dictionary.
+package segment
+
+import (
+	"math"
+	"unicode/utf8"
+
+	"github.com/ikawaha/mast/si32"
+)
+
+// DefaultUnknownCost is the cost of an unknown character if the cost is not given.
+const DefaultUnknownCost = 10000
+
+// Token represents a word of a segmentation, Input[Start:End] is the surface of the word.
+type Token struct {
+	Start   int
+	End     int
+	Output  int32 // output of the dictionary, 0 if the word is unknown
+	Cost    int
+	Unknown bool
+}
+
+// Segmenter segments sentences with a dictionary.
+type Segmenter struct {
+	dic         si32.FST
+	cost        func(out int32) int
+	unknownCost int
+}
+
+// New returns a segmenter with a dictionary, cost gives the cost of a word by its output.
+// A character which is not covered by the dictionary becomes an unknown word with the cost unknownCost,
+// if unknownCost <= 0 DefaultUnknownCost is used.
+func New(dic si32.FST, cost func(out int32) int, unknownCost int) *Segmenter {
+	if unknownCost <= 0 {
+		unknownCost = DefaultUnknownCost
+	}
+	return &Segmenter{dic: dic, cost: cost, unknownCost: unknownCost}
+}
+
+// Lattice represents a word lattice of a sentence, Nodes[i] are the words starting at the byte offset i.
+type Lattice struct {
+	Input string
+	Nodes [][]Token
+}
+
+func (s *Segmenter) unknown(input string, i int) Token {
+	_, size := utf8.DecodeRuneInString(input[i:])
+	return Token{Start: i, End: i + size, Cost: s.unknownCost, Unknown: true}
+}
+
+// Lattice builds a word lattice of the input. Every output of a dictionary word becomes a node, and
+// an unknown word of one character is added at the offsets where no dictionary word starts.
+func (s *Segmenter) Lattice(input string) *Lattice {
+	la := &Lattice{Input: input, Nodes: make([][]Token, len(input))}
+	for i := 0; i < len(input); {
+		lens, outs := s.dic.CommonPrefixSearch(input[i:])
+		for j, l := range lens {
+			if l == 0 {
+				continue
+			}
+			for _, out := range outs[j] {
+				la.Nodes[i] = append(la.Nodes[i], Token{Start: i, End: i + l, Output: out, Cost: s.cost(out)})
+			}
+		}
+		if len(la.Nodes[i]) == 0 {
+			la.Nodes[i] = append(la.Nodes[i], s.unknown(input, i))
+		}
+		_, size := utf8.DecodeRuneInString(input[i:])
+		i += size
+	}
+	return la
+}
+
+// Viterbi returns the segmentation whose total cost is minimum, or nil if the end of the input is
+// not reachable.
+func (la *Lattice) Viterbi() []Token {
+	n := len(la.Input)
+	best := make([]int, n+1)
+	prev := make([]*Token, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.MaxInt
+	}
+	for i := 0; i < n; i++ {
+		if best[i] == math.MaxInt {
+			continue
+		}
+		for j := range la.Nodes[i] {
+			t := &la.Nodes[i][j]
+			if c := best[i] + t.Cost; c < best[t.End] {
+				best[t.End], prev[t.End] = c, t
+			}
+		}
+	}
+	if n > 0 && prev[n] == nil {
+		return nil
+	}
+	var path []Token
+	for i := n; i > 0; i = prev[i].Start {
+		path = append(path, *prev[i])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Viterbi returns the minimum cost segmentation of the input.
+func (s *Segmenter) Viterbi(input string) []Token {
+	return s.Lattice(input).Viterbi()
+}
+
+// LongestMatch returns the segmentation which greedily takes the longest dictionary word from the
+// beginning of the input. If a word has several outputs, the one with the minimum cost is taken.
+func (s *Segmenter) LongestMatch(input string) []Token {
+	var ret []Token
+	for i := 0; i < len(input); {
+		t := s.unknown(input, i)
+		if l, outs := s.dic.PrefixSearch(input[i:]); l > 0 {
+			t = Token{Start: i, End: i + l, Cost: math.MaxInt}
+			for _, out := range outs {
+				if c := s.cost(out); c < t.Cost {
+					t.Output, t.Cost = out, c
+				}
+			}
+		}
+		ret = append(ret, t)
+		i = t.End
+	}
+	return ret
+}
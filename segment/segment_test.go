@@ -0,0 +1,104 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segment
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ikawaha/mast/si32"
+)
+
+func surfaces(input string, ts []Token) []string {
+	var ret []string
+	for _, t := range ts {
+		ret = append(ret, input[t.Start:t.End])
+	}
+	return ret
+}
+
+func newTestSegmenter(t *testing.T) *Segmenter {
+	words := []string{"すもも", "もも", "も", "の", "うち", "すも", "もものうち"}
+	costs := []int{100, 90, 50, 50, 100, 200, 1000}
+	var inp si32.PairSlice
+	for i, w := range words {
+		inp = append(inp, si32.Pair{In: w, Out: int32(i)})
+	}
+	dic, err := si32.Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return New(dic, func(out int32) int { return costs[out] }, 0)
+}
+
+func TestViterbi01(t *testing.T) {
+	s := newTestSegmenter(t)
+	testdata := []struct {
+		input string
+		exp   []string
+	}{
+		{input: "すもももももももものうち", exp: []string{"すもも", "もも", "もも", "もも", "の", "うち"}},
+		{input: "すもの", exp: []string{"すも", "の"}},
+		{input: "もxの", exp: []string{"も", "x", "の"}},
+		{input: "", exp: nil},
+	}
+	for _, d := range testdata {
+		got := s.Viterbi(d.input)
+		if ss := surfaces(d.input, got); !reflect.DeepEqual(ss, d.exp) {
+			t.Errorf("input %v: got %v, expected %v", d.input, ss, d.exp)
+		}
+	}
+}
+
+func TestViterbi02(t *testing.T) {
+	s := newTestSegmenter(t)
+	got := s.Viterbi("もxの")
+	exp := []Token{
+		{Start: 0, End: 3, Output: 2, Cost: 50},
+		{Start: 3, End: 4, Cost: DefaultUnknownCost, Unknown: true},
+		{Start: 4, End: 7, Output: 3, Cost: 50},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %+v, expected %+v", got, exp)
+	}
+}
+
+func TestLongestMatch01(t *testing.T) {
+	s := newTestSegmenter(t)
+	testdata := []struct {
+		input string
+		exp   []string
+	}{
+		{input: "すもももももももものうち", exp: []string{"すもも", "もも", "もも", "もものうち"}},
+		{input: "あすもも", exp: []string{"あ", "すもも"}},
+		{input: "", exp: nil},
+	}
+	for _, d := range testdata {
+		got := s.LongestMatch(d.input)
+		if ss := surfaces(d.input, got); !reflect.DeepEqual(ss, d.exp) {
+			t.Errorf("input %v: got %v, expected %v", d.input, ss, d.exp)
+		}
+	}
+}
+
+func TestLattice01(t *testing.T) {
+	s := newTestSegmenter(t)
+	la := s.Lattice("すもも")
+	var got []string
+	for _, ns := range la.Nodes {
+		for _, n := range ns {
+			got = append(got, la.Input[n.Start:n.End])
+		}
+	}
+	exp := []string{"すも", "すもも", "も", "もも", "も"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+}
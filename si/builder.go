@@ -0,0 +1,109 @@
+package si
+
+// register keeps the frozen states of a transducer under construction.
+type register interface {
+	// freeze returns a frozen state equivalent to a given state.
+	freeze(n *state) (*state, error)
+	// finish registers the initial state.
+	finish(initial *state) error
+}
+
+// mastRegister keeps all frozen states in memory to build a mast.
+type mastRegister struct {
+	m   *mast
+	dic map[uint][]*state
+}
+
+func newMastRegister() *mastRegister {
+	const initialMastSize = 1024
+	r := &mastRegister{
+		m:   new(mast),
+		dic: make(map[uint][]*state),
+	}
+	r.m.states = make([]*state, 0, initialMastSize)
+	r.m.finalStates = make([]*state, 0, initialMastSize)
+	return r
+}
+
+func (r *mastRegister) freeze(n *state) (*state, error) {
+	if cs, ok := r.dic[n.hcode]; ok {
+		for _, c := range cs {
+			if c.eq(n) {
+				c.setInvTransition()
+				return c, nil
+			}
+		}
+	}
+	s := &state{}
+	*s = *n
+	r.m.addState(s)
+	r.dic[s.hcode] = append(r.dic[s.hcode], s)
+	s.setInvTransition()
+	return s, nil
+}
+
+func (r *mastRegister) finish(initial *state) error {
+	r.m.initialState = initial
+	r.m.addState(initial)
+	return nil
+}
+
+// builder implements the construction of a mast from pairs sorted by input.
+type builder struct {
+	reg   register
+	buf   []*state
+	prev  string
+	order Order
+}
+
+// freeze returns a frozen state equivalent to the state of the buffer at i, and renews the buffer.
+func (b *builder) freeze(i int) (*state, error) {
+	n := b.buf[i]
+	if b.order == Sorted {
+		n.sortTails()
+	}
+	s, err := b.reg.freeze(n)
+	n.renew()
+	return s, err
+}
+
+func (b *builder) add(in string, out int) error {
+	for len(b.buf) <= len(in) {
+		b.buf = append(b.buf, newState())
+	}
+	buf, prev := b.buf, b.prev
+	prefixLen := commonPrefixLen(in, prev)
+	for i := len(prev); i > prefixLen; i-- {
+		s, err := b.freeze(i)
+		if err != nil {
+			return err
+		}
+		buf[i-1].setTransition(prev[i-1], s)
+	}
+	for i, size := prefixLen+1, len(in); i <= size; i++ {
+		buf[i-1].setTransition(in[i-1], buf[i])
+	}
+	if in != prev {
+		buf[len(in)].IsFinal = true
+	}
+	buf[len(in)].addTail(out)
+	b.prev = in
+	return nil
+}
+
+func (b *builder) flush() error {
+	if len(b.buf) == 0 {
+		b.buf = append(b.buf, newState())
+	}
+	for i := len(b.prev); i > 0; i-- {
+		s, err := b.freeze(i)
+		if err != nil {
+			return err
+		}
+		b.buf[i-1].setTransition(b.prev[i-1], s)
+	}
+	if b.order == Sorted {
+		b.buf[0].sortTails()
+	}
+	return b.reg.finish(b.buf[0])
+}
@@ -0,0 +1,227 @@
+package si
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strconv"
+
+	"github.com/ikawaha/mast/container"
+	"github.com/ikawaha/mast/internal/extsort"
+)
+
+// DefaultChunkSize is the number of pairs sorted in memory at once by BuildExternal.
+const DefaultChunkSize = 1 << 20
+
+// DefaultRegisterSize is the number of frozen states kept for minimization by BuildExternal.
+const DefaultRegisterSize = 1 << 20
+
+// spillSize is the number of bytes of the program kept in memory before they are spilled out.
+const spillSize = 1 << 18
+
+// ExternalConfig represents a configuration of the external memory build.
+type ExternalConfig struct {
+	TempDir      string // directory for temporary files, the default directory if empty
+	ChunkSize    int    // number of pairs sorted in memory at once, DefaultChunkSize if zero
+	RegisterSize int    // number of frozen states kept for minimization, DefaultRegisterSize if zero, unlimited if negative
+}
+
+// ExternalStats represents statistics of an external memory build.
+type ExternalStats struct {
+	Written int64 // number of bytes written
+	Pairs   int   // number of pairs read
+	States  int   // number of compiled states
+	Clears  int   // number of times the register was cleared since it reached cfg.RegisterSize
+}
+
+// Minimal reports whether the transducer built is minimal. It is not if the register was cleared,
+// because equivalent states frozen before and after a clear are compiled twice.
+func (s ExternalStats) Minimal() bool {
+	return s.Clears == 0
+}
+
+// BuildExternal constructs a finite state transducer from lines of tab separated input and output
+// read from r, and writes it to w in the format of FstVM.Save. The outputs of an input are sorted.
+// The pairs are sorted by external merge sort and the compiled program is spilled out to temporary
+// files as states are frozen. A frozen state is kept only by its signature and its address in the
+// register, which is bounded by cfg.RegisterSize, so the memory does not grow with the input. If
+// the register is full, it is cleared and the result may not be minimal; this is reported by the
+// returned stats.
+func BuildExternal(w io.Writer, r io.Reader, cfg ExternalConfig) (stats ExternalStats, err error) {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = DefaultChunkSize
+	}
+	if cfg.RegisterSize == 0 {
+		cfg.RegisterSize = DefaultRegisterSize
+	}
+	reg, err := newSpillRegister(cfg.TempDir, cfg.RegisterSize)
+	if err != nil {
+		return
+	}
+	defer reg.close()
+
+	b := builder{reg: reg}
+	if err = extsort.Sort(r, cfg.TempDir, cfg.ChunkSize, pairCodec, func(p Pair) error {
+		stats.Pairs++
+		return b.add(p.In, p.Out)
+	}); err != nil {
+		return
+	}
+	if err = b.flush(); err != nil {
+		return
+	}
+	stats.States, stats.Clears = reg.ids, reg.clears
+	stats.Written, err = reg.writeTo(w)
+	return
+}
+
+// pairCodec orders the pairs by input and output and stores them in the temporary files.
+var pairCodec = extsort.Codec[Pair]{
+	Parse: func(line string) (p Pair, err error) {
+		in, out, err := extsort.Split(line)
+		if err != nil {
+			return p, err
+		}
+		v, err := strconv.Atoi(out)
+		return Pair{In: in, Out: v}, err
+	},
+	Less: func(a, b Pair) bool {
+		return a.In < b.In || a.In == b.In && a.Out < b.Out
+	},
+	Append: func(b []byte, p Pair) []byte {
+		var tmp [binary.MaxVarintLen64]byte
+		b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(p.In)))]...)
+		b = append(b, p.In...)
+		return append(b, tmp[:binary.PutVarint(tmp[:], int64(p.Out))]...)
+	},
+	Read: func(r *bufio.Reader) (p Pair, err error) {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return
+		}
+		in := make([]byte, size)
+		if _, err = io.ReadFull(r, in); err != nil {
+			return
+		}
+		out, err := binary.ReadVarint(r)
+		return Pair{In: string(in), Out: int(out)}, err
+	},
+}
+
+// spillRegister compiles states as soon as they are frozen and spills the program out to
+// temporary files. Frozen states are identified by their signatures, and kept as stubs which
+// have only the addresses of their codes, so they are released after compilation.
+type spillRegister struct {
+	sigs   map[string]*state
+	size   int
+	ids    int
+	clears int // number of times sigs was cleared
+	c      compiler
+	prog   *extsort.File
+	data   *extsort.File
+}
+
+func newSpillRegister(dir string, size int) (r *spillRegister, err error) {
+	r = &spillRegister{
+		sigs: make(map[string]*state),
+		size: size,
+	}
+	if r.prog, err = extsort.Create(dir, "mast-prog-"); err != nil {
+		return
+	}
+	if r.data, err = extsort.Create(dir, "mast-data-"); err != nil {
+		r.close()
+		return
+	}
+	return
+}
+
+func (r *spillRegister) close() {
+	if r.prog != nil {
+		r.prog.Remove()
+	}
+	if r.data != nil {
+		r.data.Remove()
+	}
+}
+
+// compile compiles a copy of the state and returns the stub of it.
+func (r *spillRegister) compile(n *state) (*state, error) {
+	s := *n
+	s.ID = r.ids
+	r.ids++
+	if err := r.c.compile(&s); err != nil {
+		return nil, err
+	}
+	return &state{ID: s.ID, IsFinal: s.IsFinal, addr: s.addr}, r.spill(spillSize)
+}
+
+func (r *spillRegister) freeze(n *state) (*state, error) {
+	sig := n.signature()
+	if s, ok := r.sigs[sig]; ok {
+		return s, nil
+	}
+	s, err := r.compile(n)
+	if err != nil {
+		return nil, err
+	}
+	if r.size > 0 && len(r.sigs) >= r.size {
+		r.sigs = make(map[string]*state)
+		r.clears++
+	}
+	r.sigs[sig] = s
+	return s, nil
+}
+
+func (r *spillRegister) finish(initial *state) error {
+	if _, err := r.compile(initial); err != nil {
+		return err
+	}
+	return r.spill(0)
+}
+
+// spill writes out the compiled codes if more than limit bytes are kept in memory.
+func (r *spillRegister) spill(limit int) error {
+	if len(r.c.prog) < limit {
+		return nil
+	}
+	if _, err := r.prog.Write(r.c.prog); err != nil {
+		return err
+	}
+	r.c.progBase += len(r.c.prog)
+	r.c.prog = r.c.prog[:0]
+
+	var tmp [8]byte
+	for _, v := range r.c.data {
+		binary.LittleEndian.PutUint64(tmp[:], uint64(v))
+		if _, err := r.data.Write(tmp[:]); err != nil {
+			return err
+		}
+	}
+	r.c.dataBase += len(r.c.data)
+	r.c.data = r.c.data[:0]
+	return nil
+}
+
+func (r *spillRegister) writeTo(w io.Writer) (n int64, err error) {
+	cw, err := container.NewWriter(w, container.Header{
+		Kind:     container.KindSI,
+		Sections: []int64{int64(r.c.progBase), int64(r.c.dataBase)},
+	})
+	if err != nil {
+		return
+	}
+	defer func() { n = cw.Len() }()
+	if _, err = r.prog.WriteReversedTo(cw, 1); err != nil {
+		return
+	}
+	data, err := r.data.Reader()
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(cw, data); err != nil {
+		return
+	}
+	err = cw.Close()
+	return
+}
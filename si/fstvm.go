@@ -1,9 +1,13 @@
 package si
 
 import (
+	"bufio"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
+
+	"github.com/ikawaha/mast/container"
 )
 
 type instOp byte
@@ -15,13 +19,17 @@ const (
 	valMask   byte = 0xFF >> instBits
 	instMask  byte = 0xFF - valMask
 
-	instAccept instOp = 0x01 << instShift
-	instMatch         = 0x02 << instShift
-	instBreak         = 0x03 << instShift
+	// operandFlag marks the instructions followed by operands, the other bits of valMask are zero.
+	operandFlag byte = 0x01
+
+	instAcceptBreak instOp = 0x00 << instShift
+	instAccept             = 0x01 << instShift
+	instMatch              = 0x02 << instShift
+	instBreak              = 0x03 << instShift
 )
 
 var instOpName = [2 << instBits]string{
-	"OP0",
+	"ACB",
 	"ACC",
 	"MAT",
 	"BRK",
@@ -43,13 +51,69 @@ type configuration struct {
 	inp int
 }
 
-func toInt(b []byte) int {
-	var x int
-	for i, size := 0, len(b); i < size; i++ {
-		x <<= 8
-		x += int(b[i])
+// maxOperand is the limit of the operands of instructions, i.e. jumps and ranges of the data.
+// It keeps the programs portable to the platforms with 32-bit ints.
+var maxOperand = math.MaxInt32
+
+// appendOperand appends the operand x as an unsigned varint to the program built in reverse order.
+func appendOperand(prog []byte, x int) ([]byte, error) {
+	if x < 0 || x > maxOperand {
+		return prog, fmt.Errorf("operand %d exceeds the limit %d", x, maxOperand)
+	}
+	var buf [binary.MaxVarintLen64]byte
+	for i := binary.PutUvarint(buf[:], uint64(x)) - 1; i >= 0; i-- {
+		prog = append(prog, buf[i])
+	}
+	return prog, nil
+}
+
+// operand decodes the operand at pc and returns it and its size, the size is 0 if the operand is broken.
+func (vm FstVM) operand(pc int) (x, n int) {
+	if pc > len(vm.prog) {
+		return 0, 0
+	}
+	v, n := binary.Uvarint(vm.prog[pc:])
+	if n <= 0 || v > uint64(maxOperand) {
+		return 0, 0
+	}
+	return int(v), n
+}
+
+// accept decodes the accept instruction at pc and returns the range of the outputs in the data
+// and the address of the next instruction.
+func (vm FstVM) accept(pc int) (s, e, next int, ok bool) {
+	next = pc + 1
+	if vm.prog[pc]&operandFlag == 0 {
+		return 0, 0, next, true
+	}
+	s, n := vm.operand(next)
+	if n == 0 {
+		return
+	}
+	next += n
+	l, n := vm.operand(next)
+	if n == 0 {
+		return
+	}
+	return s, s + l, next + n, true
+}
+
+// edge decodes the edge instruction at pc and returns the character, the jump and the address of
+// the end of the edge, the destination of the edge is end + jump.
+func (vm FstVM) edge(pc int) (ch byte, jump, end int, ok bool) {
+	end = pc + 2
+	if end > len(vm.prog) {
+		return
+	}
+	ch = vm.prog[pc+1]
+	if vm.prog[pc]&operandFlag != 0 {
+		var n int
+		if jump, n = vm.operand(end); n == 0 {
+			return
+		}
+		end += n
 	}
-	return x
+	return ch, jump, end, true
 }
 
 // String returns a string representation of a program.
@@ -58,34 +122,31 @@ func (vm FstVM) String() string {
 	for pc := 0; pc < len(vm.prog); {
 		p := pc
 		op := instOp(vm.prog[pc] & instMask)
-		sz := int(vm.prog[pc] & valMask)
-		pc++
-		if op == instAccept {
-			if sz == 0 {
+		if op == instAccept || op == instAcceptBreak {
+			s, e, next, ok := vm.accept(pc)
+			if !ok {
+				return ret + fmt.Sprintf("%3d  %v ?\n", p, op)
+			}
+			if next == pc+1 {
 				ret += fmt.Sprintf("%3d  %v\n", p, op)
 			} else {
-				s := toInt(vm.prog[pc : pc+sz])
-				pc += sz
-				sz = int(vm.prog[pc])
-				pc++
-				e := toInt(vm.prog[pc : pc+sz])
 				ret += fmt.Sprintf("%3d  %v %v\n", p, op, vm.data[s:e])
-				for j := p + 1; j <= pc; j++ {
+				for j := p + 1; j < next; j++ {
 					ret += fmt.Sprintf("%3d [TIL addr=%d:%d]\n", j, s, e)
 				}
-				pc += sz
 			}
+			pc = next
 			continue
 		}
-		inp := vm.prog[pc]
-		pc++
-		buf := vm.prog[pc : pc+sz]
-		v := toInt(buf)
-		ret += fmt.Sprintf("%3d  %v %X %d (sz:%d)\n", p, op, inp, v, sz)
-		for j := p + 1; j < pc+sz; j++ {
-			ret += fmt.Sprintf("%3d [%v %X %d]\n", j, op, inp, v)
+		ch, jump, end, ok := vm.edge(pc)
+		if !ok {
+			return ret + fmt.Sprintf("%3d  %v ?\n", p, op)
 		}
-		pc += sz
+		ret += fmt.Sprintf("%3d  %v %X %d (sz:%d)\n", p, op, ch, jump, end-p-2)
+		for j := p + 1; j < end; j++ {
+			ret += fmt.Sprintf("%3d [%v %X %d]\n", j, op, ch, jump)
+		}
+		pc = end
 	}
 	return ret
 }
@@ -103,50 +164,29 @@ func (vm *FstVM) run(input string) (snap []configuration, accept bool) {
 	var (
 		pc int    // program counter
 		op instOp // operation
-		sz int    // size
-		ch byte   // char
 		hd int    // input head
-		va int    // value
 	)
 	for pc < len(vm.prog) && hd < len(input) {
 		op = instOp(vm.prog[pc] & instMask)
-		sz = int(vm.prog[pc] & valMask)
-		//fmt.Printf("pc:%v,op:%v,hd:%v,sz:%v\n", pc, op, hd, sz) //XXX
 		switch op {
-		case instMatch:
-			fallthrough
-		case instBreak:
-			pc++
-			ch = vm.prog[pc]
-			pc++
+		case instMatch, instBreak:
+			ch, jump, end, _ := vm.edge(pc)
 			if ch != input[hd] {
 				if op == instBreak {
 					return
 				}
-				if sz > 0 {
-					pc += sz
-				}
+				pc = end
 				continue
 			}
-			if sz > 0 {
-				va = toInt(vm.prog[pc : pc+sz])
-				pc += sz + va
-			} else {
-				va = 0
-			}
+			pc = end + jump
 			hd++
-			continue
-		case instAccept:
+		case instAccept, instAcceptBreak:
 			snap = append(snap, configuration{pc, hd})
-			pc++
-			if sz > 0 {
-				pc += sz
-				sz = int(vm.prog[pc])
-				pc += sz + 1
+			if op == instAcceptBreak {
+				return
 			}
-			continue
+			_, _, pc, _ = vm.accept(pc)
 		default:
-			//fmt.Printf("unknown op:%v\n", op)
 			return
 		}
 	}
@@ -154,10 +194,8 @@ func (vm *FstVM) run(input string) (snap []configuration, accept bool) {
 	if pc >= len(vm.prog) || hd != len(input) {
 		return
 	}
-	if op = instOp(vm.prog[pc] & instMask); op != instAccept {
-		//fmt.Printf("[[FINAL]]pc:%d, op:%s, ch:[%X], sz:%d, v:%d\n", pc, op, ch, sz, va) //XXX
+	if op = instOp(vm.prog[pc] & instMask); op != instAccept && op != instAcceptBreak {
 		return
-
 	}
 	accept = true
 	snap = append(snap, configuration{pc, hd})
@@ -171,15 +209,7 @@ func (vm *FstVM) Search(input string) []int {
 		return nil
 	}
 	c := snap[len(snap)-1]
-	pc := c.pc
-	sz := int(vm.prog[pc] & valMask)
-	pc++
-	s := toInt(vm.prog[pc : pc+sz])
-	pc += sz
-	sz = int(vm.prog[pc])
-	pc++
-	e := toInt(vm.prog[pc : pc+sz])
-	pc += sz
+	s, e, _, _ := vm.accept(c.pc)
 	return vm.data[s:e]
 }
 
@@ -190,15 +220,7 @@ func (vm *FstVM) PrefixSearch(input string) (int, []int) {
 		return -1, nil
 	}
 	c := snap[len(snap)-1]
-	pc := c.pc
-	sz := int(vm.prog[pc] & valMask)
-	pc++
-	s := toInt(vm.prog[pc : pc+sz])
-	pc += sz
-	sz = int(vm.prog[pc])
-	pc++
-	e := toInt(vm.prog[pc : pc+sz])
-	pc += sz
+	s, e, _, _ := vm.accept(c.pc)
 	return c.inp, vm.data[s:e]
 
 }
@@ -211,15 +233,7 @@ func (vm *FstVM) CommonPrefixSearch(input string) (lens []int, outputs [][]int)
 		return
 	}
 	for _, c := range snap {
-		pc := c.pc
-		sz := int(vm.prog[pc] & valMask)
-		pc++
-		s := toInt(vm.prog[pc : pc+sz])
-		pc += sz
-		sz = int(vm.prog[pc])
-		pc++
-		e := toInt(vm.prog[pc : pc+sz])
-		pc += sz
+		s, e, _, _ := vm.accept(c.pc)
 		lens = append(lens, c.inp)
 		outputs = append(outputs, vm.data[s:e])
 	}
@@ -229,43 +243,50 @@ func (vm *FstVM) CommonPrefixSearch(input string) (lens []int, outputs [][]int)
 
 // Save FstVM
 func (vm FstVM) Save(w io.Writer) (err error) {
-	if err = binary.Write(w, binary.LittleEndian, int64(len(vm.prog))); err != nil {
+	cw, err := container.NewWriter(w, container.Header{
+		Kind:     container.KindSI,
+		Sections: []int64{int64(len(vm.prog)), int64(len(vm.data))},
+	})
+	if err != nil {
 		return
 	}
-	if _, err = w.Write(vm.prog); err != nil { //TODO compress
-		return
-	}
-	if err = binary.Write(w, binary.LittleEndian, int64(len(vm.data))); err != nil {
+	if _, err = cw.Write(vm.prog); err != nil { //TODO compress
 		return
 	}
 	for i := 0; i < len(vm.data); i++ {
-		if err = binary.Write(w, binary.LittleEndian, int64(vm.data[i])); err != nil {
+		if err = binary.Write(cw, binary.LittleEndian, int64(vm.data[i])); err != nil {
 			return
 		}
 	}
-	return
+	return cw.Close()
 }
 
 // Load FstVM
 func (vm *FstVM) Load(r io.Reader) (err error) {
-	var n int64
-	if err = binary.Read(r, binary.LittleEndian, &n); err != nil {
+	cr, err := container.NewReader(bufio.NewReader(r), container.KindSI)
+	if err != nil {
 		return
 	}
-	prog := make([]byte, n, n)
-	if _, err = r.Read(prog); err != nil {
+	if len(cr.Sections) != 2 || cr.Sections[0] < 0 || cr.Sections[1] < 0 {
+		return fmt.Errorf("invalid format: sections %v", cr.Sections)
+	}
+	prog, err := cr.ReadSection(cr.Sections[0], 1)
+	if err != nil {
 		return
 	}
-	if err = binary.Read(r, binary.LittleEndian, &n); err != nil {
+	b, err := cr.ReadSection(cr.Sections[1], 8)
+	if err != nil {
 		return
 	}
-	data := make([]int, n, n)
-	for i := 0; i < int(n); i++ {
-		var v int64
-		if err = binary.Read(r, binary.LittleEndian, &v); err != nil {
-			return
-		}
-		data[i] = int(v)
+	data := make([]int, cr.Sections[1])
+	for i := range data {
+		data[i] = int(int64(binary.LittleEndian.Uint64(b[8*i:])))
+	}
+	if err = cr.Close(); err != nil {
+		return
+	}
+	if err = (FstVM{prog: prog, data: data}).Verify(); err != nil {
+		return
 	}
 	vm.prog = prog
 	vm.data = data
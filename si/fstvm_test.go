@@ -144,6 +144,42 @@ func TestFstVMSearch06(t *testing.T) {
 	}
 }
 
+func TestFstVMSearch07(t *testing.T) {
+	inp := PairSlice{
+		{"a", 3},
+		{"b", 2},
+		{"a", 1},
+		{"b", 3},
+		{"a", 2},
+		{"b", 1},
+		{"a", 1},
+		{"c", 2},
+		{"c", 1},
+		{"ab", 2},
+		{"ab", 1},
+	}
+	crs := []struct {
+		order Order
+		outs  map[string][]int
+	}{
+		{Sorted, map[string][]int{"a": {1, 2, 3}, "b": {1, 2, 3}, "c": {1, 2}, "ab": {1, 2}}},
+		{InsertionOrder, map[string][]int{"a": {3, 1, 2}, "b": {2, 3, 1}, "c": {2, 1}, "ab": {2, 1}}},
+	}
+	for _, cr := range crs {
+		for i := 0; i < 10; i++ {
+			vm, e := BuildWithOrder(append(PairSlice(nil), inp...), cr.order)
+			if e != nil {
+				t.Fatalf("unexpected error: %v\n", e)
+			}
+			for in, exp := range cr.outs {
+				if outs := vm.Search(in); !reflect.DeepEqual(outs, exp) {
+					t.Errorf("order %v, input: %v, got %v, expected %v\n", cr.order, in, outs, exp)
+				}
+			}
+		}
+	}
+}
+
 func TestFstVMPrefixSearch06(t *testing.T) {
 	inp := PairSlice{
 		{"こんにちは", 111},
@@ -230,22 +266,24 @@ func TestFstVMCommonPrefixSearch06(t *testing.T) {
 	}
 }
 
-func TestToInt01(t *testing.T) {
+func TestOperand01(t *testing.T) {
 	cr := []struct {
 		in  []byte
 		out int
+		n   int
 	}{
-		{[]byte{7}, 7},
-		{[]byte{255}, 255},
-		{[]byte{0, 1}, 1},
-		{[]byte{1, 0}, 256},
-		{[]byte{2, 0}, 512},
-		{[]byte{4, 0}, 1024},
-		{[]byte{255, 255, 255}, 0xFFFFFF},
+		{[]byte{7}, 7, 1},
+		{[]byte{0x7F, 0xFF}, 0x7F, 1},
+		{[]byte{0xAC, 0x02}, 300, 2},
+		{[]byte{0xFF, 0xFF, 0xFF, 0x07}, 0xFFFFFF, 4},
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x07}, 0x7FFFFFFF, 5},
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x0F}, 0, 0},
+		{[]byte{0x80}, 0, 0},
+		{[]byte{}, 0, 0},
 	}
 	for _, s := range cr {
-		if r := toInt(s.in); !reflect.DeepEqual(s.out, r) {
-			t.Errorf("got %v, expected %v\n", r, s.out)
+		if r, n := (FstVM{prog: s.in}).operand(0); r != s.out || n != s.n {
+			t.Errorf("%v: got %v, %v, expected %v, %v\n", s.in, r, n, s.out, s.n)
 		}
 	}
 }
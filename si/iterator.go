@@ -0,0 +1,128 @@
+package si
+
+// arc represents a transition of a compiled state.
+type arc struct {
+	ch   byte
+	next int // address of the destination state
+}
+
+// node represents a compiled state.
+type node struct {
+	final bool
+	tails []int
+	arcs  []arc
+}
+
+// node decodes the compiled state at pc.
+func (vm FstVM) node(pc int) (n node) {
+	if pc >= len(vm.prog) {
+		return
+	}
+	op := instOp(vm.prog[pc] & instMask)
+	if op == instAccept || op == instAcceptBreak {
+		n.final = true
+		s, e, next, _ := vm.accept(pc)
+		n.tails = vm.data[s:e]
+		if op == instAcceptBreak {
+			return
+		}
+		pc = next
+	}
+	for pc < len(vm.prog) {
+		op = instOp(vm.prog[pc] & instMask)
+		if op != instMatch && op != instBreak {
+			return
+		}
+		ch, jump, end, _ := vm.edge(pc)
+		n.arcs = append(n.arcs, arc{ch: ch, next: end + jump})
+		if op == instBreak {
+			return
+		}
+		pc = end
+	}
+	return
+}
+
+type frame struct {
+	node    node
+	arc     int // index of the next arc to visit
+	visited bool
+}
+
+// Iterator enumerates the keys and outputs of a finite state transducer in lexicographic order.
+type Iterator struct {
+	vm    FstVM
+	key   []byte
+	stack []frame
+}
+
+// All returns an iterator over all the keys and outputs of the finite state transducer.
+func (vm FstVM) All() *Iterator {
+	return &Iterator{
+		vm:    vm,
+		stack: []frame{{node: vm.node(0)}},
+	}
+}
+
+// Next returns the next key and its outputs. ok is false if there are no more keys.
+func (it *Iterator) Next() (key string, outs []int, ok bool) {
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+		if !top.visited {
+			top.visited = true
+			if top.node.final {
+				return string(it.key), top.node.tails, true
+			}
+		}
+		if top.arc >= len(top.node.arcs) {
+			it.stack = it.stack[:len(it.stack)-1]
+			if len(it.stack) > 0 {
+				it.key = it.key[:len(it.key)-1]
+			}
+			continue
+		}
+		a := top.node.arcs[top.arc]
+		top.arc++
+		it.key = append(it.key, a.ch)
+		it.stack = append(it.stack, frame{node: it.vm.node(a.next)})
+	}
+	return "", nil, false
+}
+
+// prefix returns an iterator over the keys which start with the given prefix.
+func (vm FstVM) prefix(prefix string) *Iterator {
+	n := vm.node(0)
+	for i := 0; i < len(prefix); i++ {
+		var ok bool
+		for _, a := range n.arcs {
+			if a.ch != prefix[i] {
+				continue
+			}
+			n, ok = vm.node(a.next), true
+			break
+		}
+		if !ok {
+			return &Iterator{vm: vm}
+		}
+	}
+	return &Iterator{
+		vm:    vm,
+		key:   []byte(prefix),
+		stack: []frame{{node: n}},
+	}
+}
+
+// PredictiveSearch finds keywords which start with a given prefix and returns them and their outputs
+// in lexicographic order. If limit > 0, at most limit keywords are returned.
+func (vm FstVM) PredictiveSearch(prefix string, limit int) (keys []string, outputs [][]int) {
+	it := vm.prefix(prefix)
+	for limit <= 0 || len(keys) < limit {
+		key, outs, ok := it.Next()
+		if !ok {
+			break
+		}
+		keys = append(keys, key)
+		outputs = append(outputs, outs)
+	}
+	return
+}
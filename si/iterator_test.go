@@ -0,0 +1,116 @@
+package si
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIteratorNext01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", 28},
+		{"feb", 29},
+		{"apr", 30},
+		{"jan", 31},
+		{"jun", 30},
+		{"jul", 31},
+		{"dec", 31},
+		{"july", 7},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	type entry struct {
+		key  string
+		outs []int
+	}
+	var got []entry
+	it := vm.All()
+	for {
+		key, outs, ok := it.Next()
+		if !ok {
+			break
+		}
+		sort.Ints(outs)
+		got = append(got, entry{key, outs})
+	}
+	exp := []entry{
+		{"apr", []int{30}},
+		{"dec", []int{31}},
+		{"feb", []int{28, 29}},
+		{"jan", []int{31}},
+		{"jul", []int{31}},
+		{"july", []int{7}},
+		{"jun", []int{30}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v\n", got, exp)
+	}
+}
+
+func TestIteratorNext02(t *testing.T) {
+	vm, e := Build(PairSlice{})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	if key, outs, ok := vm.All().Next(); ok {
+		t.Errorf("unexpected key: %v, %v\n", key, outs)
+	}
+}
+
+func TestFstVMSearchFinalLeaf01(t *testing.T) {
+	inp := PairSlice{
+		{"ab", 1},
+		{"cd", 2},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	for _, in := range []string{"abd", "cdb", "abab"} {
+		if outs := vm.Search(in); outs != nil {
+			t.Errorf("input: %v, got %v, expected nil\n", in, outs)
+		}
+	}
+}
+
+func TestFstVMPredictiveSearch01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", 28},
+		{"feb", 29},
+		{"apr", 30},
+		{"jan", 31},
+		{"jun", 30},
+		{"jul", 31},
+		{"ju", 3},
+		{"july", 7},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	testdata := []struct {
+		prefix string
+		limit  int
+		keys   []string
+		outs   [][]int
+	}{
+		{prefix: "ju", keys: []string{"ju", "jul", "july", "jun"}, outs: [][]int{{3}, {31}, {7}, {30}}},
+		{prefix: "ju", limit: 2, keys: []string{"ju", "jul"}, outs: [][]int{{3}, {31}}},
+		{prefix: "f", keys: []string{"feb"}, outs: [][]int{{28, 29}}},
+		{prefix: "july", keys: []string{"july"}, outs: [][]int{{7}}},
+		{prefix: "julyx"},
+		{prefix: "x"},
+		{prefix: "", limit: 1, keys: []string{"apr"}, outs: [][]int{{30}}},
+	}
+	for _, d := range testdata {
+		keys, outs := vm.PredictiveSearch(d.prefix, d.limit)
+		for _, v := range outs {
+			sort.Ints(v)
+		}
+		if !reflect.DeepEqual(keys, d.keys) || !reflect.DeepEqual(outs, d.outs) {
+			t.Errorf("prefix %q: got %v %v, expected %v %v\n", d.prefix, keys, outs, d.keys, d.outs)
+		}
+	}
+}
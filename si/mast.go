@@ -21,9 +21,25 @@ func (m *mast) addState(n *state) {
 	}
 }
 
-// Build returns a virtual machine of a finite state transducer.
+// Order represents the order of the outputs of an input.
+type Order int
+
+const (
+	// Sorted orders the outputs of an input in ascending order.
+	Sorted Order = iota
+	// InsertionOrder orders the outputs of an input in the order of the given pairs.
+	InsertionOrder
+)
+
+// Build returns a virtual machine of a finite state transducer, the outputs of an input are sorted.
 func Build(input PairSlice) (vm FstVM, err error) {
-	m := buildMast(input)
+	return BuildWithOrder(input, Sorted)
+}
+
+// BuildWithOrder returns a virtual machine of a finite state transducer which returns the outputs
+// of an input in the given order.
+func BuildWithOrder(input PairSlice, order Order) (vm FstVM, err error) {
+	m := buildMast(input, order)
 	return m.compile()
 }
 
@@ -39,78 +55,15 @@ func commonPrefixLen(a, b string) int {
 	return i
 }
 
-func buildMast(input PairSlice) (m *mast) { //XXX TODO private
-	sort.Sort(input)
-	//fmt.Println("sorted---") //XXX
-	const initialMastSize = 1024
-	m = new(mast)
-	dic := make(map[uint][]*state)
-	m.states = make([]*state, 0, initialMastSize)
-	m.finalStates = make([]*state, 0, initialMastSize)
-
-	buf := make([]*state, input.maxInputWordLen()+1)
-	for i := range buf {
-		buf[i] = newState()
-	}
-	prev := ""
+func buildMast(input PairSlice, order Order) (m *mast) {
+	sort.Stable(input)
+	r := newMastRegister()
+	b := builder{reg: r, order: order}
 	for _, pair := range input {
-		//fmt.Println(pair) //XXX
-		in, out := pair.In, pair.Out
-		prefixLen := commonPrefixLen(in, prev)
-		for i := len(prev); i > prefixLen; i-- {
-			var s *state
-			if cs, ok := dic[buf[i].hcode]; ok {
-				for _, c := range cs {
-					if c.eq(buf[i]) {
-						s = c
-						break
-					}
-				}
-			}
-			if s == nil {
-				s = &state{}
-				*s = *buf[i]
-				buf[i].renew()
-				m.addState(s)
-				dic[s.hcode] = append(dic[s.hcode], s)
-			}
-			buf[i-1].setTransition(prev[i-1], s)
-			s.setInvTransition()
-		}
-		for i, size := prefixLen+1, len(in); i <= size; i++ {
-			buf[i-1].setTransition(in[i-1], buf[i])
-		}
-		if in != prev {
-			buf[len(in)].IsFinal = true
-		}
-		buf[len(in)].addTail(out)
-		prev = in
-	}
-	// flush the buf
-	for i := len(prev); i > 0; i-- {
-		var s *state
-		if cs, ok := dic[buf[i].hcode]; ok {
-			for _, c := range cs {
-				if c.eq(buf[i]) {
-					s = c
-					break
-				}
-			}
-		}
-		if s == nil {
-			s = &state{}
-			*s = *buf[i]
-			buf[i].renew()
-			m.addState(s)
-			dic[s.hcode] = append(dic[s.hcode], s)
-		}
-		buf[i-1].setTransition(prev[i-1], s)
-		s.setInvTransition()
+		b.add(pair.In, pair.Out)
 	}
-	m.initialState = buf[0]
-	m.addState(buf[0])
-
-	return
+	b.flush()
+	return r.m
 }
 
 func (m *mast) run(input string) (out []int, ok bool) {
@@ -158,74 +111,86 @@ func (p byteSlice) Len() int           { return len(p) }
 func (p byteSlice) Less(i, j int) bool { return p[i] < p[j] }
 func (p byteSlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
-func toBytes(x int) []byte {
-	var (
-		b   byte
-		buf []byte
-	)
-	for x >= 256 {
-		b = byte(x % 256)
-		x >>= 8
-		buf = append(buf, b)
+func (m *mast) compile() (vm FstVM, err error) {
+	c := new(compiler)
+	for _, s := range m.states {
+		if err = c.compile(s); err != nil {
+			return
+		}
 	}
-	buf = append(buf, byte(x))
-	return buf
+	vm.prog = invert(c.prog)
+	vm.data = c.data
+	return
 }
 
-func (m *mast) compile() (vm FstVM, err error) {
-	var tape []int
-	var edges []byte
-	addrMap := make(map[int]int)
-	for _, s := range m.states {
-		edges = edges[:0]
-		for ch := range s.Trans {
-			edges = append(edges, ch)
+// compiler compiles states into a program built in reverse order.
+type compiler struct {
+	prog     []byte
+	data     []int
+	progBase int // number of bytes already spilled out of prog
+	dataBase int // number of outputs already spilled out of data
+	edges    []byte
+}
+
+func (c *compiler) progLen() int {
+	return c.progBase + len(c.prog)
+}
+
+func (c *compiler) dataLen() int {
+	return c.dataBase + len(c.data)
+}
+
+// compile appends the codes of a state and sets the address of them to the state. The codes of
+// the states reachable from the state must be compiled in advance.
+func (c *compiler) compile(s *state) (err error) {
+	edges := c.edges[:0]
+	for ch := range s.Trans {
+		edges = append(edges, ch)
+	}
+	if len(edges) > 0 {
+		sort.Sort(byteSlice(edges))
+	}
+	c.edges = edges
+	for i, size := 0, len(edges); i < size; i++ {
+		inp := edges[size-1-i]
+		next := s.Trans[inp]
+		if next.addr == 0 && !next.IsFinal {
+			return fmt.Errorf("next addr is undefined: state(%v), input(%X)", s.ID, inp)
 		}
-		if len(edges) > 0 {
-			sort.Sort(byteSlice(edges))
+		var op instOp
+		if i == 0 {
+			op = instBreak
+		} else {
+			op = instMatch
 		}
-		for i, size := 0, len(edges); i < size; i++ {
-			inp := edges[size-1-i]
-			next := s.Trans[inp]
-			addr, ok := addrMap[next.ID]
-			if !ok && !next.IsFinal {
-				err = fmt.Errorf("next addr is undefined: state(%v), input(%X)", s.ID, inp)
-				return
-			}
-			var op instOp
-			if i == 0 {
-				op = instBreak
-			} else {
-				op = instMatch
+		inst := byte(op)
+		if jump := c.progLen() - next.addr; jump > 0 {
+			if c.prog, err = appendOperand(c.prog, jump); err != nil {
+				return fmt.Errorf("state(%v), input(%X): %v", s.ID, inp, err)
 			}
-			inst := byte(op)
-			jump := len(vm.prog) - addr
-			if jump > 1 {
-				dst := toBytes(jump)
-				inst |= byte(len(dst))
-				vm.prog = append(vm.prog, dst...)
-			}
-			vm.prog = append(vm.prog, inp)
-			vm.prog = append(vm.prog, inst)
+			inst |= operandFlag
+		}
+		c.prog = append(c.prog, inp)
+		c.prog = append(c.prog, inst)
+	}
+	if s.IsFinal {
+		inst := byte(instAccept)
+		if len(s.Trans) == 0 {
+			inst = byte(instAcceptBreak)
 		}
-		if s.IsFinal {
-			inst := byte(instAccept)
-			if len(s.Tail) > 0 {
-				dst1 := toBytes(len(tape))
-				inst |= byte(len(dst1))
-				for t := range s.Tail {
-					tape = append(tape, t)
-				}
-				dst2 := toBytes(len(tape))
-				vm.prog = append(vm.prog, dst2...)
-				vm.prog = append(vm.prog, byte(len(dst2)))
-				vm.prog = append(vm.prog, dst1...)
+		if len(s.Tail) > 0 {
+			start := c.dataLen()
+			c.data = append(c.data, s.order...)
+			if c.prog, err = appendOperand(c.prog, len(s.order)); err == nil {
+				c.prog, err = appendOperand(c.prog, start)
+			}
+			if err != nil {
+				return fmt.Errorf("state(%v): %v", s.ID, err)
 			}
-			vm.prog = append(vm.prog, inst)
+			inst |= operandFlag
 		}
-		addrMap[s.ID] = len(vm.prog)
+		c.prog = append(c.prog, inst)
 	}
-	vm.prog = invert(vm.prog)
-	vm.data = tape
+	s.addr = c.progLen()
 	return
 }
@@ -1,6 +1,7 @@
 package si
 
 import (
+	"math/rand"
 	"os"
 	"reflect"
 	"sort"
@@ -9,7 +10,7 @@ import (
 
 func TestBuildMast01(t *testing.T) {
 	inp := PairSlice{}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	if m.initialState.ID != 0 {
 		t.Errorf("got initial state id %v, expected 0\n", m.initialState.ID)
 	}
@@ -30,7 +31,7 @@ func TestAccept01(t *testing.T) {
 		{"112", 333},
 		{"211", 444},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	for _, pair := range inp {
 		if ok := m.accept(pair.In); !ok {
 			t.Errorf("expected: accept [%v]\n", pair.In)
@@ -50,7 +51,7 @@ func TestRun01(t *testing.T) {
 		{"113", 333},
 		{"211", 444},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	for _, pair := range inp {
 		out, ok := m.run(pair.In)
 		if !ok {
@@ -73,7 +74,7 @@ func TestMastRun02(t *testing.T) {
 		{"hello", 1},
 		{"hello", 2},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	for _, pair := range inp {
 		out, ok := m.run(pair.In)
 		if !ok {
@@ -99,25 +100,30 @@ func TestMastDot01(t *testing.T) {
 		{"1a111a", 1},
 		{"1b111b", 2},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	m.dot(os.Stdout)
 }
 
-func TestToBytes01(t *testing.T) {
+func TestAppendOperand01(t *testing.T) {
 	cr := []struct {
 		in  int
 		out []byte
 	}{
 		{7, []byte{7}},
-		{255, []byte{255}},
-		{256, []byte{0, 1}},
-		{512, []byte{0, 2}},
-		{1024, []byte{0, 4}},
-		{0xFFFFFF, []byte{255, 255, 255}},
+		{127, []byte{127}},
+		{128, []byte{0x01, 0x80}},
+		{300, []byte{0x02, 0xAC}},
+		{0xFFFFFF, []byte{0x07, 0xFF, 0xFF, 0xFF}},
+		{0x7FFFFFFF, []byte{0x07, 0xFF, 0xFF, 0xFF, 0xFF}},
 	}
 	for _, s := range cr {
-		if r := toBytes(s.in); !reflect.DeepEqual(s.out, r) {
-			t.Errorf("got %v, expected %v\n", r, s.out)
+		if r, e := appendOperand(nil, s.in); e != nil || !reflect.DeepEqual(s.out, r) {
+			t.Errorf("got %v, %v, expected %v\n", r, e, s.out)
+		}
+	}
+	for _, x := range []int{-1, maxOperand + 1} {
+		if _, e := appendOperand(nil, x); e == nil {
+			t.Errorf("%d: expected an error\n", x)
 		}
 	}
 }
@@ -127,7 +133,7 @@ func TestCompile01(t *testing.T) {
 		{"1a22xss", 1},
 		{"1b22yss", 2},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	_, e := m.compile()
 	if e != nil {
 		t.Errorf("unexpected error: %v", e)
@@ -139,7 +145,7 @@ func TestMastCompile02(t *testing.T) {
 		{"abc", 123},
 		{"abc", 456},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	vm, e := m.compile()
 	if e != nil {
 		t.Errorf("unexpected error: %v\n", e)
@@ -151,3 +157,38 @@ func TestMastCompile02(t *testing.T) {
 		t.Errorf("got %v, expected %v\n", outs, exp)
 	}
 }
+
+func TestMastCompile03(t *testing.T) {
+	inp := randomPairs(rand.New(rand.NewSource(1)), 150000, 16)
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	if len(vm.prog) < 1<<21 {
+		t.Fatalf("expected a program with 4-byte jumps, got %d bytes\n", len(vm.prog))
+	}
+	exp := map[string][]int{}
+	for _, p := range inp {
+		exp[p.In] = append(exp[p.In], p.Out)
+	}
+	for k, v := range exp {
+		outs := vm.Search(k)
+		sort.Ints(outs)
+		sort.Ints(v)
+		if !reflect.DeepEqual(outs, v) {
+			t.Fatalf("%v: got %v, expected %v\n", k, outs, v)
+		}
+	}
+}
+
+func TestMastCompile04(t *testing.T) {
+	defer func(x int) { maxOperand = x }(maxOperand)
+	maxOperand = 1000
+	inp := randomPairs(rand.New(rand.NewSource(1)), 300, 16)
+	if _, e := Build(inp[:10]); e != nil {
+		t.Errorf("unexpected error: %v\n", e)
+	}
+	if _, e := Build(inp); e == nil {
+		t.Errorf("expected an error\n")
+	}
+}
@@ -0,0 +1,53 @@
+package si
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestScannerFindAll02(t *testing.T) {
+	vm, e := Build(PairSlice{{"he", 1}, {"she", 2}, {"his", 3}, {"hers", 4}, {"hers", 5}, {"東京", 6}, {"京都", 7}})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	s := NewScanner(vm)
+	text := "ushers 東京都"
+	if got, exp := s.FindAll(text), []Match{{1, 4, []int{2}}, {2, 4, []int{1}}, {2, 6, []int{4, 5}}, {7, 13, []int{6}}, {10, 16, []int{7}}}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v\n", got, exp)
+	}
+	var got []Match
+	s.FindAllFunc("she, hers 東京", WordBoundary, func(m Match) bool {
+		got = append(got, m)
+		return true
+	})
+	if exp := []Match{{0, 3, []int{2}}, {5, 9, []int{4, 5}}, {10, 16, []int{6}}}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v\n", got, exp)
+	}
+}
+
+func TestScannerFindAll01(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	vm, e := Build(randomPairs(r, 500, 3))
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	s := NewScanner(vm)
+	for i := 0; i < 20; i++ {
+		b := make([]byte, r.Intn(200))
+		for j := range b {
+			b[j] = byte('a' + r.Intn(26))
+		}
+		text := string(b)
+		var naive []Match
+		for i := range text {
+			lens, outs := vm.CommonPrefixSearch(text[i:])
+			for j := range lens {
+				naive = append(naive, Match{Start: i, End: i + lens[j], Outputs: outs[j]})
+			}
+		}
+		if got := s.FindAll(text); !reflect.DeepEqual(got, naive) {
+			t.Fatalf("text:%q, got %v, expected %v\n", text, got, naive)
+		}
+	}
+}
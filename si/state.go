@@ -1,7 +1,9 @@
 package si
 
 import (
+	"encoding/binary"
 	"fmt"
+	"sort"
 )
 
 type intSet map[int]bool
@@ -10,9 +12,11 @@ type state struct {
 	ID      int
 	Trans   map[byte]*state
 	Tail    intSet
+	order   []int // tails in the order of addition
 	IsFinal bool
 	Prev    []*state
 	hcode   uint
+	addr    int // address of the compiled codes, 0 if not compiled yet
 }
 
 func newState() (n *state) {
@@ -27,18 +31,24 @@ func (n *state) hasTail() bool {
 }
 
 func (n *state) addTail(t int) {
+	if n.Tail[t] {
+		return
+	}
 	n.Tail[t] = true
+	n.order = append(n.order, t)
 
 	const magic = 117709
 	n.hcode += uint(t) * magic
 }
 
+// tails returns the tails in the order of addition.
 func (n *state) tails() (t []int) {
-	t = make([]int, 0, len(n.Tail))
-	for item := range n.Tail {
-		t = append(t, item)
-	}
-	return
+	return append([]int(nil), n.order...)
+}
+
+// sortTails sorts the tails in ascending order.
+func (n *state) sortTails() {
+	sort.Ints(n.order)
 }
 
 func (n *state) setTransition(ch byte, next *state) {
@@ -57,6 +67,7 @@ func (n *state) setInvTransition() {
 func (n *state) renew() {
 	n.Trans = make(map[byte]*state)
 	n.Tail = make(intSet)
+	n.order = nil
 	n.IsFinal = false
 	n.Prev = make([]*state, 0)
 	n.hcode = 0
@@ -71,7 +82,8 @@ func (n *state) eq(dst *state) bool {
 	}
 	if n.IsFinal != dst.IsFinal ||
 		len(n.Trans) != len(dst.Trans) ||
-		len(n.Tail) != len(dst.Tail) {
+		len(n.Tail) != len(dst.Tail) ||
+		len(n.order) != len(dst.order) {
 		return false
 	}
 	for ch, next := range n.Trans {
@@ -84,9 +96,40 @@ func (n *state) eq(dst *state) bool {
 			return false
 		}
 	}
+	for i := range n.order {
+		if n.order[i] != dst.order[i] {
+			return false
+		}
+	}
 	return true
 }
 
+// signature returns a string which identifies the state by its finality, its transitions to the
+// frozen states and its tails.
+func (n *state) signature() string {
+	edges := make([]byte, 0, len(n.Trans))
+	for ch := range n.Trans {
+		edges = append(edges, ch)
+	}
+	sort.Sort(byteSlice(edges))
+	var tmp [binary.MaxVarintLen64]byte
+	b := make([]byte, 0, 1+len(edges)*(1+binary.MaxVarintLen32)+len(n.order)*binary.MaxVarintLen32)
+	if n.IsFinal {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(edges)))]...)
+	for _, ch := range edges {
+		b = append(b, ch)
+		b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(n.Trans[ch].ID))]...)
+	}
+	for _, t := range n.order {
+		b = append(b, tmp[:binary.PutVarint(tmp[:], int64(t))]...)
+	}
+	return string(b)
+}
+
 // String returns a string representaion of a node for debug.
 func (n *state) String() string {
 	ret := ""
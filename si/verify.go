@@ -0,0 +1,67 @@
+package si
+
+import "fmt"
+
+// Verify checks that the program is well formed so that searches never fail on it: every operation
+// is defined and complete, every operand is within the limit, every state without edges breaks,
+// every range of outputs is in the data and every jump goes forward to the beginning of a state. Load verifies the loaded program.
+func (vm FstVM) Verify() error {
+	starts := make([]bool, len(vm.prog))
+	if err := vm.verify(starts, false); err != nil {
+		return err
+	}
+	return vm.verify(starts, true)
+}
+
+func invalidProgram(pc int, format string, a ...interface{}) error {
+	return fmt.Errorf("invalid program: pc %d: %s", pc, fmt.Sprintf(format, a...))
+}
+
+// verify parses the program and marks the beginnings of states, the jumps are checked if jumps is true.
+func (vm FstVM) verify(starts []bool, jumps bool) error {
+	for pc := 0; pc < len(vm.prog); {
+		starts[pc] = true
+		op := instOp(vm.prog[pc] & instMask)
+		if op == instAccept || op == instAcceptBreak {
+			if vm.prog[pc]&valMask != operandFlag {
+				return invalidProgram(pc, "%v without outputs", op)
+			}
+			s, e, next, ok := vm.accept(pc)
+			if !ok {
+				return invalidProgram(pc, "incomplete %v", op)
+			}
+			if s > e || e > len(vm.data) {
+				return invalidProgram(pc, "outputs [%d:%d] out of data", s, e)
+			}
+			pc = next
+			if op == instAcceptBreak {
+				continue
+			}
+		}
+		for last := false; !last; {
+			if pc >= len(vm.prog) {
+				return invalidProgram(pc, "missing break")
+			}
+			op = instOp(vm.prog[pc] & instMask)
+			switch op {
+			case instMatch:
+			case instBreak:
+				last = true
+			default:
+				return invalidProgram(pc, "unexpected operation %v", op)
+			}
+			if vm.prog[pc]&valMask&^operandFlag != 0 {
+				return invalidProgram(pc, "unexpected flags %X", vm.prog[pc]&valMask)
+			}
+			_, jump, end, ok := vm.edge(pc)
+			if !ok {
+				return invalidProgram(pc, "incomplete %v", op)
+			}
+			if to := end + jump; jumps && (to >= len(vm.prog) || !starts[to]) {
+				return invalidProgram(pc, "invalid jump to %d", to)
+			}
+			pc = end
+		}
+	}
+	return nil
+}
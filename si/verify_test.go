@@ -0,0 +1,103 @@
+package si
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func randomPairs(r *rand.Rand, n, maxLen int) PairSlice {
+	ps := make(PairSlice, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, r.Intn(maxLen)+1)
+		for j := range b {
+			b[j] = byte('a' + r.Intn(26))
+		}
+		ps = append(ps, Pair{In: string(b), Out: r.Intn(1 << 20)})
+	}
+	return ps
+}
+
+func TestFstVMVerify01(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 10, 1000, 30000} {
+		vm, e := Build(randomPairs(r, n, 12))
+		if e != nil {
+			t.Fatalf("unexpected error: %v\n", e)
+		}
+		if e := vm.Verify(); e != nil {
+			t.Errorf("%d pairs: unexpected error: %v\n", n, e)
+		}
+	}
+}
+
+func TestFstVMVerify02(t *testing.T) {
+	vm, e := Build(PairSlice{{"ab", 1}, {"cd", 2}})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	testdata := []struct {
+		name string
+		fn   func(vm *FstVM)
+	}{
+		{name: "truncated", fn: func(vm *FstVM) { vm.prog = vm.prog[:len(vm.prog)-1] }},
+		{name: "data out of range", fn: func(vm *FstVM) { vm.data = vm.data[:1] }},
+		{name: "jump out of program", fn: func(vm *FstVM) { vm.prog[2] = 0xFF }},
+		{name: "undefined operation", fn: func(vm *FstVM) { vm.prog[0] = byte(instAccept) }},
+	}
+	for _, d := range testdata {
+		c := FstVM{prog: append([]byte(nil), vm.prog...), data: append([]int(nil), vm.data...)}
+		d.fn(&c)
+		if e := c.Verify(); e == nil {
+			t.Errorf("%v: expected an error\n%v", d.name, c)
+		}
+	}
+}
+
+// exercise runs the searches which must not panic on a verified program.
+func exercise(vm FstVM) {
+	for _, in := range []string{"", "a", "ab", "abc", "b", "cd", "cdb", "\x00", "\xff\xff"} {
+		vm.Search(in)
+		vm.PrefixSearch(in)
+		vm.CommonPrefixSearch(in)
+		vm.PredictiveSearch(in, 10)
+	}
+	it := vm.All()
+	for i := 0; i < 100; i++ {
+		if _, _, ok := it.Next(); !ok {
+			break
+		}
+	}
+}
+
+func FuzzLoad(f *testing.F) {
+	vm, e := Build(PairSlice{{"ab", 1}, {"ab", 300}, {"cd", 2}})
+	if e != nil {
+		f.Fatalf("unexpected error: %v\n", e)
+	}
+	var b bytes.Buffer
+	vm.Save(&b)
+	f.Add(b.Bytes())
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var vm FstVM
+		if vm.Load(bytes.NewReader(b)) != nil {
+			return
+		}
+		exercise(vm)
+	})
+}
+
+func FuzzVerify(f *testing.F) {
+	vm, e := Build(PairSlice{{"ab", 1}, {"ab", 300}, {"cd", 2}})
+	if e != nil {
+		f.Fatalf("unexpected error: %v\n", e)
+	}
+	f.Add(vm.prog)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		vm := FstVM{prog: b, data: []int{1, 2, 3}}
+		if vm.Verify() != nil {
+			return
+		}
+		exercise(vm)
+	})
+}
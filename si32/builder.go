@@ -0,0 +1,205 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBuilderFinished is returned when a pair is added to a finished builder.
+var ErrBuilderFinished = errors.New("builder is already finished")
+
+// ErrEmptyInput is returned by Builder.Add for an empty input, which a transducer cannot accept.
+var ErrEmptyInput = errors.New("empty input")
+
+// OrderError is returned by Builder.Add when an input is not given in lexicographic order.
+type OrderError struct {
+	Prev string // the last input accepted by the builder
+	In   string // the rejected input
+}
+
+// Error returns a description of the order violation.
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("input %q is out of order, previous input is %q", e.In, e.Prev)
+}
+
+// register keeps the frozen states of a transducer under construction.
+type register interface {
+	// freeze returns a frozen state equivalent to a given state.
+	freeze(n *state) (*state, error)
+	// finish registers the initial state.
+	finish(initial *state) error
+}
+
+// mastRegister keeps all frozen states in memory to build a mast.
+type mastRegister struct {
+	m   mast
+	dic map[int64][]*state
+}
+
+func newMastRegister() *mastRegister {
+	const initialMASTSize = 1024
+	r := &mastRegister{
+		dic: make(map[int64][]*state),
+	}
+	r.m.states = make([]*state, 0, initialMASTSize)
+	r.m.finalStates = make([]*state, 0, initialMASTSize)
+	return r
+}
+
+func (r *mastRegister) freeze(n *state) (*state, error) {
+	if cs, ok := r.dic[n.hcode]; ok {
+		for _, c := range cs {
+			if c.eq(n) {
+				return c, nil
+			}
+		}
+	}
+	s := &state{}
+	*s = *n
+	r.m.addState(s)
+	r.dic[s.hcode] = append(r.dic[s.hcode], s)
+	return s, nil
+}
+
+func (r *mastRegister) finish(initial *state) error {
+	r.m.initialState = initial
+	r.m.addState(initial)
+	return nil
+}
+
+// builder implements the construction of a mast from pairs sorted by input.
+type builder struct {
+	reg   register
+	buf   []*state
+	prev  string
+	order Order
+}
+
+// freeze freezes a state of the buffer, the tails are sorted unless the insertion order is kept.
+func (b *builder) freeze(n *state) (*state, error) {
+	if b.order == Sorted {
+		n.sortTails()
+	}
+	return b.reg.freeze(n)
+}
+
+func (b *builder) add(in string, out int32) error {
+	for len(b.buf) <= len(in) {
+		b.buf = append(b.buf, newState())
+	}
+	buf, prev := b.buf, b.prev
+	fZero := (out == 0) // flag
+	prefixLen := len(commonPrefix(in, prev))
+	for i := len(prev); i > prefixLen; i-- {
+		s, err := b.freeze(buf[i])
+		if err != nil {
+			return err
+		}
+		buf[i].renew()
+		buf[i-1].setTransition(prev[i-1], s)
+	}
+	for i, size := prefixLen+1, len(in); i <= size; i++ {
+		buf[i-1].setTransition(in[i-1], buf[i])
+	}
+	if in != prev {
+		buf[len(in)].IsFinal = true
+	}
+	var shared bool // the output is already emitted by an edge of the common prefix
+	for j := 1; j < prefixLen+1; j++ {
+		outSuff, ok := buf[j-1].Output[in[j-1]]
+		if ok {
+			if outSuff == out {
+				out = 0
+				shared = true
+				break
+			}
+			buf[j-1].removeOutput(in[j-1]) // clear the prev edge
+			for ch := range buf[j].Trans {
+				buf[j].setOutput(ch, outSuff)
+			}
+			if buf[j].IsFinal {
+				buf[j].addTail(outSuff)
+			}
+		}
+	}
+	if in != prev {
+		if !shared {
+			buf[prefixLen].setOutput(in[prefixLen], out)
+		}
+	} else if fZero || out != 0 {
+		buf[len(in)].addTail(out)
+	}
+	b.prev = in
+	return nil
+}
+
+func (b *builder) flush() error {
+	if len(b.buf) == 0 {
+		b.buf = append(b.buf, newState())
+	}
+	for i := len(b.prev); i > 0; i-- {
+		s, err := b.freeze(b.buf[i])
+		if err != nil {
+			return err
+		}
+		b.buf[i-1].setTransition(b.prev[i-1], s)
+	}
+	if b.order == Sorted {
+		b.buf[0].sortTails()
+	}
+	return b.reg.finish(b.buf[0])
+}
+
+// Builder constructs a finite state transducer incrementally from pairs sorted by input.
+// The suffix states are frozen as soon as they are no longer reachable from the last input,
+// so the pairs never have to be kept in memory.
+type Builder struct {
+	builder
+	mast     *mastRegister
+	finished bool
+}
+
+// NewBuilder returns a new builder of a finite state transducer.
+func NewBuilder() *Builder {
+	r := newMastRegister()
+	return &Builder{
+		builder: builder{reg: r},
+		mast:    r,
+	}
+}
+
+// Add appends a pair of input and output. Inputs must be added in lexicographic (byte) order,
+// outputs of the same input may be added in any order.
+func (b *Builder) Add(in string, out int32) error {
+	if b.finished {
+		return ErrBuilderFinished
+	}
+	if in == "" {
+		return ErrEmptyInput
+	}
+	if in < b.prev {
+		return &OrderError{Prev: b.prev, In: in}
+	}
+	return b.add(in, out)
+}
+
+// Finish freezes the remaining states and returns the compiled finite state transducer.
+func (b *Builder) Finish() (FST, error) {
+	if b.finished {
+		return FST{}, ErrBuilderFinished
+	}
+	b.finished = true
+	if err := b.flush(); err != nil {
+		return FST{}, err
+	}
+	return b.mast.m.buildMachine()
+}
@@ -0,0 +1,282 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestBuilderAdd01(t *testing.T) {
+	inp := PairSlice{
+		{"apr", 30},
+		{"aug", 31},
+		{"dec", 31},
+		{"feb", 28},
+		{"feb", 29},
+		{"jan", 31},
+		{"jul", 31},
+		{"jun", 30},
+	}
+	b := NewBuilder()
+	for _, p := range inp {
+		if err := b.Add(p.In, p.Out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	fst, err := b.Finish()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	org, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fst, org) {
+		t.Errorf("got %v, expected %v", fst, org)
+	}
+	crs := []struct {
+		in  string
+		out []int32
+	}{
+		{"apr", []int32{30}},
+		{"feb", []int32{28, 29}},
+		{"jun", []int32{30}},
+		{"ju", nil},
+		{"mar", nil},
+	}
+	for _, cr := range crs {
+		outs := fst.Search(cr.in)
+		sort.Sort(int32Slice(outs))
+		if !reflect.DeepEqual(outs, cr.out) {
+			t.Errorf("input:%v, got %v, expected %v", cr.in, outs, cr.out)
+		}
+	}
+}
+
+func TestBuilderAdd02(t *testing.T) {
+	b := NewBuilder()
+	if err := b.Add("feb", 28); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Add("feb", 29); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := b.Add("dec", 31)
+	oe, ok := err.(*OrderError)
+	if !ok {
+		t.Fatalf("got %v, expected *OrderError", err)
+	}
+	if oe.Prev != "feb" || oe.In != "dec" {
+		t.Errorf("got %+v, expected {Prev:feb In:dec}", oe)
+	}
+	if err := b.Add("jan", 31); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fst, err := b.Finish()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outs := fst.Search("dec"); outs != nil {
+		t.Errorf("input:dec, got %v, expected nil", outs)
+	}
+	if err := b.Add("jun", 30); err != ErrBuilderFinished {
+		t.Errorf("got %v, expected %v", err, ErrBuilderFinished)
+	}
+	if _, err := b.Finish(); err != ErrBuilderFinished {
+		t.Errorf("got %v, expected %v", err, ErrBuilderFinished)
+	}
+}
+
+func TestBuilderSharedOutput01(t *testing.T) {
+	inp := PairSlice{
+		{"a", 5},
+		{"ab", 5},
+		{"ac", 5},
+		{"aca", 2},
+		{"b", 0},
+		{"bc", 0},
+		{"c", 0},
+		{"c", 3},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crs := []struct {
+		in  string
+		out []int32
+	}{
+		{"a", []int32{5}},
+		{"ab", []int32{5}},
+		{"ac", []int32{5}},
+		{"aca", []int32{2}},
+		{"b", []int32{0}},
+		{"bc", []int32{0}},
+		{"c", []int32{0, 3}},
+	}
+	for _, cr := range crs {
+		outs := fst.Search(cr.in)
+		sort.Sort(int32Slice(outs))
+		if !reflect.DeepEqual(outs, cr.out) {
+			t.Errorf("input:%v, got %v, expected %v", cr.in, outs, cr.out)
+		}
+	}
+}
+
+func TestBuilderEmpty01(t *testing.T) {
+	fst, err := NewBuilder().Finish()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outs := fst.Search("a"); outs != nil {
+		t.Errorf("got %v, expected nil", outs)
+	}
+}
+
+func TestBuilderEmpty02(t *testing.T) {
+	b := NewBuilder()
+	if err := b.Add("", 1); err != ErrEmptyInput {
+		t.Errorf("got %v, expected %v", err, ErrEmptyInput)
+	}
+	if err := b.Add("a", 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fst, err := b.Finish()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outs := fst.Search(""); outs != nil {
+		t.Errorf("got %v, expected nil", outs)
+	}
+}
+
+// legacyBuildMAST is buildMAST before the builder, which emits the zero output of an edge and
+// does not keep a zero output as a tail.
+func legacyBuildMAST(input PairSlice) (m mast) {
+	sort.Sort(input)
+	dic := make(map[int64][]*state)
+	var buf []*state
+	freeze := func(n *state) *state {
+		for _, c := range dic[n.hcode] {
+			if c.eq(n) {
+				return c
+			}
+		}
+		s := &state{}
+		*s = *n
+		m.addState(s)
+		dic[s.hcode] = append(dic[s.hcode], s)
+		return s
+	}
+	prev := ""
+	for _, pair := range input {
+		in, out := pair.In, pair.Out
+		for len(buf) <= len(in) {
+			buf = append(buf, newState())
+		}
+		fZero := (out == 0) // flag
+		prefixLen := len(commonPrefix(in, prev))
+		for i := len(prev); i > prefixLen; i-- {
+			s := freeze(buf[i])
+			buf[i].renew()
+			buf[i-1].setTransition(prev[i-1], s)
+		}
+		for i, size := prefixLen+1, len(in); i <= size; i++ {
+			buf[i-1].setTransition(in[i-1], buf[i])
+		}
+		if in != prev {
+			buf[len(in)].IsFinal = true
+		}
+		for j := 1; j < prefixLen+1; j++ {
+			outSuff, ok := buf[j-1].Output[in[j-1]]
+			if ok {
+				if outSuff == out {
+					out = 0
+					break
+				}
+				buf[j-1].removeOutput(in[j-1]) // clear the prev edge
+				for ch := range buf[j].Trans {
+					buf[j].setOutput(ch, outSuff)
+				}
+				if buf[j].IsFinal && outSuff != 0 {
+					buf[j].addTail(outSuff)
+				}
+			}
+		}
+		if in != prev {
+			buf[prefixLen].setOutput(in[prefixLen], out)
+		} else if fZero || out != 0 {
+			buf[len(in)].addTail(out)
+		}
+		prev = in
+	}
+	if len(buf) == 0 {
+		buf = append(buf, newState())
+	}
+	for i := len(prev); i > 0; i-- {
+		buf[i-1].setTransition(prev[i-1], freeze(buf[i]))
+	}
+	m.initialState = buf[0]
+	m.addState(buf[0])
+	return
+}
+
+func TestBuilderLegacy01(t *testing.T) {
+	// the programs agree unless a key repeats the output of its prefix or has the zero output.
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 10, 1000, 10000} {
+		var inp PairSlice
+		seen := map[string]bool{}
+		for len(inp) < n {
+			b := make([]byte, r.Intn(8)+1)
+			for j := range b {
+				b[j] = byte('a' + r.Intn(4))
+			}
+			if seen[string(b)] {
+				continue
+			}
+			seen[string(b)] = true
+			inp = append(inp, Pair{In: string(b), Out: int32(len(inp) + 1)})
+		}
+		fst, err := Build(append(PairSlice(nil), inp...))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		legacy, err := legacyBuildMAST(inp).buildMachine()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(fst.prog, legacy.prog) || !reflect.DeepEqual(fst.data, legacy.data) {
+			t.Fatalf("%d pairs: got\n%v\nexpected\n%v", n, fst, legacy)
+		}
+	}
+}
+
+func TestBuilderLegacy02(t *testing.T) {
+	// the legacy build lost the output of "ab", it emitted the zero output on the edge 'b'.
+	inp := PairSlice{{"a", 5}, {"ab", 5}}
+	legacy, err := legacyBuildMAST(append(PairSlice(nil), inp...)).buildMachine()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := legacy.Search("ab"); !reflect.DeepEqual(got, []int32{0}) {
+		t.Errorf("legacy: got %v, expected [0]", got)
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fst.Search("ab"); !reflect.DeepEqual(got, []int32{5}) {
+		t.Errorf("got %v, expected [5]", got)
+	}
+}
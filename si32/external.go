@@ -0,0 +1,280 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strconv"
+
+	"github.com/ikawaha/mast/container"
+	"github.com/ikawaha/mast/internal/extsort"
+)
+
+// DefaultChunkSize is the number of pairs sorted in memory at once by BuildExternal.
+const DefaultChunkSize = 1 << 20
+
+// DefaultRegisterSize is the number of frozen states kept for minimization by BuildExternal.
+const DefaultRegisterSize = 1 << 20
+
+// spillSize is the number of instructions kept in memory before they are spilled out to a file.
+const spillSize = 1 << 16
+
+// ExternalConfig represents a configuration of the external memory build.
+type ExternalConfig struct {
+	TempDir      string // directory for temporary files, the default directory if empty
+	ChunkSize    int    // number of pairs sorted in memory at once, DefaultChunkSize if zero
+	RegisterSize int    // number of frozen states kept for minimization, DefaultRegisterSize if zero, unlimited if negative
+	PerfectHash  bool   // store the numbers of the keys for Index and Key as BuildPerfectHash
+}
+
+// ExternalStats represents statistics of an external memory build.
+type ExternalStats struct {
+	Written int64 // number of bytes written
+	Pairs   int   // number of pairs read
+	States  int   // number of compiled states
+	Clears  int   // number of times the register was cleared since it reached cfg.RegisterSize
+}
+
+// Minimal reports whether the transducer built is minimal. It is not if the register was cleared,
+// because equivalent states frozen before and after a clear are compiled twice.
+func (s ExternalStats) Minimal() bool {
+	return s.Clears == 0
+}
+
+// BuildExternal constructs a finite state transducer from lines of tab separated input and output
+// read from r, and writes it to w in the format of FST.WriteTo. The pairs are sorted by external
+// merge sort and the compiled program is spilled out to temporary files as states are frozen.
+// A frozen state is kept only by its signature and its address in the register, which is
+// bounded by cfg.RegisterSize, so the memory does not grow with the input. If the register is
+// full, it is cleared and the result may not be minimal; this is reported by the returned stats.
+func BuildExternal(w io.Writer, r io.Reader, cfg ExternalConfig) (stats ExternalStats, err error) {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = DefaultChunkSize
+	}
+	if cfg.RegisterSize == 0 {
+		cfg.RegisterSize = DefaultRegisterSize
+	}
+	reg, err := newSpillRegister(cfg.TempDir, cfg.RegisterSize)
+	if err != nil {
+		return
+	}
+	defer reg.close()
+	reg.c.counts = cfg.PerfectHash
+
+	b := builder{reg: reg}
+	if err = extsort.Sort(r, cfg.TempDir, cfg.ChunkSize, pairCodec, func(p Pair) error {
+		stats.Pairs++
+		return b.add(p.In, p.Out)
+	}); err != nil {
+		return
+	}
+	if err = b.flush(); err != nil {
+		return
+	}
+	stats.States, stats.Clears = reg.ids, reg.clears
+	stats.Written, err = reg.writeTo(w)
+	return
+}
+
+// pairCodec orders the pairs as PairSlice and stores them in the temporary files.
+var pairCodec = extsort.Codec[Pair]{
+	Parse: func(line string) (p Pair, err error) {
+		in, out, err := extsort.Split(line)
+		if err != nil {
+			return p, err
+		}
+		v, err := strconv.ParseInt(out, 10, 32)
+		return Pair{In: in, Out: int32(v)}, err
+	},
+	Less: func(a, b Pair) bool {
+		return PairSlice{a, b}.Less(0, 1)
+	},
+	Append: func(b []byte, p Pair) []byte {
+		var tmp [binary.MaxVarintLen64]byte
+		b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(p.In)))]...)
+		b = append(b, p.In...)
+		return append(b, tmp[:binary.PutVarint(tmp[:], int64(p.Out))]...)
+	},
+	Read: func(r *bufio.Reader) (p Pair, err error) {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return
+		}
+		in := make([]byte, size)
+		if _, err = io.ReadFull(r, in); err != nil {
+			return
+		}
+		out, err := binary.ReadVarint(r)
+		return Pair{In: string(in), Out: int32(out)}, err
+	},
+}
+
+// spillRegister compiles states as soon as they are frozen and spills the program out to
+// temporary files. Frozen states are identified by their signatures, and kept as stubs which
+// have only the addresses of their codes, so they are released after compilation.
+type spillRegister struct {
+	sigs   map[string]*state
+	size   int
+	ids    int
+	clears int // number of times sigs was cleared
+	c      *compiler
+	prog   *extsort.File
+	data   *extsort.File
+}
+
+func newSpillRegister(dir string, size int) (r *spillRegister, err error) {
+	r = &spillRegister{
+		sigs: make(map[string]*state),
+		size: size,
+		c:    newCompiler(),
+	}
+	if r.prog, err = extsort.Create(dir, "mast-prog-"); err != nil {
+		return
+	}
+	if r.data, err = extsort.Create(dir, "mast-data-"); err != nil {
+		r.close()
+		return
+	}
+	return
+}
+
+func (r *spillRegister) close() {
+	if r.prog != nil {
+		r.prog.Remove()
+	}
+	if r.data != nil {
+		r.data.Remove()
+	}
+}
+
+// compile compiles a copy of the state and returns the stub of it.
+func (r *spillRegister) compile(n *state) (*state, error) {
+	s := *n
+	s.ID = r.ids
+	r.ids++
+	if err := r.c.compile(&s); err != nil {
+		return nil, err
+	}
+	stub := &state{ID: s.ID, IsFinal: s.IsFinal, addr: s.addr, keys: s.keys}
+	return stub, r.spill(spillSize)
+}
+
+func (r *spillRegister) freeze(n *state) (*state, error) {
+	sig := n.signature()
+	if s, ok := r.sigs[sig]; ok {
+		return s, nil
+	}
+	s, err := r.compile(n)
+	if err != nil {
+		return nil, err
+	}
+	if r.size > 0 && len(r.sigs) >= r.size {
+		r.sigs = make(map[string]*state)
+		r.clears++
+	}
+	r.sigs[sig] = s
+	return s, nil
+}
+
+func (r *spillRegister) finish(initial *state) error {
+	if _, err := r.compile(initial); err != nil {
+		return err
+	}
+	if err := r.spill(0); err != nil {
+		return err
+	}
+	if err := r.prog.Flush(); err != nil {
+		return err
+	}
+	return r.data.Flush()
+}
+
+// spill writes out the compiled codes if more than limit instructions are kept in memory.
+func (r *spillRegister) spill(limit int) error {
+	if len(r.c.prog) < limit {
+		return nil
+	}
+	for _, code := range r.c.prog {
+		if _, err := r.prog.Write(code[:]); err != nil {
+			return err
+		}
+	}
+	r.c.progBase += len(r.c.prog)
+	r.c.prog = r.c.prog[:0]
+
+	var tmp [4]byte
+	for _, v := range r.c.data {
+		binary.LittleEndian.PutUint32(tmp[:], uint32(v))
+		if _, err := r.data.Write(tmp[:]); err != nil {
+			return err
+		}
+	}
+	r.c.dataBase += len(r.c.data)
+	r.c.data = r.c.data[:0]
+	return nil
+}
+
+func (r *spillRegister) writeTo(w io.Writer) (n int64, err error) {
+	progLen := int64(r.c.progBase)
+	cw, err := container.NewWriter(w, container.Header{
+		Kind:     container.KindSI32,
+		Sections: []int64{int64(r.c.dataBase), progLen},
+	})
+	if err != nil {
+		return
+	}
+	defer func() { n = cw.Len() }()
+	data, err := r.data.Reader()
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(cw, data); err != nil {
+		return
+	}
+	if _, err = writeProg(cw, &reverseProgReader{
+		r:   r.prog,
+		off: progLen * int64(len(instruction{})),
+		buf: make([]byte, spillSize*len(instruction{})),
+	}); err != nil {
+		return
+	}
+	err = cw.Close()
+	return
+}
+
+// reverseProgReader reads the instructions spilled out to a file in the reverse order.
+type reverseProgReader struct {
+	r   io.ReaderAt
+	off int64 // offset of the instructions not read yet
+	buf []byte
+	pos int
+}
+
+func (r *reverseProgReader) next() (code instruction, err error) {
+	if r.pos == 0 {
+		if r.off == 0 {
+			return code, io.EOF
+		}
+		size := int64(len(r.buf))
+		if size > r.off {
+			size = r.off
+		}
+		r.off -= size
+		if n, e := r.r.ReadAt(r.buf[:size], r.off); int64(n) < size {
+			return code, e
+		}
+		r.pos = int(size)
+	}
+	r.pos -= len(code)
+	copy(code[:], r.buf[r.pos:])
+	return
+}
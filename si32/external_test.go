@@ -0,0 +1,121 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBuildExternal01(t *testing.T) {
+	inp := PairSlice{
+		{"jun", 30},
+		{"feb", 29},
+		{"apr", 30},
+		{"jan", 31},
+		{"feb", 28},
+		{"jul", 31},
+		{"dec", 31},
+	}
+	var src bytes.Buffer
+	for _, p := range inp {
+		fmt.Fprintf(&src, "%s\t%d\n", p.In, p.Out)
+	}
+	dir := t.TempDir()
+	var got bytes.Buffer
+	stats, err := BuildExternal(&got, &src, ExternalConfig{TempDir: dir, ChunkSize: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Written != int64(got.Len()) {
+		t.Errorf("write len: got %v, expected %v", stats.Written, got.Len())
+	}
+	if stats.Pairs != len(inp) {
+		t.Errorf("pairs: got %v, expected %v", stats.Pairs, len(inp))
+	}
+	if !stats.Minimal() {
+		t.Errorf("expected minimal, got %+v", stats)
+	}
+
+	org, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var exp bytes.Buffer
+	if _, err := org.WriteTo(&exp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), exp.Bytes()) {
+		t.Errorf("got %v, expected %v", got.Bytes(), exp.Bytes())
+	}
+	if fs, _ := os.ReadDir(dir); len(fs) != 0 {
+		t.Errorf("temporary files are left: %v", fs)
+	}
+}
+
+func TestBuildExternal02(t *testing.T) {
+	const size = 30000
+	var src bytes.Buffer
+	for i := size - 1; i >= 0; i-- {
+		fmt.Fprintf(&src, "%x\t%d\n", i*7919, i)
+	}
+	for _, rs := range []int{0, -1, 128} {
+		var b bytes.Buffer
+		stats, err := BuildExternal(&b, bytes.NewReader(src.Bytes()), ExternalConfig{
+			TempDir:      t.TempDir(),
+			ChunkSize:    1000,
+			RegisterSize: rs,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if minimal := rs <= 0; stats.Minimal() != minimal {
+			t.Errorf("register size:%v, got %+v, expected minimal=%v", rs, stats, minimal)
+		}
+		fst, err := Read(&b)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for i := 0; i < size; i++ {
+			in := fmt.Sprintf("%x", i*7919)
+			if outs := fst.Search(in); !reflect.DeepEqual(outs, []int32{int32(i)}) {
+				t.Fatalf("register size:%v, input:%v, got %v, expected %v", rs, in, outs, []int32{int32(i)})
+			}
+		}
+	}
+}
+
+func TestBuildExternal03(t *testing.T) {
+	src := "feb\t28\nfeb\t29\n\ndec\t31\n"
+	var b bytes.Buffer
+	if _, err := BuildExternal(&b, strings.NewReader(src), ExternalConfig{TempDir: t.TempDir()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fst, err := Read(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	outs := fst.Search("feb")
+	sort.Sort(int32Slice(outs))
+	if !reflect.DeepEqual(outs, []int32{28, 29}) {
+		t.Errorf("got %v, expected [28 29]", outs)
+	}
+
+	for _, src := range []string{"feb 28\n", "feb\tXX\n", "feb\t4294967296\n"} {
+		if _, err := BuildExternal(&b, strings.NewReader(src), ExternalConfig{TempDir: t.TempDir()}); err == nil {
+			t.Errorf("input:%q, expected error", src)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import "unicode/utf8"
+
+// levenshtein returns the next row of the edit distance table of the query after reading the rune r.
+func levenshtein(row []int, query []rune, r rune) []int {
+	next := make([]int, len(row))
+	next[0] = row[0] + 1
+	for i, q := range query {
+		cost := 1
+		if q == r {
+			cost = 0
+		}
+		next[i+1] = min3(row[i+1]+1, next[i]+1, row[i]+cost)
+	}
+	return next
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func minInt(row []int) int {
+	m := row[0]
+	for _, v := range row[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// FuzzySearch finds keywords within maxEdits edits (insertions, deletions or substitutions of a rune)
+// of the query and returns them, their edit distances and outputs in lexicographic order.
+// Keywords and the query are compared as UTF-8 rune sequences.
+func (t FST) FuzzySearch(query string, maxEdits int) (keys []string, dists []int, outputs [][]int32) {
+	if maxEdits < 0 {
+		return
+	}
+	q := []rune(query)
+	row := make([]int, len(q)+1)
+	for i := range row {
+		row[i] = i
+	}
+	var key []byte
+	var walk func(n node, out int32, row []int, pending int)
+	walk = func(n node, out int32, row []int, pending int) {
+		if n.final && pending == 0 && row[len(q)] <= maxEdits {
+			keys = append(keys, string(key))
+			dists = append(dists, row[len(q)])
+			outputs = append(outputs, n.outputs(out))
+		}
+		for _, a := range n.arcs {
+			o := out
+			if a.hasOut {
+				o = a.out
+			}
+			key = append(key, a.ch)
+			r, p := row, pending+1 // p is the number of bytes of an incomplete rune
+			for p > 0 && utf8.FullRune(key[len(key)-p:]) {
+				c, size := utf8.DecodeRune(key[len(key)-p:])
+				r = levenshtein(r, q, c)
+				p -= size
+			}
+			if minInt(r) <= maxEdits {
+				walk(t.node(a.next), o, r, p)
+			}
+			key = key[:len(key)-1]
+		}
+	}
+	walk(t.node(0), 0, row, 0)
+	return
+}
@@ -0,0 +1,55 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFSTFuzzySearch01(t *testing.T) {
+	inp := PairSlice{
+		{"apple", 1},
+		{"apply", 2},
+		{"ample", 3},
+		{"maple", 4},
+		{"app", 5},
+		{"東京都", 6},
+		{"京都府", 7},
+		{"東京", 8},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testdata := []struct {
+		query string
+		edits int
+		keys  []string
+		dists []int
+		outs  [][]int32
+	}{
+		{query: "apple", edits: 0, keys: []string{"apple"}, dists: []int{0}, outs: [][]int32{{1}}},
+		{query: "apple", edits: 1, keys: []string{"ample", "apple", "apply"}, dists: []int{1, 0, 1}, outs: [][]int32{{3}, {1}, {2}}},
+		{query: "aple", edits: 1, keys: []string{"ample", "apple", "maple"}, dists: []int{1, 1, 1}, outs: [][]int32{{3}, {1}, {4}}},
+		{query: "apl", edits: 1, keys: []string{"app"}, dists: []int{1}, outs: [][]int32{{5}}},
+		{query: "東京府", edits: 1, keys: []string{"東京", "東京都"}, dists: []int{1, 1}, outs: [][]int32{{8}, {6}}},
+		{query: "東京府", edits: 2, keys: []string{"京都府", "東京", "東京都"}, dists: []int{2, 1, 1}, outs: [][]int32{{7}, {8}, {6}}},
+		{query: "西京都", edits: 1, keys: []string{"東京都"}, dists: []int{1}, outs: [][]int32{{6}}},
+		{query: "xyz", edits: 1},
+		{query: "apple", edits: -1},
+	}
+	for _, d := range testdata {
+		keys, dists, outs := fst.FuzzySearch(d.query, d.edits)
+		if !reflect.DeepEqual(keys, d.keys) || !reflect.DeepEqual(dists, d.dists) || !reflect.DeepEqual(outs, d.outs) {
+			t.Errorf("query %q(%d): got %v %v %v, expected %v %v %v", d.query, d.edits, keys, dists, outs, d.keys, d.dists, d.outs)
+		}
+	}
+}
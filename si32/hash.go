@@ -0,0 +1,116 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+// BuildPerfectHash constructs a finite state transducer like Build which also stores the number of
+// the keys accepted from each state, so that the transducer works as a minimal perfect hash of the
+// keys by Index and Key.
+func BuildPerfectHash(input PairSlice) (t FST, err error) {
+	m := buildMAST(input, Sorted)
+	c := newCompiler()
+	c.counts = true
+	return m.buildMachineWith(c)
+}
+
+// count decodes the number of the keys accepted from the state at pc and returns it and the address
+// of the next instruction, ok is false if the state has no count.
+func (t FST) count(pc int) (n, next int, ok bool) {
+	if pc >= len(t.prog) || operation(t.prog[pc][0]) != opCount {
+		return 0, pc, false
+	}
+	if v16 := t.prog[pc].jump(); v16 > 0 {
+		return int(v16), pc + 1, true
+	}
+	if pc+1 >= len(t.prog) {
+		return 0, pc, false
+	}
+	return int(t.prog[pc+1].value()), pc + 2, true
+}
+
+// NumKeys returns the number of the distinct keys, it is 0 unless the transducer is built by
+// BuildPerfectHash.
+func (t FST) NumKeys() int {
+	n, _, _ := t.count(0)
+	return n
+}
+
+// Index returns the rank of a key in lexicographic order of the keys, i.e. a number in [0, NumKeys())
+// which is unique to the key. It returns false if the key is not accepted or the transducer is not
+// built by BuildPerfectHash.
+func (t FST) Index(key string) (int, bool) {
+	if _, _, ok := t.count(0); !ok {
+		return 0, false
+	}
+	var rank int
+	pc := 0
+	for i := 0; i < len(key); i++ {
+		n := t.node(pc)
+		if n.final {
+			rank++
+		}
+		next := -1
+		for _, a := range n.arcs {
+			if a.ch >= key[i] {
+				if a.ch == key[i] {
+					next = a.next
+				}
+				break
+			}
+			c, _, ok := t.count(a.next)
+			if !ok {
+				return 0, false
+			}
+			rank += c
+		}
+		if next < 0 {
+			return 0, false
+		}
+		pc = next
+	}
+	if !t.node(pc).final {
+		return 0, false
+	}
+	return rank, true
+}
+
+// Key returns the i-th key in lexicographic order, the inverse of Index. It returns an empty string
+// if i is out of [0, NumKeys()).
+func (t FST) Key(i int) string {
+	if i < 0 || i >= t.NumKeys() {
+		return ""
+	}
+	var key []byte
+	for pc := 0; ; {
+		n := t.node(pc)
+		if n.final {
+			if i == 0 {
+				return string(key)
+			}
+			i--
+		}
+		next := -1
+		for _, a := range n.arcs {
+			c, _, ok := t.count(a.next)
+			if !ok {
+				return ""
+			}
+			if i < c {
+				key = append(key, a.ch)
+				next = a.next
+				break
+			}
+			i -= c
+		}
+		if next < 0 {
+			return ""
+		}
+		pc = next
+	}
+}
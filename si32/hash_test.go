@@ -0,0 +1,159 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFSTIndex01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", 28},
+		{"feb", 29},
+		{"apr", 30},
+		{"aug", 31},
+		{"dec", 31},
+		{"a", 1},
+	}
+	keys := []string{"a", "apr", "aug", "dec", "feb"}
+	fst, err := BuildPerfectHash(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fst.Verify(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := fst.NumKeys(); n != len(keys) {
+		t.Errorf("got %v keys, expected %v", n, len(keys))
+	}
+	for i, k := range keys {
+		if got, ok := fst.Index(k); !ok || got != i {
+			t.Errorf("Index(%q): got %v %v, expected %v true", k, got, ok, i)
+		}
+		if got := fst.Key(i); got != k {
+			t.Errorf("Key(%d): got %q, expected %q", i, got, k)
+		}
+	}
+	for _, k := range []string{"ap", "aprx", "b", "fe", "z"} {
+		if got, ok := fst.Index(k); ok {
+			t.Errorf("Index(%q): got %v %v, expected false", k, got, ok)
+		}
+	}
+	for _, i := range []int{-1, len(keys)} {
+		if got := fst.Key(i); got != "" {
+			t.Errorf("Key(%d): got %q, expected empty", i, got)
+		}
+	}
+	if got := fst.Search("feb"); !reflect.DeepEqual(got, []int32{28, 29}) {
+		t.Errorf("got %v, expected [28 29]", got)
+	}
+}
+
+func TestFSTIndex02(t *testing.T) {
+	fst, err := Build(PairSlice{{"apr", 30}, {"aug", 31}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, ok := fst.Index("apr"); ok {
+		t.Errorf("got %v %v, expected false", got, ok)
+	}
+	if got := fst.Key(0); got != "" {
+		t.Errorf("got %q, expected empty", got)
+	}
+	if fst, err = BuildPerfectHash(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := fst.NumKeys(); n != 0 {
+		t.Errorf("got %v keys, expected 0", n)
+	}
+	if got, ok := fst.Index(""); ok {
+		t.Errorf("got %v %v, expected false", got, ok)
+	}
+}
+
+func TestFSTIndex03(t *testing.T) {
+	inp := randomPairs(rand.New(rand.NewSource(1)), 100000, 8)
+	fst, err := BuildPerfectHash(append(PairSlice(nil), inp...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plain, err := Build(append(PairSlice(nil), inp...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	if _, err := fst.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fst, err = Read(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	set := map[string]bool{}
+	for _, p := range inp {
+		set[p.In] = true
+	}
+	var keys []string
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if n := fst.NumKeys(); n != len(keys) || n <= maxUint16 {
+		t.Fatalf("got %v keys, expected %v", n, len(keys))
+	}
+	for i, k := range keys {
+		if got, ok := fst.Index(k); !ok || got != i {
+			t.Fatalf("Index(%q): got %v %v, expected %v true", k, got, ok, i)
+		}
+		if got := fst.Key(i); got != k {
+			t.Fatalf("Key(%d): got %q, expected %q", i, got, k)
+		}
+		if got, exp := fst.Search(k), plain.Search(k); !reflect.DeepEqual(got, exp) {
+			t.Fatalf("Search(%q): got %v, expected %v", k, got, exp)
+		}
+	}
+	text := "abcdefghijklmnopqrstuvwxyz"
+	if got, exp := NewScanner(fst).FindAll(text), NewScanner(plain).FindAll(text); !reflect.DeepEqual(got, exp) {
+		t.Errorf("FindAll: got %v, expected %v", got, exp)
+	}
+	keys1, outs1 := fst.PredictiveSearch("ab", 0)
+	keys2, outs2 := plain.PredictiveSearch("ab", 0)
+	if !reflect.DeepEqual(keys1, keys2) || !reflect.DeepEqual(outs1, outs2) {
+		t.Errorf("PredictiveSearch: got %v %v, expected %v %v", keys1, outs1, keys2, outs2)
+	}
+}
+
+func TestBuildExternalPerfectHash01(t *testing.T) {
+	inp := randomPairs(rand.New(rand.NewSource(1)), 1000, 6)
+	var src bytes.Buffer
+	for _, p := range inp {
+		fmt.Fprintf(&src, "%s\t%d\n", p.In, p.Out)
+	}
+	var w bytes.Buffer
+	cfg := ExternalConfig{TempDir: t.TempDir(), ChunkSize: 100, PerfectHash: true}
+	if _, err := BuildExternal(&w, &src, cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fst, err := Read(&w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp, err := BuildPerfectHash(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fst.prog, exp.prog) || !reflect.DeepEqual(fst.data, exp.data) {
+		t.Errorf("got\n%v\nexpected\n%v", fst, exp)
+	}
+}
@@ -0,0 +1,213 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import "sort"
+
+// arc represents a transition of a compiled state.
+type arc struct {
+	ch     byte
+	out    int32
+	hasOut bool
+	next   int // address of the destination state
+}
+
+// node represents a compiled state.
+type node struct {
+	final bool
+	tails []int32 // outputs of the final state, nil if the output is given by the edges
+	arcs  []arc
+}
+
+// outputs returns the outputs of a final state reached with the output register out.
+func (n node) outputs(out int32) []int32 {
+	if n.tails != nil {
+		return n.tails
+	}
+	return []int32{out}
+}
+
+// node decodes the compiled state at pc.
+func (t FST) node(pc int) (n node) {
+	if _, next, ok := t.count(pc); ok {
+		pc = next
+	}
+	if pc >= len(t.prog) {
+		return
+	}
+	code := t.prog[pc]
+	if op := operation(code[0]); op == opAccept || op == opAcceptBreak {
+		n.final = true
+		pc++
+		if code[1] != 0 {
+			to := t.prog[pc].value()
+			from := t.prog[pc+1].value()
+			n.tails = t.data[from:to]
+			pc += 2
+		}
+		if op == opAcceptBreak {
+			return
+		}
+	}
+	for pc < len(t.prog) {
+		code = t.prog[pc]
+		op := operation(code[0])
+		a := arc{ch: code[1]}
+		switch op {
+		case opOutput, opOutputBreak:
+			pc++
+			a.out = t.prog[pc].value()
+			a.hasOut = true
+		case opMatch, opBreak:
+		default:
+			return
+		}
+		if v16 := code.jump(); v16 > 0 {
+			a.next = pc + int(v16)
+		} else {
+			pc++
+			a.next = pc + int(t.prog[pc].value())
+		}
+		n.arcs = append(n.arcs, a)
+		if op == opBreak || op == opOutputBreak {
+			return
+		}
+		pc++
+	}
+	return
+}
+
+type frame struct {
+	node    node
+	out     int32 // output register on entering the state
+	arc     int   // index of the next arc to visit
+	visited bool
+}
+
+// Iterator enumerates the keys and outputs of a finite state transducer in lexicographic order.
+type Iterator struct {
+	t     FST
+	key   []byte
+	stack []frame
+	to    string // upper bound of the keys, exclusive
+	limit bool   // to is given
+}
+
+// All returns an iterator over all the keys and outputs of the finite state transducer.
+func (t FST) All() *Iterator {
+	return &Iterator{
+		t:     t,
+		stack: []frame{{node: t.node(0)}},
+	}
+}
+
+// Next returns the next key and its outputs. ok is false if there are no more keys.
+func (it *Iterator) Next() (key string, outs []int32, ok bool) {
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+		if !top.visited {
+			top.visited = true
+			if top.node.final {
+				if it.limit && string(it.key) >= it.to {
+					it.key, it.stack = nil, nil
+					break
+				}
+				return string(it.key), top.node.outputs(top.out), true
+			}
+		}
+		if top.arc >= len(top.node.arcs) {
+			it.stack = it.stack[:len(it.stack)-1]
+			if len(it.stack) > 0 {
+				it.key = it.key[:len(it.key)-1]
+			}
+			continue
+		}
+		a := top.node.arcs[top.arc]
+		top.arc++
+		out := top.out
+		if a.hasOut {
+			out = a.out
+		}
+		it.key = append(it.key, a.ch)
+		it.stack = append(it.stack, frame{node: it.t.node(a.next), out: out})
+	}
+	return "", nil, false
+}
+
+// prefix returns an iterator over the keys which start with the given prefix.
+func (t FST) prefix(prefix string) *Iterator {
+	var out int32
+	n := t.node(0)
+	for i := 0; i < len(prefix); i++ {
+		var ok bool
+		for _, a := range n.arcs {
+			if a.ch != prefix[i] {
+				continue
+			}
+			if a.hasOut {
+				out = a.out
+			}
+			n, ok = t.node(a.next), true
+			break
+		}
+		if !ok {
+			return &Iterator{t: t}
+		}
+	}
+	return &Iterator{
+		t:     t,
+		key:   []byte(prefix),
+		stack: []frame{{node: n, out: out}},
+	}
+}
+
+// PredictiveSearch finds keywords which start with a given prefix and returns them and their outputs
+// in lexicographic order. If limit > 0, at most limit keywords are returned.
+func (t FST) PredictiveSearch(prefix string, limit int) (keys []string, outputs [][]int32) {
+	it := t.prefix(prefix)
+	for limit <= 0 || len(keys) < limit {
+		key, outs, ok := it.Next()
+		if !ok {
+			break
+		}
+		keys = append(keys, key)
+		outputs = append(outputs, outs)
+	}
+	return
+}
+
+// Range returns an iterator over the keys in [from, to) and their outputs in lexicographic order.
+// If to is empty, the keys are not bounded above.
+func (t FST) Range(from, to string) *Iterator {
+	it := &Iterator{
+		t:     t,
+		stack: []frame{{node: t.node(0)}},
+		to:    to,
+		limit: to != "",
+	}
+	for i := 0; i < len(from); i++ {
+		top := &it.stack[len(it.stack)-1]
+		top.visited = true // the key is a proper prefix of from
+		arcs := top.node.arcs
+		j := sort.Search(len(arcs), func(j int) bool { return arcs[j].ch >= from[i] })
+		top.arc = j
+		if j == len(arcs) || arcs[j].ch != from[i] {
+			break
+		}
+		top.arc++
+		out := top.out
+		if arcs[j].hasOut {
+			out = arcs[j].out
+		}
+		it.key = append(it.key, arcs[j].ch)
+		it.stack = append(it.stack, frame{node: t.node(arcs[j].next), out: out})
+	}
+	return it
+}
@@ -0,0 +1,221 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIteratorNext01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", 28},
+		{"feb", 29},
+		{"apr", 30},
+		{"jan", 31},
+		{"jun", 30},
+		{"jul", 31},
+		{"dec", 31},
+		{"ju", 0},
+		{"july", 7},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	type entry struct {
+		key  string
+		outs []int32
+	}
+	var got []entry
+	it := fst.All()
+	for {
+		key, outs, ok := it.Next()
+		if !ok {
+			break
+		}
+		got = append(got, entry{key, outs})
+	}
+	exp := []entry{
+		{"apr", []int32{30}},
+		{"dec", []int32{31}},
+		{"feb", []int32{28, 29}},
+		{"jan", []int32{31}},
+		{"ju", []int32{0}},
+		{"jul", []int32{31}},
+		{"july", []int32{7}},
+		{"jun", []int32{30}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+	if _, _, ok := it.Next(); ok {
+		t.Errorf("expected the end of the iteration")
+	}
+}
+
+func TestIteratorNext02(t *testing.T) {
+	fst, err := Build(PairSlice{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key, outs, ok := fst.All().Next(); ok {
+		t.Errorf("got %v %v, expected no entries", key, outs)
+	}
+}
+
+func TestIteratorNext03(t *testing.T) {
+	var inp PairSlice
+	for i := 0; i < 30000; i++ {
+		inp = append(inp, Pair{In: string([]byte{byte(i >> 8), byte(i), byte(i % 7)}), Out: int32(i % 1000)})
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Sort(inp)
+	it := fst.All()
+	for _, p := range inp {
+		key, outs, ok := it.Next()
+		if !ok || key != p.In || !reflect.DeepEqual(outs, []int32{p.Out}) {
+			t.Fatalf("got %q %v %v, expected %q [%v]", key, outs, ok, p.In, p.Out)
+		}
+	}
+	if key, _, ok := it.Next(); ok {
+		t.Errorf("got %q, expected the end of the iteration", key)
+	}
+}
+
+func TestFSTPredictiveSearch01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", 28},
+		{"feb", 29},
+		{"apr", 30},
+		{"jan", 31},
+		{"jun", 30},
+		{"jul", 31},
+		{"ju", 3},
+		{"july", 7},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testdata := []struct {
+		prefix string
+		limit  int
+		keys   []string
+		outs   [][]int32
+	}{
+		{prefix: "ju", keys: []string{"ju", "jul", "july", "jun"}, outs: [][]int32{{3}, {31}, {7}, {30}}},
+		{prefix: "ju", limit: 2, keys: []string{"ju", "jul"}, outs: [][]int32{{3}, {31}}},
+		{prefix: "f", keys: []string{"feb"}, outs: [][]int32{{28, 29}}},
+		{prefix: "july", keys: []string{"july"}, outs: [][]int32{{7}}},
+		{prefix: "julyx"},
+		{prefix: "x"},
+		{prefix: "", limit: 1, keys: []string{"apr"}, outs: [][]int32{{30}}},
+	}
+	for _, d := range testdata {
+		keys, outs := fst.PredictiveSearch(d.prefix, d.limit)
+		for _, v := range outs {
+			sort.Sort(int32Slice(v))
+		}
+		if !reflect.DeepEqual(keys, d.keys) || !reflect.DeepEqual(outs, d.outs) {
+			t.Errorf("prefix %q: got %v %v, expected %v %v", d.prefix, keys, outs, d.keys, d.outs)
+		}
+	}
+}
+
+func TestFSTRange01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", 28},
+		{"feb", 29},
+		{"apr", 30},
+		{"jan", 31},
+		{"jun", 30},
+		{"jul", 31},
+		{"ju", 3},
+		{"july", 7},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testdata := []struct {
+		from, to string
+		keys     []string
+	}{
+		{from: "", to: "", keys: []string{"apr", "feb", "jan", "ju", "jul", "july", "jun"}},
+		{from: "b", to: "jul", keys: []string{"feb", "jan", "ju"}},
+		{from: "ju", to: "jun", keys: []string{"ju", "jul", "july"}},
+		{from: "jua", to: "", keys: []string{"jul", "july", "jun"}},
+		{from: "julz", to: "k", keys: []string{"jun"}},
+		{from: "feb", to: "feb"},
+		{from: "k", to: "z"},
+		{from: "jun", to: "a"},
+	}
+	for _, d := range testdata {
+		var keys []string
+		it := fst.Range(d.from, d.to)
+		for {
+			key, outs, ok := it.Next()
+			if !ok {
+				break
+			}
+			if exp := fst.Search(key); !reflect.DeepEqual(outs, exp) {
+				t.Errorf("[%q, %q) %q: got %v, expected %v", d.from, d.to, key, outs, exp)
+			}
+			keys = append(keys, key)
+		}
+		if !reflect.DeepEqual(keys, d.keys) {
+			t.Errorf("[%q, %q): got %v, expected %v", d.from, d.to, keys, d.keys)
+		}
+		if _, _, ok := it.Next(); ok {
+			t.Errorf("[%q, %q): expected the end of the iteration", d.from, d.to)
+		}
+	}
+}
+
+func TestFSTRange02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	fst, err := Build(randomPairs(r, 3000, 4))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var all []string
+	for it := fst.All(); ; {
+		key, _, ok := it.Next()
+		if !ok {
+			break
+		}
+		all = append(all, key)
+	}
+	for i := 0; i < 300; i++ {
+		from, to := randomPairs(r, 1, 4)[0].In, randomPairs(r, 1, 4)[0].In
+		var exp, got []string
+		for _, k := range all {
+			if k >= from && k < to {
+				exp = append(exp, k)
+			}
+		}
+		for it := fst.Range(from, to); ; {
+			key, _, ok := it.Next()
+			if !ok {
+				break
+			}
+			got = append(got, key)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("[%q, %q): got %v, expected %v", from, to, got, exp)
+		}
+	}
+}
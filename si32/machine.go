@@ -14,8 +14,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"sort"
-	"unsafe"
+
+	"github.com/ikawaha/mast/container"
 )
 
 type operation byte
@@ -27,22 +29,41 @@ const (
 	opBreak       operation = 4
 	opOutput      operation = 5
 	opOutputBreak operation = 6
+	opCount       operation = 7 // number of the keys accepted from the state, see BuildPerfectHash
 )
 
 func (o operation) String() string {
-	opName := []string{"OP0", "ACC", "ACB", "MTC", "BRK", "OUT", "OUB", "OP7"}
+	opName := []string{"OP0", "ACC", "ACB", "MTC", "BRK", "OUT", "OUB", "CNT"}
 	if int(o) >= len(opName) {
 		return fmt.Sprintf("NA[%d]", o)
 	}
 	return opName[o]
 }
 
+// instruction is a word of a program. An operation word holds the operation, the label and a 16bit
+// jump, a value word holds a 32bit value, both in little endian regardless of the host.
 type instruction [4]byte
 
+func newInstruction(op operation, ch byte, jump uint16) (code instruction) {
+	code[0], code[1] = byte(op), ch
+	binary.LittleEndian.PutUint16(code[2:], jump)
+	return
+}
+
+func newValue(v int32) (code instruction) {
+	binary.LittleEndian.PutUint32(code[:], uint32(v))
+	return
+}
+
+func (c instruction) jump() uint16 { return binary.LittleEndian.Uint16(c[2:]) }
+func (c instruction) value() int32 { return int32(binary.LittleEndian.Uint32(c[:])) }
+
 // FST represents a finite state transducer.
 type FST struct {
-	prog []instruction
-	data []int32
+	prog     []instruction
+	data     []int32
+	mapping  []byte // memory mapped by Open
+	monotone bool   // built by BuildMonotone
 }
 
 // Configuration represents a FST configuration.
@@ -75,96 +96,132 @@ func invert(prog []instruction) []instruction {
 	return inv
 }
 
-func (m mast) buildMachine() (t FST, err error) {
-	var (
-		prog []instruction
-		data []int32
-		code instruction // tmp instruction
-	)
-	var edges []byte
-	addrMap := make(map[int]int)
-	for _, s := range m.states {
-		edges = edges[:0]
-		for ch := range s.Trans {
-			edges = append(edges, ch)
-		}
-		if len(edges) > 0 {
-			sort.Sort(byteSlice(edges))
-		}
-		for i, size := 0, len(edges); i < size; i++ {
-			ch := edges[size-1-i]
-			next := s.Trans[ch]
-			addr, ok := addrMap[next.ID]
-			if !ok && !next.IsFinal {
-				err = fmt.Errorf("next addr is undefined: state(%v), input(%X)", s.ID, ch)
-				return
-			}
-			jump := len(prog) - addr + 1
+type compiler struct {
+	prog     []instruction
+	data     []int32
+	progBase int // number of instructions already spilled out of prog
+	dataBase int // number of outputs already spilled out of data
+	edges    []byte
+	counts   bool // emit the numbers of the accepted keys
+}
 
-			var op operation
-			out, ok := s.Output[ch]
-			if !ok {
-				if i == 0 {
-					op = opBreak
-				} else {
-					op = opMatch
-				}
-			} else {
-				if i == 0 {
-					op = opOutputBreak
-				} else {
-					op = opOutput
-				}
-			}
+func newCompiler() *compiler {
+	return &compiler{}
+}
 
-			if jump > maxUint16 {
-				p := unsafe.Pointer(&code[0])
-				(*(*int32)(p)) = int32(jump)
-				prog = append(prog, code)
-				jump = 0
-			}
-			if ok {
-				p := unsafe.Pointer(&code[0])
-				(*(*int32)(p)) = int32(out)
-				prog = append(prog, code)
-			}
+func (c *compiler) progLen() int {
+	return c.progBase + len(c.prog)
+}
+
+func (c *compiler) dataLen() int {
+	return c.dataBase + len(c.data)
+}
 
-			code[0] = byte(op)
-			code[1] = ch
-			p := unsafe.Pointer(&code[2])
-			(*(*uint16)(p)) = uint16(jump)
-			prog = append(prog, code)
+// compile appends the codes of a state and sets the address of them to the state. The codes of
+// the states reachable from the state must be compiled in advance.
+func (c *compiler) compile(s *state) (err error) {
+	var code instruction // tmp instruction
+	edges := c.edges[:0]
+	for ch := range s.Trans {
+		edges = append(edges, ch)
+	}
+	if len(edges) > 0 {
+		sort.Sort(byteSlice(edges))
+	}
+	c.edges = edges
+	var keys int
+	if s.IsFinal {
+		keys = 1
+	}
+	for i, size := 0, len(edges); i < size; i++ {
+		ch := edges[size-1-i]
+		next := s.Trans[ch]
+		keys += next.keys
+		addr := next.addr
+		if addr == 0 && !next.IsFinal {
+			err = fmt.Errorf("next addr is undefined: state(%v), input(%X)", s.ID, ch)
+			return
 		}
-		if s.IsFinal {
-			if len(s.Tail) > 0 {
-				p := unsafe.Pointer(&code[0])
-				(*(*int32)(p)) = int32(len(data))
-				prog = append(prog, code)
-				var tmp int32Slice
-				for t := range s.Tail {
-					tmp = append(tmp, t)
-				}
-				sort.Sort(tmp)
-				data = append(data, tmp...)
-				p = unsafe.Pointer(&code[0])
-				(*(*int32)(p)) = int32(len(data))
-				prog = append(prog, code)
-			}
-			if len(s.Trans) == 0 {
-				code[0] = byte(opAcceptBreak)
+		jump := c.progLen() - addr + 1
+
+		var op operation
+		out, ok := s.Output[ch]
+		if !ok {
+			if i == 0 {
+				op = opBreak
 			} else {
-				code[0] = byte(opAccept)
+				op = opMatch
 			}
-			code[1], code[2], code[3] = 0, 0, 0 // clear
-			if len(s.Tail) > 0 {
-				code[1] = 1
+		} else {
+			if i == 0 {
+				op = opOutputBreak
+			} else {
+				op = opOutput
 			}
+		}
 
-			prog = append(prog, code)
+		if jump > maxUint16 {
+			code = newValue(int32(jump))
+			c.prog = append(c.prog, code)
+			jump = 0
+		}
+		if ok {
+			code = newValue(int32(out))
+			c.prog = append(c.prog, code)
+		}
+
+		code = newInstruction(op, ch, uint16(jump))
+		c.prog = append(c.prog, code)
+	}
+	if s.IsFinal {
+		if len(s.Tail) > 0 {
+			code = newValue(int32(c.dataLen()))
+			c.prog = append(c.prog, code)
+			c.data = append(c.data, s.order...)
+			code = newValue(int32(c.dataLen()))
+			c.prog = append(c.prog, code)
+		}
+		if len(s.Trans) == 0 {
+			code[0] = byte(opAcceptBreak)
+		} else {
+			code[0] = byte(opAccept)
+		}
+		code[1], code[2], code[3] = 0, 0, 0 // clear
+		if len(s.Tail) > 0 {
+			code[1] = 1
+		}
+
+		c.prog = append(c.prog, code)
+	}
+	if c.counts && keys > 0 {
+		if keys > math.MaxInt32 {
+			return fmt.Errorf("too many keys: state(%v), %d keys", s.ID, keys)
+		}
+		if keys > maxUint16 {
+			c.prog = append(c.prog, newValue(int32(keys)))
+			c.prog = append(c.prog, newInstruction(opCount, 0, 0))
+		} else {
+			c.prog = append(c.prog, newInstruction(opCount, 0, uint16(keys)))
 		}
-		addrMap[s.ID] = len(prog)
 	}
-	t = FST{prog: invert(prog), data: data}
+	if c.counts {
+		s.keys = keys
+	}
+	s.addr = c.progLen()
+	return
+}
+
+func (m mast) buildMachine() (t FST, err error) {
+	return m.buildMachineWith(newCompiler())
+}
+
+func (m mast) buildMachineWith(c *compiler) (t FST, err error) {
+	for _, s := range m.states {
+		if err = c.compile(s); err != nil {
+			return
+		}
+	}
+	t = FST{prog: invert(c.prog), data: c.data}
 	return
 }
 
@@ -183,8 +240,14 @@ func (t FST) String() string {
 		code = t.prog[pc]
 		op = operation(code[0])
 		ch = code[1]
-		v16 = (*(*uint16)(unsafe.Pointer(&code[2])))
+		v16 = code.jump()
 		switch operation(op) {
+		case opCount:
+			ret += fmt.Sprintf("%3d %v\t%d\n", pc, op, v16)
+			if v16 == 0 {
+				pc++
+				ret += fmt.Sprintf("%3d [%d]\n", pc, t.prog[pc].value())
+			}
 		case opAccept:
 			fallthrough
 		case opAcceptBreak:
@@ -195,11 +258,11 @@ func (t FST) String() string {
 			}
 			pc++
 			code = t.prog[pc]
-			to := (*(*int32)(unsafe.Pointer(&code[0])))
+			to := code.value()
 			ret += fmt.Sprintf("%3d [%d]\n", pc, to)
 			pc++
 			code = t.prog[pc]
-			from := (*(*int32)(unsafe.Pointer(&code[0])))
+			from := code.value()
 			ret += fmt.Sprintf("%3d [%d] %v\n", pc, from, t.data[from:to]) //FIXME
 		case opMatch:
 			fallthrough
@@ -209,7 +272,7 @@ func (t FST) String() string {
 			if v16 == 0 {
 				pc++
 				code = t.prog[pc]
-				v32 = (*(*int32)(unsafe.Pointer(&code[0])))
+				v32 = code.value()
 				//fmt.Printf("%3d [%d]\n", pc, v32) //XXX
 				ret += fmt.Sprintf("%3d jmp[%d]\n", pc, v32)
 				//break
@@ -222,14 +285,14 @@ func (t FST) String() string {
 			if v16 == 0 {
 				pc++
 				code = t.prog[pc]
-				v32 = (*(*int32)(unsafe.Pointer(&code[0])))
+				v32 = code.value()
 				//fmt.Printf("%3d [%d]\n", pc, v32) //XXX
 				ret += fmt.Sprintf("%3d jmp[%d]\n", pc, v32)
 				//break
 			}
 			pc++
 			code = t.prog[pc]
-			v32 = (*(*int32)(unsafe.Pointer(&code[0])))
+			v32 = code.value()
 			//fmt.Printf("%3d [%d]\n", pc, v32) //XXX
 			ret += fmt.Sprintf("%3d [%d]\n", pc, v32)
 		default:
@@ -256,9 +319,15 @@ func (t *FST) run(input string) (snap []configuration, accept bool) {
 		code = t.prog[pc]
 		op = operation(code[0])
 		ch = code[1]
-		v16 = (*(*uint16)(unsafe.Pointer(&code[2])))
+		v16 = code.jump()
 		//fmt.Printf("pc:%v,op:%v,hd:%v,v16:%v,out:%v\n", pc, op, hd, v16, out) //XXX
 		switch op {
+		case opCount:
+			if v16 == 0 {
+				pc++
+			}
+			pc++
+			continue
 		case opMatch:
 			fallthrough
 		case opBreak:
@@ -280,7 +349,7 @@ func (t *FST) run(input string) (snap []configuration, accept bool) {
 			} else {
 				pc++
 				code = t.prog[pc]
-				v32 = (*(*int32)(unsafe.Pointer(&code[0])))
+				v32 = code.value()
 				//fmt.Printf("ex jump:%d\n", v32) //XXX
 				pc += int(v32)
 			}
@@ -305,13 +374,13 @@ func (t *FST) run(input string) (snap []configuration, accept bool) {
 			}
 			pc++
 			code = t.prog[pc]
-			out = (*(*int32)(unsafe.Pointer(&code[0])))
+			out = code.value()
 			if v16 > 0 {
 				pc += int(v16)
 			} else {
 				pc++
 				code = t.prog[pc]
-				v32 = (*(*int32)(unsafe.Pointer(&code[0])))
+				v32 = code.value()
 				//fmt.Printf("ex jump:%d\n", v32) //XXX
 				pc += int(v32)
 			}
@@ -326,10 +395,10 @@ func (t *FST) run(input string) (snap []configuration, accept bool) {
 				c.out = []int32{out}
 			} else {
 				code = t.prog[pc]
-				to := (*(*int32)(unsafe.Pointer(&code[0])))
+				to := code.value()
 				pc++
 				code = t.prog[pc]
-				from := (*(*int32)(unsafe.Pointer(&code[0])))
+				from := code.value()
 				c.out = t.data[from:to]
 				pc++
 			}
@@ -401,40 +470,63 @@ func (t FST) CommonPrefixSearch(input string) (lens []int, outputs [][]int32) {
 
 // WriteTo saves a program of finite state transducer.
 func (t FST) WriteTo(w io.Writer) (n int64, err error) {
-	var (
-		pc   int
-		code instruction
-		op   operation
-		ch   byte
-		v16  uint16
-		v32  int32
-	)
-	dataLen := int64(len(t.data))
-	//fmt.Println("data len:", dataLen)
-	if err = binary.Write(w, binary.LittleEndian, dataLen); err != nil {
+	cw, err := container.NewWriter(w, container.Header{
+		Kind:     container.KindSI32,
+		Flags:    t.flags(),
+		Sections: []int64{int64(len(t.data)), int64(len(t.prog))},
+	})
+	if err != nil {
 		return
 	}
-	n += int64(binary.Size(dataLen))
-	//fmt.Println("data len:", dataLen) //XXX
+	defer func() { n = cw.Len() }()
 	for _, v := range t.data {
-		if err = binary.Write(w, binary.LittleEndian, v); err != nil {
+		if err = binary.Write(cw, binary.LittleEndian, v); err != nil {
 			return
 		}
-		n += int64(binary.Size(v))
 	}
-
-	progLen := int64(len(t.prog))
-	if err = binary.Write(w, binary.LittleEndian, progLen); err != nil {
+	if _, err = writeProg(cw, &progReader{prog: t.prog}); err != nil {
 		return
 	}
-	n += int64(binary.Size(progLen))
+	err = cw.Close()
+	return
+}
 
-	//fmt.Println("prog len:", progLen) //XXX
-	for pc = 0; pc < len(t.prog); pc++ {
-		code = t.prog[pc]
+type instructionReader interface {
+	next() (instruction, error)
+}
+
+type progReader struct {
+	prog []instruction
+	pc   int
+}
+
+func (r *progReader) next() (code instruction, err error) {
+	if r.pc >= len(r.prog) {
+		return code, io.EOF
+	}
+	code = r.prog[r.pc]
+	r.pc++
+	return
+}
+
+func writeProg(w io.Writer, r instructionReader) (n int64, err error) {
+	var (
+		code instruction
+		op   operation
+		ch   byte
+		v16  uint16
+		v32  int32
+	)
+	for {
+		if code, err = r.next(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
 		op = operation(code[0])
 		ch = code[1]
-		v16 = (*(*uint16)(unsafe.Pointer(&code[2])))
+		v16 = code.jump()
 
 		// write op and ch
 		var tmp int
@@ -451,25 +543,31 @@ func (t FST) WriteTo(w io.Writer) (n int64, err error) {
 			if ch == 0 {
 				break
 			}
-			pc++
-			code = t.prog[pc]
-			v32 = (*(*int32)(unsafe.Pointer(&code[0]))) //to addr
+			if code, err = r.next(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return
+			}
+			v32 = code.value() //to addr
 			if err = binary.Write(w, binary.LittleEndian, v32); err != nil {
 				return
 			}
 			n += int64(binary.Size(v32))
 			//fmt.Printf("%3d \t[%d]\n", pc, v32) //XXX
-			pc++
-			code = t.prog[pc]
-			v32 = (*(*int32)(unsafe.Pointer(&code[0]))) //from addr
+			if code, err = r.next(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return
+			}
+			v32 = code.value() //from addr
 			if err = binary.Write(w, binary.LittleEndian, v32); err != nil {
 				return
 			}
 			n += int64(binary.Size(v32))
 			//fmt.Printf("%3d \t[%d]\n", pc, v32) //XXX
-		case opMatch:
-			fallthrough
-		case opBreak:
+		case opCount, opMatch, opBreak:
 			if err = binary.Write(w, binary.LittleEndian, v16); err != nil {
 				return
 			}
@@ -477,9 +575,13 @@ func (t FST) WriteTo(w io.Writer) (n int64, err error) {
 			if v16 != 0 {
 				break
 			}
-			pc++
-			code = t.prog[pc]
-			v32 = (*(*int32)(unsafe.Pointer(&code[0])))
+			if code, err = r.next(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return
+			}
+			v32 = code.value()
 			if err = binary.Write(w, binary.LittleEndian, v32); err != nil {
 				return
 			}
@@ -492,9 +594,13 @@ func (t FST) WriteTo(w io.Writer) (n int64, err error) {
 				return
 			}
 			n += int64(binary.Size(v16))
-			pc++
-			code = t.prog[pc]
-			v32 = (*(*int32)(unsafe.Pointer(&code[0])))
+			if code, err = r.next(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return
+			}
+			v32 = code.value()
 			if err = binary.Write(w, binary.LittleEndian, v32); err != nil {
 				return
 			}
@@ -504,9 +610,13 @@ func (t FST) WriteTo(w io.Writer) (n int64, err error) {
 			if v16 != 0 {
 				break
 			}
-			pc++
-			code = t.prog[pc]
-			v32 = (*(*int32)(unsafe.Pointer(&code[0])))
+			if code, err = r.next(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return
+			}
+			v32 = code.value()
 			if err = binary.Write(w, binary.LittleEndian, v32); err != nil {
 				return
 			}
@@ -516,7 +626,6 @@ func (t FST) WriteTo(w io.Writer) (n int64, err error) {
 			return n, fmt.Errorf("undefined operation error")
 		}
 	}
-	return
 }
 
 // Read loads a program of finite state transducer.
@@ -527,33 +636,35 @@ func Read(r io.Reader) (t FST, e error) {
 		ch   byte
 		v16  uint16
 		v32  int32
-		p    unsafe.Pointer
 		//pc   int //XXX
 	)
 
-	rd := bufio.NewReader(r)
-
-	var dataLen int64
-	if e = binary.Read(rd, binary.LittleEndian, &dataLen); e != nil {
+	rd, e := container.NewReader(bufio.NewReader(r), container.KindSI32)
+	if e != nil {
 		return
 	}
-	//fmt.Println("data len:", dataLen) //XXX
-	t.data = make([]int32, 0, dataLen)
-	for i := 0; i < int(dataLen); i++ {
-		if e = binary.Read(rd, binary.LittleEndian, &v32); e != nil {
+	if len(rd.Sections) != 2 || rd.Sections[0] < 0 || rd.Sections[1] < 0 {
+		return t, fmt.Errorf("invalid format: sections %v", rd.Sections)
+	}
+	if rd.Flags&flagRaw != 0 {
+		if t, e = readRaw(rd); e != nil {
 			return
 		}
-		t.data = append(t.data, v32)
+		if e = rd.Close(); e != nil {
+			return
+		}
+		t.monotone = rd.Flags&flagMonotone != 0
+		e = t.Verify()
+		return
 	}
-
-	var progLen int64
-	if e = binary.Read(rd, binary.LittleEndian, &progLen); e != nil {
+	t.monotone = rd.Flags&flagMonotone != 0
+	dataLen, progLen := rd.Sections[0], rd.Sections[1]
+	if t.data, e = readData(rd, dataLen); e != nil {
 		return
 	}
-	//fmt.Println("prog len:", progLen) //XXX
-	t.prog = make([]instruction, 0, progLen)
+	t.prog = make([]instruction, 0, minInt64(progLen, 1<<16)) // the length may be corrupted
 
-	for e == nil {
+	for e == nil && int64(len(t.prog)) < progLen {
 		if op, e = rd.ReadByte(); e != nil {
 			break
 		}
@@ -574,8 +685,7 @@ func Read(r io.Reader) (t FST, e error) {
 			if e = binary.Read(rd, binary.LittleEndian, &v32); e != nil {
 				break
 			}
-			p = unsafe.Pointer(&code[0])
-			(*(*int32)(p)) = int32(v32)
+			code = newValue(v32)
 			//fmt.Printf("%3d \t[%d]\n", pc, v32) //XXX
 			//pc++                                //XXX
 			t.prog = append(t.prog, code)
@@ -583,20 +693,16 @@ func Read(r io.Reader) (t FST, e error) {
 			if e = binary.Read(rd, binary.LittleEndian, &v32); e != nil {
 				break
 			}
-			p = unsafe.Pointer(&code[0])
-			(*(*int32)(p)) = int32(v32)
+			code = newValue(v32)
 			//fmt.Printf("%3d \t[%d]\n", pc, v32) //XXX
 			//pc++                                //XXX
 			t.prog = append(t.prog, code)
-		case opMatch:
-			fallthrough
-		case opBreak:
+		case opCount, opMatch, opBreak:
 			code[0], code[1] = op, ch
 			if e = binary.Read(rd, binary.LittleEndian, &v16); e != nil {
 				break
 			}
-			p = unsafe.Pointer(&code[2])
-			(*(*uint16)(p)) = uint16(v16)
+			binary.LittleEndian.PutUint16(code[2:], v16)
 			//fmt.Printf("%3d %v\t%X %d\n", pc, operation(op), ch, v16) //XXX
 			//pc++                                                      //XXX
 			t.prog = append(t.prog, code)
@@ -607,8 +713,7 @@ func Read(r io.Reader) (t FST, e error) {
 			if e = binary.Read(rd, binary.LittleEndian, &v32); e != nil {
 				break
 			}
-			p = unsafe.Pointer(&code[0])
-			(*(*int32)(p)) = int32(v32)
+			code = newValue(v32)
 			//fmt.Printf("%3d \t[%d]\n", pc, v32) //XXX
 			//pc++                                //XXX
 			t.prog = append(t.prog, code)
@@ -619,16 +724,14 @@ func Read(r io.Reader) (t FST, e error) {
 			if e = binary.Read(rd, binary.LittleEndian, &v16); e != nil {
 				break
 			}
-			p = unsafe.Pointer(&code[2])
-			(*(*uint16)(p)) = uint16(v16)
+			binary.LittleEndian.PutUint16(code[2:], v16)
 			//fmt.Printf("%3d %v\t%X %d\n", pc, operation(op), ch, v16) //XXX
 			//pc++                                                      //XXX
 			t.prog = append(t.prog, code)
 			if e = binary.Read(rd, binary.LittleEndian, &v32); e != nil {
 				break
 			}
-			p = unsafe.Pointer(&code[0])
-			(*(*int32)(p)) = int32(v32)
+			code = newValue(v32)
 			//fmt.Printf("%3d \t[%d]\n", pc, v32) //XXX
 			//pc++                                //XXX
 			t.prog = append(t.prog, code)
@@ -639,8 +742,7 @@ func Read(r io.Reader) (t FST, e error) {
 			if e = binary.Read(rd, binary.LittleEndian, &v32); e != nil {
 				break
 			}
-			p = unsafe.Pointer(&code[0])
-			(*(*int32)(p)) = int32(v32)
+			code = newValue(v32)
 			//fmt.Printf("%3d \t[%d]\n", pc, v32) //XXX
 			//pc++                                //XXX
 			t.prog = append(t.prog, code)
@@ -650,7 +752,34 @@ func Read(r io.Reader) (t FST, e error) {
 		}
 	}
 	if e == io.EOF {
-		e = nil
+		e = io.ErrUnexpectedEOF
+	}
+	if e != nil {
+		return
 	}
+	if e = rd.Close(); e != nil {
+		return
+	}
+	e = t.Verify()
 	return
 }
+
+// readData reads n outputs in little endian.
+func readData(r *container.Reader, n int64) ([]int32, error) {
+	b, err := r.ReadSection(n, 4)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]int32, n)
+	for i := range data {
+		data[i] = int32(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return data, nil
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
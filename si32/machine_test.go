@@ -12,11 +12,14 @@ package si32
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
 	"sort"
 	"testing"
+
+	"github.com/ikawaha/mast/container"
 )
 
 func TestFSTRun01(t *testing.T) {
@@ -26,7 +29,7 @@ func TestFSTRun01(t *testing.T) {
 		{"feb", 30},
 		{"dec", 31},
 	}
-	m := buildMAST(inp)
+	m := buildMAST(inp, Sorted)
 	m.dot(os.Stdout)
 
 	fst, _ := m.buildMachine()
@@ -47,7 +50,7 @@ func TestFSTRun02(t *testing.T) {
 		{"feb", 30},
 		{"dec", 31},
 	}
-	m := buildMAST(inp)
+	m := buildMAST(inp, Sorted)
 	m.dot(os.Stdout)
 
 	fst, _ := m.buildMachine()
@@ -66,7 +69,7 @@ func TestFSTRun03(t *testing.T) {
 		{"feb", 0},
 		{"february", 1},
 	}
-	m := buildMAST(inp)
+	m := buildMAST(inp, Sorted)
 	m.dot(os.Stdout)
 
 	fst, _ := m.buildMachine()
@@ -216,6 +219,44 @@ func TestFSTSearch06(t *testing.T) {
 	}
 }
 
+func TestFSTSearch07(t *testing.T) {
+	inp := PairSlice{
+		{"a", 3},
+		{"b", 2},
+		{"a", 1},
+		{"b", 3},
+		{"a", 2},
+		{"b", 1},
+		{"a", 1},
+		{"c", 2},
+		{"c", 1},
+		{"ab", 2},
+		{"ab", 1},
+		{"abc", 0},
+		{"abc", 5},
+	}
+	crs := []struct {
+		order Order
+		outs  map[string][]int32
+	}{
+		{Sorted, map[string][]int32{"a": {1, 2, 3}, "b": {1, 2, 3}, "c": {1, 2}, "ab": {1, 2}, "abc": {0, 5}}},
+		{InsertionOrder, map[string][]int32{"a": {3, 1, 2}, "b": {2, 3, 1}, "c": {2, 1}, "ab": {2, 1}, "abc": {0, 5}}},
+	}
+	for _, cr := range crs {
+		for i := 0; i < 10; i++ {
+			fst, e := BuildWithOrder(append(PairSlice(nil), inp...), cr.order)
+			if e != nil {
+				t.Fatalf("unexpected error: %v\n", e)
+			}
+			for in, exp := range cr.outs {
+				if outs := fst.Search(in); !reflect.DeepEqual(outs, exp) {
+					t.Errorf("order %v, input: %v, got %v, expected %v\n", cr.order, in, outs, exp)
+				}
+			}
+		}
+	}
+}
+
 func TestFSTPrefixSearch01(t *testing.T) {
 	inp := PairSlice{
 		{"こんにちは", 111},
@@ -353,7 +394,7 @@ func TestFSTOperationString(t *testing.T) {
 		{4, "BRK"},
 		{5, "OUT"},
 		{6, "OUB"},
-		{7, "OP7"},
+		{7, "CNT"},
 		{8, "NA[8]"},
 		{9, "NA[9]"},
 	}
@@ -379,7 +420,7 @@ func TestFSTStress(t *testing.T) {
 	if e := s.Err(); e != nil {
 		t.Fatalf("unexpected error, %v", e)
 	}
-	m := buildMAST(ps)
+	m := buildMAST(ps, Sorted)
 	fst, err := m.buildMachine()
 	if err != nil {
 		t.Fatalf("unexpected error, %v", err)
@@ -399,3 +440,88 @@ func TestFSTStress(t *testing.T) {
 		}
 	}
 }
+
+func TestFSTReadFormatError01(t *testing.T) {
+	fst, err := Build(PairSlice{{"hello", 1}, {"world", 2}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	if _, err := fst.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	corrupted := append([]byte(nil), b.Bytes()...)
+	corrupted[27] ^= 0xFF // the label of the first edge
+
+	var other bytes.Buffer
+	w, err := container.NewWriter(&other, container.Header{Kind: container.KindSS, Sections: []int64{0, 0}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Close()
+
+	testdata := []struct {
+		input []byte
+		err   error
+	}{
+		{input: []byte("hello world"), err: container.ErrBadMagic},
+		{input: other.Bytes(), err: container.ErrKind},
+		{input: corrupted, err: container.ErrChecksum},
+	}
+	for _, d := range testdata {
+		if _, err := Read(bytes.NewReader(d.input)); !errors.Is(err, d.err) {
+			t.Errorf("got %v, expected %v", err, d.err)
+		}
+	}
+}
+
+func TestFSTEncoding01(t *testing.T) {
+	fst, err := Build(PairSlice{{"ab", 1}, {"ab", 300}, {"b", 70000}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the encoding must not depend on the byte order of the host
+	prog := []byte{
+		0x03, 0x61, 0x04, 0x00, // MTC a, jump 4
+		0x06, 0x62, 0x01, 0x00, // OUB b, jump 1
+		0x70, 0x11, 0x01, 0x00, // 70000
+		0x02, 0x00, 0x00, 0x00, // ACB
+		0x04, 0x62, 0x01, 0x00, // BRK b, jump 1
+		0x02, 0x01, 0x00, 0x00, // ACB with tails
+		0x02, 0x00, 0x00, 0x00, // to 2
+		0x00, 0x00, 0x00, 0x00, // from 0
+	}
+	var got []byte
+	for _, code := range fst.prog {
+		got = append(got, code[:]...)
+	}
+	if !reflect.DeepEqual(got, prog) {
+		t.Errorf("got %#v, expected %#v", got, prog)
+	}
+	file := []byte{
+		0x4d, 0x41, 0x53, 0x54, 0x01, 0x00, 0x03, 0x00, 0x02, 0x00, // header
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // data length
+		0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // program length
+		0x01, 0x00, 0x00, 0x00, 0x2c, 0x01, 0x00, 0x00, // data
+		0x03, 0x61, 0x04, 0x00,
+		0x06, 0x62, 0x01, 0x00, 0x70, 0x11, 0x01, 0x00,
+		0x02, 0x00,
+		0x04, 0x62, 0x01, 0x00,
+		0x02, 0x01, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xd2, 0x81, 0x98, 0xd1, // checksum
+	}
+	var b bytes.Buffer
+	if _, err := fst.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(b.Bytes(), file) {
+		t.Errorf("got %#v, expected %#v", b.Bytes(), file)
+	}
+	r, err := Read(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(r.prog, fst.prog) || !reflect.DeepEqual(r.data, fst.data) {
+		t.Errorf("got %v, expected %v", r, fst)
+	}
+}
@@ -30,9 +30,26 @@ func (m *mast) addState(n *state) {
 	}
 }
 
+// Order represents the order of the outputs of an input.
+type Order int
+
+const (
+	// Sorted orders the outputs of an input in ascending order.
+	Sorted Order = iota
+	// InsertionOrder orders the outputs of an input in the order of the given pairs.
+	InsertionOrder
+)
+
 // Build constructs a virtual machine of a finite state transducer from a given inputs.
+// The outputs of an input are sorted in ascending order.
 func Build(input PairSlice) (t FST, err error) {
-	m := buildMAST(input)
+	return BuildWithOrder(input, Sorted)
+}
+
+// BuildWithOrder constructs a virtual machine of a finite state transducer which returns the
+// outputs of an input in the given order.
+func BuildWithOrder(input PairSlice, order Order) (t FST, err error) {
+	m := buildMAST(input, order)
 	return m.buildMachine()
 }
 
@@ -48,95 +65,19 @@ func commonPrefix(a, b string) string {
 	return a[0:i]
 }
 
-func buildMAST(input PairSlice) (m mast) {
-	sort.Sort(input)
-
-	const initialMASTSize = 1024
-	dic := make(map[int64][]*state)
-	m.states = make([]*state, 0, initialMASTSize)
-	m.finalStates = make([]*state, 0, initialMASTSize)
-
-	buf := make([]*state, input.maxInputWordLen()+1)
-	for i := range buf {
-		buf[i] = newState()
+func buildMAST(input PairSlice, order Order) mast {
+	if order == Sorted {
+		sort.Sort(input)
+	} else {
+		sort.SliceStable(input, func(i, j int) bool { return input[i].In < input[j].In })
 	}
-	prev := ""
+	r := newMastRegister()
+	b := builder{reg: r, order: order}
 	for _, pair := range input {
-		in, out := pair.In, pair.Out
-		fZero := (out == 0) // flag
-		prefixLen := len(commonPrefix(in, prev))
-		for i := len(prev); i > prefixLen; i-- {
-			var s *state
-			if cs, ok := dic[buf[i].hcode]; ok {
-				for _, c := range cs {
-					if c.eq(buf[i]) {
-						s = c
-						break
-					}
-				}
-			}
-			if s == nil {
-				s = &state{}
-				*s = *buf[i]
-				m.addState(s)
-				dic[s.hcode] = append(dic[s.hcode], s)
-			}
-			buf[i].renew()
-			buf[i-1].setTransition(prev[i-1], s)
-		}
-		for i, size := prefixLen+1, len(in); i <= size; i++ {
-			buf[i-1].setTransition(in[i-1], buf[i])
-		}
-		if in != prev {
-			buf[len(in)].IsFinal = true
-		}
-		for j := 1; j < prefixLen+1; j++ {
-			outSuff, ok := buf[j-1].Output[in[j-1]]
-			if ok {
-				if outSuff == out {
-					out = 0
-					break
-				}
-				buf[j-1].removeOutput(in[j-1]) // clear the prev edge
-				for ch := range buf[j].Trans {
-					buf[j].setOutput(ch, outSuff)
-				}
-				if buf[j].IsFinal && outSuff != 0 {
-					buf[j].addTail(outSuff)
-				}
-			}
-		}
-		if in != prev {
-			buf[prefixLen].setOutput(in[prefixLen], out)
-		} else if fZero || out != 0 {
-			buf[len(in)].addTail(out)
-		}
-		prev = in
-	}
-	// flush the buf
-	for i := len(prev); i > 0; i-- {
-		var s *state
-		if cs, ok := dic[buf[i].hcode]; ok {
-			for _, c := range cs {
-				if c.eq(buf[i]) {
-					s = c
-					break
-				}
-			}
-		}
-		if s == nil {
-			s = &state{}
-			*s = *buf[i]
-			buf[i].renew()
-			m.addState(s)
-			dic[s.hcode] = append(dic[s.hcode], s)
-		}
-		buf[i-1].setTransition(prev[i-1], s)
+		b.add(pair.In, pair.Out) // never fails on the memory register
 	}
-	m.initialState = buf[0]
-	m.addState(buf[0])
-
-	return
+	b.flush()
+	return r.m
 }
 
 func (m *mast) run(input string) (out []int32, ok bool) {
@@ -18,7 +18,7 @@ import (
 
 func TestMASTBuildMAST01(t *testing.T) {
 	inp := PairSlice{}
-	m := buildMAST(inp)
+	m := buildMAST(inp, Sorted)
 	if m.initialState.ID != 0 {
 		t.Errorf("got initial state id %v, expected 0\n", m.initialState.ID)
 	}
@@ -39,7 +39,7 @@ func TestMASTAccept01(t *testing.T) {
 		{"112", 122},
 		{"211", 345},
 	}
-	m := buildMAST(inp)
+	m := buildMAST(inp, Sorted)
 	for _, pair := range inp {
 		if ok := m.accept(pair.In); !ok {
 			t.Errorf("expected: accept [%v]\n", pair.In)
@@ -59,7 +59,7 @@ func TestMASTRun01(t *testing.T) {
 		{"113", 122},
 		{"211", 111},
 	}
-	m := buildMAST(inp)
+	m := buildMAST(inp, Sorted)
 	for _, pair := range inp {
 		out, ok := m.run(pair.In)
 		if !ok {
@@ -82,7 +82,7 @@ func TestMASTRun02(t *testing.T) {
 		{"hello", 1111},
 		{"hello", 2222},
 	}
-	m := buildMAST(inp)
+	m := buildMAST(inp, Sorted)
 	for _, pair := range inp {
 		out, ok := m.run(pair.In)
 		if !ok {
@@ -111,7 +111,7 @@ func TestMASTDot01(t *testing.T) {
 		{"feb", 28},
 		{"feb", 29},
 	}
-	m := buildMAST(inp)
+	m := buildMAST(inp, Sorted)
 	m.dot(os.Stdout)
 }
 
@@ -126,6 +126,6 @@ func TestMASTDot02(t *testing.T) {
 		{"lucid", 2},
 		{"lucifer", 666},
 	}
-	m := buildMAST(inp)
+	m := buildMAST(inp, Sorted)
 	m.dot(os.Stdout)
 }
@@ -0,0 +1,76 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import "sort"
+
+// MergePolicy returns the outputs of a key which both of the merged transducers have, given the
+// outputs of the left and the right one. The key is dropped if no outputs are returned.
+type MergePolicy func(key string, left, right []int32) []int32
+
+// KeepBoth is a merge policy which keeps the outputs of both transducers.
+func KeepBoth(key string, left, right []int32) []int32 {
+	return append(append([]int32(nil), left...), right...)
+}
+
+// PreferLeft is a merge policy which keeps the outputs of the left transducer.
+func PreferLeft(key string, left, right []int32) []int32 {
+	return left
+}
+
+// PreferRight is a merge policy which keeps the outputs of the right transducer.
+func PreferRight(key string, left, right []int32) []int32 {
+	return right
+}
+
+// Merge constructs a minimal finite state transducer of the union of the keys of two transducers.
+// The outputs of a key which both transducers have are given by a policy. The pairs are enumerated
+// from the compiled programs in lexicographic order, so the source pairs are not required.
+func Merge(a, b FST, policy MergePolicy) (FST, error) {
+	bd := NewBuilder()
+	add := bd.addOutputs
+	itA, itB := a.All(), b.All()
+	keyA, outsA, okA := itA.Next()
+	keyB, outsB, okB := itB.Next()
+	for okA || okB {
+		var err error
+		switch {
+		case okA && (!okB || keyA < keyB):
+			err = add(keyA, outsA)
+			keyA, outsA, okA = itA.Next()
+		case okB && (!okA || keyB < keyA):
+			err = add(keyB, outsB)
+			keyB, outsB, okB = itB.Next()
+		default:
+			err = add(keyA, policy(keyA, outsA, outsB))
+			keyA, outsA, okA = itA.Next()
+			keyB, outsB, okB = itB.Next()
+		}
+		if err != nil {
+			return FST{}, err
+		}
+	}
+	return bd.Finish()
+}
+
+// addOutputs appends the pairs of an input and its outputs, the outputs are sorted and deduplicated.
+func (b *Builder) addOutputs(in string, outs []int32) error {
+	outs = append([]int32(nil), outs...)
+	sort.Sort(int32Slice(outs))
+	for i, out := range outs {
+		if i > 0 && out == outs[i-1] {
+			continue
+		}
+		if err := b.Add(in, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestMerge01(t *testing.T) {
+	a, err := Build(PairSlice{{"apr", 30}, {"feb", 28}, {"jan", 31}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := Build(PairSlice{{"dec", 31}, {"feb", 29}, {"feb", 28}, {"jun", 30}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	drop := func(key string, left, right []int32) []int32 { return nil }
+	testdata := []struct {
+		name   string
+		policy MergePolicy
+		feb    []int32
+	}{
+		{name: "KeepBoth", policy: KeepBoth, feb: []int32{28, 29}},
+		{name: "PreferLeft", policy: PreferLeft, feb: []int32{28}},
+		{name: "PreferRight", policy: PreferRight, feb: []int32{28, 29}},
+		{name: "drop", policy: drop},
+	}
+	for _, d := range testdata {
+		fst, err := Merge(a, b, d.policy)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", d.name, err)
+		}
+		keys, outs := fst.PredictiveSearch("", 0)
+		expKeys := []string{"apr", "dec", "feb", "jan", "jun"}
+		expOuts := [][]int32{{30}, {31}, d.feb, {31}, {30}}
+		if d.feb == nil {
+			expKeys = append(expKeys[:2:2], expKeys[3:]...)
+			expOuts = append(expOuts[:2:2], expOuts[3:]...)
+		}
+		if !reflect.DeepEqual(keys, expKeys) || !reflect.DeepEqual(outs, expOuts) {
+			t.Errorf("%s: got %v %v, expected %v %v", d.name, keys, outs, expKeys, expOuts)
+		}
+	}
+}
+
+func TestMerge02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	inpA, inpB := randomPairs(r, 3000, 5), randomPairs(r, 3000, 5)
+	a, err := Build(append(PairSlice(nil), inpA...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := Build(append(PairSlice(nil), inpB...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fst, err := Merge(a, b, KeepBoth)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp, err := Build(append(inpA, inpB...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fst.prog, exp.prog) || !reflect.DeepEqual(fst.data, exp.data) {
+		t.Errorf("got\n%v\nexpected\n%v", fst, exp)
+	}
+}
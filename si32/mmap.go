@@ -0,0 +1,120 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/ikawaha/mast/container"
+)
+
+// flagRaw marks a file whose payload is the in-memory layout of the data and the program.
+// The payload starts at an offset aligned to rawAlign, the data follows as little endian int32s
+// and then the instructions as they are in memory.
+const flagRaw = 1 << 0
+
+const rawAlign = 8
+
+// hostLittleEndian reports whether the data of the raw layout can be used without decoding,
+// it is a variable to emulate big endian hosts.
+var hostLittleEndian = nativeLittleEndian
+
+func rawPadding(h container.Header) int {
+	return (rawAlign - h.Len()%rawAlign) % rawAlign
+}
+
+// WriteRawTo saves a program of finite state transducer in the layout which Open maps into memory
+// without decoding. The file is larger than the one written by WriteTo.
+func (t FST) WriteRawTo(w io.Writer) (n int64, err error) {
+	h := container.Header{
+		Kind:     container.KindSI32,
+		Flags:    flagRaw | t.flags(),
+		Sections: []int64{int64(len(t.data)), int64(len(t.prog))},
+	}
+	cw, err := container.NewWriter(w, h)
+	if err != nil {
+		return
+	}
+	defer func() { n = cw.Len() }()
+	if _, err = cw.Write(make([]byte, rawPadding(h))); err != nil {
+		return
+	}
+	if err = binary.Write(cw, binary.LittleEndian, t.data); err != nil {
+		return
+	}
+	for _, code := range t.prog {
+		if _, err = cw.Write(code[:]); err != nil {
+			return
+		}
+	}
+	err = cw.Close()
+	return
+}
+
+// readRaw reads the payload of the raw layout.
+func readRaw(r *container.Reader) (t FST, err error) {
+	if _, err = io.ReadFull(r, make([]byte, rawPadding(r.Header))); err != nil {
+		return
+	}
+	if t.data, err = readData(r, r.Sections[0]); err != nil {
+		return
+	}
+	b, err := r.ReadSection(r.Sections[1], len(instruction{}))
+	if err != nil {
+		return
+	}
+	t.prog = make([]instruction, r.Sections[1])
+	for i := range t.prog {
+		copy(t.prog[i][:], b[4*i:])
+	}
+	return
+}
+
+// mapFile maps a whole file into memory.
+func mapFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return mmap(f, fi.Size())
+}
+
+// ReadFile loads a file saved by WriteTo or WriteRawTo through a memory mapping. The program and
+// the data are decoded into memory with encoding/binary and verified as Read does, the mapping is
+// released before ReadFile returns. It is the portable counterpart of Open which does not refer to
+// the mapping with the unsafe package.
+func ReadFile(path string) (t *FST, err error) {
+	b, err := mapFile(path)
+	if err != nil {
+		return
+	}
+	defer munmap(b)
+	u, err := Read(bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+	return &u, nil
+}
+
+// Close releases the mapping of a finite state transducer returned by Open. It only drops the
+// program of one which does not refer to a mapping.
+func (t *FST) Close() error {
+	b := t.mapping
+	t.prog, t.data, t.mapping = nil, nil, nil
+	return munmap(b)
+}
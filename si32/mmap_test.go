@@ -0,0 +1,180 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ikawaha/mast/container"
+)
+
+func writeFile(t *testing.T, name string, fn func(f *os.File) error) string {
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fn(f); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestOpen01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", 28},
+		{"feb", 29},
+		{"apr", 30},
+		{"jan", 31},
+		{"jun", 30},
+		{"jul", 31},
+		{"dec", 31},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	raw := writeFile(t, "raw.fst", func(f *os.File) error {
+		_, err := fst.WriteRawTo(f)
+		return err
+	})
+	packed := writeFile(t, "packed.fst", func(f *os.File) error {
+		_, err := fst.WriteTo(f)
+		return err
+	})
+	for _, path := range []string{raw, packed} {
+		m, err := ReadFile(path)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", path, err)
+		}
+		if m.mapping != nil {
+			t.Errorf("%v: unexpected mapping", path)
+		}
+		for _, p := range inp {
+			if outs, exp := m.Search(p.In), fst.Search(p.In); !reflect.DeepEqual(outs, exp) {
+				t.Errorf("%v: input %v, got %v, expected %v", path, p.In, outs, exp)
+			}
+		}
+		if err := m.Close(); err != nil {
+			t.Errorf("%v: unexpected error: %v", path, err)
+		}
+	}
+	for _, path := range []string{raw, packed} {
+		m, err := Open(path)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", path, err)
+		}
+		if path == raw && m.mapping == nil {
+			t.Errorf("%v: expected a mapping", path)
+		}
+		for _, p := range inp {
+			if outs, exp := m.Search(p.In), fst.Search(p.In); !reflect.DeepEqual(outs, exp) {
+				t.Errorf("%v: input %v, got %v, expected %v", path, p.In, outs, exp)
+			}
+		}
+		if !reflect.DeepEqual(m.prog, fst.prog) || !reflect.DeepEqual(m.data, fst.data) {
+			t.Errorf("%v: got %v, expected %v", path, m, fst)
+		}
+		if err := m.Close(); err != nil {
+			t.Errorf("%v: unexpected error: %v", path, err)
+		}
+		if outs := m.Search("feb"); outs != nil {
+			t.Errorf("%v: got %v after close", path, outs)
+		}
+	}
+}
+
+func TestOpen02(t *testing.T) {
+	fst, err := Build(PairSlice{{"hello", 1}, {"world", 2}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	if _, err := fst.WriteRawTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Read accepts the raw layout
+	got, err := Read(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outs := got.Search("world"); !reflect.DeepEqual(outs, []int32{2}) {
+		t.Errorf("got %v, expected [2]", outs)
+	}
+
+	testdata := []struct {
+		name  string
+		input []byte
+		err   error
+	}{
+		{name: "empty", input: nil, err: container.ErrBadMagic},
+		{name: "truncated", input: b.Bytes()[:b.Len()-1]},
+		{name: "magic", input: append([]byte("XXXX"), b.Bytes()[4:]...), err: container.ErrBadMagic},
+		{name: "checksum", input: corrupt(b.Bytes(), b.Len()-5), err: container.ErrChecksum},
+		{name: "program", input: seal(corrupt(b.Bytes(), b.Len()-4-4*len(fst.prog)))}, // undefined operation
+	}
+	for _, d := range testdata {
+		path := writeFile(t, d.name, func(f *os.File) error {
+			_, err := f.Write(d.input)
+			return err
+		})
+		for _, open := range []func(string) (*FST, error){Open, ReadFile} {
+			m, err := open(path)
+			if err == nil {
+				m.Close()
+				t.Errorf("%v: expected an error", d.name)
+				continue
+			}
+			if d.err != nil && !errors.Is(err, d.err) {
+				t.Errorf("%v: got %v, expected %v", d.name, err, d.err)
+			}
+		}
+	}
+}
+
+func corrupt(b []byte, i int) []byte {
+	b = append([]byte(nil), b...)
+	b[i] ^= 0xff
+	return b
+}
+
+func TestOpenBigEndian01(t *testing.T) {
+	// emulates a big endian host which cannot use the data of the mapping as it is
+	defer func(v bool) { hostLittleEndian = v }(hostLittleEndian)
+	hostLittleEndian = false
+
+	inp := PairSlice{{"ab", 1}, {"ab", 300}, {"b", 70000}}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := writeFile(t, "raw.fst", func(f *os.File) error {
+		_, err := fst.WriteRawTo(f)
+		return err
+	})
+	m, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer m.Close()
+	for _, p := range inp {
+		if outs, exp := m.Search(p.In), fst.Search(p.In); !reflect.DeepEqual(outs, exp) {
+			t.Errorf("input %v, got %v, expected %v", p.In, outs, exp)
+		}
+	}
+}
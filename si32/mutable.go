@@ -0,0 +1,199 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import "sort"
+
+// Mutable represents a minimal transducer which accepts insertions and deletions of pairs in any
+// order, following the incremental algorithm of Carrasco and Forcada. The states on the path of a
+// modified input are cloned, changed and registered again from the last one, so that the transducer
+// stays minimal after each operation.
+//
+// If all the keys accepted from a state have the same single output, the state is uniform and the
+// output is put on the edge to the state, otherwise the edges of the state have the outputs of
+// their uniform destinations and the final state has its outputs as tails.
+type Mutable struct {
+	root *state
+	dic  map[int64][]*state // registered states except the root
+	refs map[*state]int     // number of the edges to a registered state
+	id   int                // ID of the next registered state
+}
+
+// NewMutable returns a mutable transducer which has the pairs of a given transducer.
+// A zero FST gives an empty one.
+func NewMutable(t FST) *Mutable {
+	m := &Mutable{
+		root: newState(),
+		dic:  make(map[int64][]*state),
+		refs: make(map[*state]int),
+	}
+	m.root.ID = m.nextID()
+	for it := t.All(); ; {
+		in, outs, ok := it.Next()
+		if !ok {
+			break
+		}
+		for _, out := range outs {
+			m.Insert(in, out)
+		}
+	}
+	return m
+}
+
+func (m *Mutable) nextID() int {
+	m.id++
+	return m.id - 1
+}
+
+// Insert adds a pair. The empty input is ignored as Build does, and the outputs of an input are
+// kept sorted.
+func (m *Mutable) Insert(in string, out int32) {
+	if in == "" {
+		return
+	}
+	p := m.path(in, true)
+	last := p[len(p)-1]
+	last.IsFinal = true
+	last.addTail(out)
+	last.sortTails()
+	m.commit(in, p)
+}
+
+// Delete removes an input and all its outputs.
+func (m *Mutable) Delete(in string) {
+	if in == "" {
+		return
+	}
+	p := m.path(in, false)
+	if p == nil || !p[len(p)-1].IsFinal {
+		return
+	}
+	last := p[len(p)-1]
+	last.IsFinal = false
+	last.clearTails()
+	m.commit(in, p)
+}
+
+// Compile constructs a virtual machine of the transducer.
+func (m *Mutable) Compile() (t FST, err error) {
+	var ms mast
+	seen := make(map[*state]bool)
+	var visit func(s *state)
+	visit = func(s *state) {
+		seen[s] = true
+		edges := make([]byte, 0, len(s.Trans))
+		for ch := range s.Trans {
+			edges = append(edges, ch)
+		}
+		sort.Sort(byteSlice(edges))
+		for _, ch := range edges {
+			if next := s.Trans[ch]; !seen[next] {
+				visit(next)
+			}
+		}
+		ms.states = append(ms.states, s) // the IDs are kept for the register
+		if s.IsFinal {
+			ms.finalStates = append(ms.finalStates, s)
+		}
+	}
+	visit(m.root)
+	ms.initialState = m.root
+	return ms.buildMachine()
+}
+
+// path returns the clones of the states on the path of an input, the uniform ones are expanded.
+// The missing states are created if create, otherwise path returns nil if the path breaks.
+func (m *Mutable) path(in string, create bool) []*state {
+	p := make([]*state, 1, len(in)+1)
+	p[0] = m.root.clone()
+	for i := 0; i < len(in); i++ {
+		s := p[i]
+		next, ok := s.Trans[in[i]]
+		if !ok {
+			if !create {
+				return nil
+			}
+			p = append(p, newState())
+			continue
+		}
+		c := next.clone()
+		if out, ok := s.Output[in[i]]; ok {
+			c.expand(out)
+		}
+		p = append(p, c)
+	}
+	return p
+}
+
+// commit replaces the path of an input with its modified clones from the last one.
+func (m *Mutable) commit(in string, p []*state) {
+	for i := len(p) - 1; i > 0; i-- {
+		ch, parent, s := in[i-1], p[i-1], p[i]
+		delete(parent.Trans, ch)
+		delete(parent.Output, ch)
+		if len(s.Trans) == 0 && !s.IsFinal {
+			continue // dead state
+		}
+		if out, ok := s.uniform(); ok {
+			s.strip()
+			parent.Output[ch] = out
+		}
+		parent.Trans[ch] = m.register(s)
+	}
+	root := p[0]
+	root.ID = m.nextID()
+	root.rehash()
+	for _, next := range root.Trans {
+		m.refs[next]++
+	}
+	old := m.root
+	m.root = root
+	m.release(old)
+}
+
+// register returns a registered state equivalent to a given state, the state is registered if
+// there is no such state.
+func (m *Mutable) register(n *state) *state {
+	n.rehash()
+	for _, s := range m.dic[n.hcode] {
+		if s.eq(n) {
+			return s
+		}
+	}
+	n.ID = m.nextID()
+	m.dic[n.hcode] = append(m.dic[n.hcode], n)
+	for _, next := range n.Trans {
+		m.refs[next]++
+	}
+	return n
+}
+
+// release drops the edges of a state and unregisters the states which are no longer reachable.
+func (m *Mutable) release(n *state) {
+	for _, next := range n.Trans {
+		if m.refs[next]--; m.refs[next] > 0 {
+			continue
+		}
+		delete(m.refs, next)
+		cs := m.dic[next.hcode]
+		for i, c := range cs {
+			if c == next {
+				cs = append(cs[:i], cs[i+1:]...)
+				break
+			}
+		}
+		if len(cs) == 0 {
+			delete(m.dic, next.hcode)
+		} else {
+			m.dic[next.hcode] = cs
+		}
+		m.release(next)
+	}
+}
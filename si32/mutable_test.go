@@ -0,0 +1,118 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// numStates returns the number of the states of a mutable transducer.
+func (m *Mutable) numStates() (n int) {
+	for _, cs := range m.dic {
+		n += len(cs)
+	}
+	return n + 1
+}
+
+func TestMutable01(t *testing.T) {
+	m := NewMutable(FST{})
+	m.Insert("feb", 28)
+	m.Insert("apr", 30)
+	m.Insert("feb", 29)
+	m.Insert("jan", 31)
+	m.Insert("dec", 31)
+	m.Insert("june", 30)
+	m.Insert("jun", 30)
+	m.Insert("", 1)
+	m.Delete("june")
+	m.Delete("jan")
+	m.Delete("nov")
+	m.Delete("ju")
+	fst, err := m.Compile()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fst.Verify(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, outs := fst.PredictiveSearch("", 0)
+	expKeys := []string{"apr", "dec", "feb", "jun"}
+	expOuts := [][]int32{{30}, {31}, {28, 29}, {30}}
+	if !reflect.DeepEqual(keys, expKeys) || !reflect.DeepEqual(outs, expOuts) {
+		t.Errorf("got %v %v, expected %v %v", keys, outs, expKeys, expOuts)
+	}
+}
+
+func TestMutable02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	model := map[string]map[int32]bool{}
+	m := NewMutable(FST{})
+	for i := 0; i < 20000; i++ {
+		p := randomPairs(r, 1, 5)[0]
+		p.Out %= 8
+		if r.Intn(3) == 0 {
+			m.Delete(p.In)
+			delete(model, p.In)
+			continue
+		}
+		m.Insert(p.In, p.Out)
+		if model[p.In] == nil {
+			model[p.In] = map[int32]bool{}
+		}
+		model[p.In][p.Out] = true
+	}
+	var inp PairSlice
+	for in, outs := range model {
+		for out := range outs {
+			inp = append(inp, Pair{In: in, Out: out})
+		}
+	}
+	sort.Sort(inp)
+	fst, err := m.Compile()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fst.Verify(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp, err := Build(append(PairSlice(nil), inp...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	it, expIt := fst.All(), exp.All()
+	for {
+		k1, o1, ok1 := it.Next()
+		k2, o2, ok2 := expIt.Next()
+		if k1 != k2 || !reflect.DeepEqual(o1, o2) || ok1 != ok2 {
+			t.Fatalf("got %q %v %v, expected %q %v %v", k1, o1, ok1, k2, o2, ok2)
+		}
+		if !ok1 {
+			break
+		}
+	}
+
+	// the transducer is the same as the one given by the pairs in order
+	sorted := NewMutable(exp)
+	if got, n := m.numStates(), sorted.numStates(); got != n {
+		t.Errorf("got %v states, expected %v", got, n)
+	}
+	if got, n := m.numStates(), len(buildMAST(inp, Sorted).states); got != n {
+		t.Errorf("got %v states, expected %v states of buildMAST", got, n)
+	}
+	for in := range model {
+		m.Delete(in)
+	}
+	if n := m.numStates(); n != 1 || len(m.refs) != 0 {
+		t.Errorf("got %v states and %v references, expected only the initial state", n, len(m.refs))
+	}
+}
@@ -0,0 +1,183 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+// Shadowing determines how the outputs of a layer affect the layers below it.
+type Shadowing int
+
+const (
+	// Hide hides the outputs of the lower layers for a key which the layer has.
+	Hide Shadowing = iota
+	// Append appends the outputs of the lower layers to the ones of the layer.
+	Append
+)
+
+// Layer represents a finite state transducer in an overlay.
+type Layer struct {
+	FST       FST
+	Shadowing Shadowing
+}
+
+// Overlay represents a stack of finite state transducers searched as one, e.g. a user dictionary
+// on top of a system dictionary. The outputs of a key are resolved at query time by the shadowing
+// rules of the layers which have the key.
+type Overlay struct {
+	layers []Layer
+}
+
+// NewOverlay returns an overlay of layers, the first one is the top.
+func NewOverlay(layers ...Layer) *Overlay {
+	return &Overlay{layers: append([]Layer(nil), layers...)}
+}
+
+// final decodes the accept instruction of the state at pc reached with the output register out.
+// It returns the outputs and the address of the edges, brk is true if the state has no edges.
+// ok is false if the state is not final.
+func (t FST) final(pc int, out int32) (outs []int32, next int, brk, ok bool) {
+	if _, n, ok := t.count(pc); ok {
+		pc = n
+	}
+	if pc >= len(t.prog) {
+		return nil, pc, true, false
+	}
+	code := t.prog[pc]
+	op := operation(code[0])
+	if op != opAccept && op != opAcceptBreak {
+		return nil, pc, false, false
+	}
+	pc++
+	if code[1] != 0 {
+		to := t.prog[pc].value()
+		from := t.prog[pc+1].value()
+		outs = t.data[from:to]
+		pc += 2
+	} else {
+		outs = []int32{out}
+	}
+	return outs, pc, op == opAcceptBreak, true
+}
+
+// cursor represents a configuration of a layer in a run.
+type cursor struct {
+	pc    int
+	out   int32
+	alive bool
+}
+
+// run runs all the layers over the input in one pass and calls fn with the length and the outputs
+// of each non-empty prefix which some layer accepts, it stops when fn returns false.
+func (o *Overlay) run(input string, fn func(hd int, outs []int32) bool) {
+	cs := make([]cursor, len(o.layers))
+	for i := range cs {
+		cs[i].alive = true
+	}
+	for hd := 0; ; hd++ {
+		var (
+			outs   []int32
+			hidden bool
+			alive  bool
+		)
+		for i, l := range o.layers {
+			c := &cs[i]
+			if !c.alive {
+				continue
+			}
+			tails, next, brk, ok := l.FST.final(c.pc, c.out)
+			if ok && hd > 0 && !hidden {
+				outs = append(outs, tails...)
+				hidden = l.Shadowing == Hide
+			}
+			if brk || hd == len(input) {
+				c.alive = false
+				continue
+			}
+			if c.pc, c.out, c.alive = l.FST.transition(next, input[hd], c.out); c.alive {
+				alive = true
+			}
+		}
+		if outs != nil && !fn(hd, outs) {
+			return
+		}
+		if !alive {
+			return
+		}
+	}
+}
+
+// Search returns the outputs of an input resolved over the layers, nil if no layer accepts it.
+func (o *Overlay) Search(input string) (outputs []int32) {
+	o.run(input, func(hd int, outs []int32) bool {
+		if hd == len(input) {
+			outputs = outs
+		}
+		return true
+	})
+	return
+}
+
+// PrefixSearch returns the longest common prefix keyword of the layers in the input and its
+// length, it returns -1, nil if there are no such keywords.
+func (o *Overlay) PrefixSearch(input string) (length int, output []int32) {
+	length = -1
+	o.run(input, func(hd int, outs []int32) bool {
+		length, output = hd, outs
+		return true
+	})
+	return
+}
+
+// CommonPrefixSearch finds the keywords of the layers which are prefixes of the input and returns
+// their lengths and outputs. It returns nil, nil if there are no such keywords.
+func (o *Overlay) CommonPrefixSearch(input string) (lens []int, outputs [][]int32) {
+	o.run(input, func(hd int, outs []int32) bool {
+		lens = append(lens, hd)
+		outputs = append(outputs, outs)
+		return true
+	})
+	return
+}
+
+// transition follows the edge labeled ch from the edges starting at pc, out is the output register.
+func (t FST) transition(pc int, ch byte, out int32) (next int, o int32, ok bool) {
+	for pc < len(t.prog) {
+		code := t.prog[pc]
+		op := operation(code[0])
+		hasOut := op == opOutput || op == opOutputBreak
+		if !hasOut && op != opMatch && op != opBreak {
+			return
+		}
+		v16 := code.jump()
+		if code[1] != ch {
+			if op == opBreak || op == opOutputBreak {
+				return
+			}
+			pc++
+			if hasOut {
+				pc++
+			}
+			if v16 == 0 {
+				pc++
+			}
+			continue
+		}
+		if hasOut {
+			pc++
+			out = t.prog[pc].value()
+		}
+		if v16 > 0 {
+			pc += int(v16)
+		} else {
+			pc++
+			pc += int(t.prog[pc].value())
+		}
+		return pc, out, true
+	}
+	return
+}
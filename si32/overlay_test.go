@@ -0,0 +1,89 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestOverlaySearch01(t *testing.T) {
+	sys, err := Build(PairSlice{{"a", 1}, {"ab", 2}, {"abc", 3}, {"b", 4}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	usr, err := Build(PairSlice{{"ab", 20}, {"abcd", 40}, {"b", 5}, {"b", 6}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testdata := []struct {
+		shadowing Shadowing
+		search    map[string][]int32
+		lens      []int
+		outs      [][]int32
+	}{
+		{
+			shadowing: Hide,
+			search:    map[string][]int32{"a": {1}, "ab": {20}, "abc": {3}, "abcd": {40}, "b": {5, 6}, "c": nil},
+			lens:      []int{1, 2, 3, 4},
+			outs:      [][]int32{{1}, {20}, {3}, {40}},
+		},
+		{
+			shadowing: Append,
+			search:    map[string][]int32{"a": {1}, "ab": {20, 2}, "abc": {3}, "abcd": {40}, "b": {5, 6, 4}, "c": nil},
+			lens:      []int{1, 2, 3, 4},
+			outs:      [][]int32{{1}, {20, 2}, {3}, {40}},
+		},
+	}
+	for _, d := range testdata {
+		o := NewOverlay(Layer{FST: usr, Shadowing: d.shadowing}, Layer{FST: sys})
+		for in, exp := range d.search {
+			if got := o.Search(in); !reflect.DeepEqual(got, exp) {
+				t.Errorf("%v: Search(%q): got %v, expected %v", d.shadowing, in, got, exp)
+			}
+		}
+		lens, outs := o.CommonPrefixSearch("abcde")
+		if !reflect.DeepEqual(lens, d.lens) || !reflect.DeepEqual(outs, d.outs) {
+			t.Errorf("%v: got %v %v, expected %v %v", d.shadowing, lens, outs, d.lens, d.outs)
+		}
+		if l, out := o.PrefixSearch("abcde"); l != 4 || !reflect.DeepEqual(out, []int32{40}) {
+			t.Errorf("%v: got %v %v, expected 4 [40]", d.shadowing, l, out)
+		}
+		if l, out := o.PrefixSearch("x"); l != -1 || out != nil {
+			t.Errorf("%v: got %v %v, expected -1 []", d.shadowing, l, out)
+		}
+	}
+}
+
+func TestOverlaySearch02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	fst, err := Build(randomPairs(r, 3000, 5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	o := NewOverlay(Layer{FST: FST{}}, Layer{FST: fst})
+	for i := 0; i < 1000; i++ {
+		in := randomPairs(r, 1, 7)[0].In
+		if got, exp := o.Search(in), fst.Search(in); !reflect.DeepEqual(got, exp) {
+			t.Fatalf("Search(%q): got %v, expected %v", in, got, exp)
+		}
+		l1, out1 := o.PrefixSearch(in)
+		l2, out2 := fst.PrefixSearch(in)
+		if l1 != l2 || !reflect.DeepEqual(out1, out2) {
+			t.Fatalf("PrefixSearch(%q): got %v %v, expected %v %v", in, l1, out1, l2, out2)
+		}
+		lens1, outs1 := o.CommonPrefixSearch(in)
+		lens2, outs2 := fst.CommonPrefixSearch(in)
+		if !reflect.DeepEqual(lens1, lens2) || !reflect.DeepEqual(outs1, outs2) {
+			t.Fatalf("CommonPrefixSearch(%q): got %v %v, expected %v %v", in, lens1, outs1, lens2, outs2)
+		}
+	}
+}
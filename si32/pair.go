@@ -26,12 +26,3 @@ func (ps PairSlice) Less(i, j int) bool {
 	}
 	return ps[i].In < ps[j].In
 }
-
-func (ps PairSlice) maxInputWordLen() (max int) {
-	for _, pair := range ps {
-		if size := len(pair.In); size > max {
-			max = size
-		}
-	}
-	return
-}
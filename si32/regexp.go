@@ -0,0 +1,52 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import "github.com/ikawaha/mast/internal/pattern"
+
+// RegexpSearch finds keywords which match the regular expression as a whole and returns them
+// and their outputs in lexicographic order. The syntax of the pattern is the one accepted by
+// the regexp package, keywords are matched as UTF-8 rune sequences. The pattern is compiled to a
+// DFA which is built lazily while the transducer is walked, and the walk leaves the edges on which
+// the DFA fails, so the keys which cannot match are not enumerated.
+func (t FST) RegexpSearch(expr string) (keys []string, outputs [][]int32, err error) {
+	d, err := pattern.Compile(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	var key []byte
+	var walk func(n node, out int32, c pattern.Cursor)
+	walk = func(n node, out int32, c pattern.Cursor) {
+		if n.final && d.Match(c) {
+			keys = append(keys, string(key))
+			outputs = append(outputs, n.outputs(out))
+		}
+		for _, a := range n.arcs {
+			o := out
+			if a.hasOut {
+				o = a.out
+			}
+			if next, ok := d.Step(c, a.ch); ok {
+				key = append(key, a.ch)
+				walk(t.node(a.next), o, next)
+				key = key[:len(key)-1]
+			}
+		}
+	}
+	walk(t.node(0), 0, d.Start())
+	return keys, outputs, nil
+}
+
+// GlobSearch finds keywords which match the glob pattern and returns them and their outputs
+// in lexicographic order. '*' matches any sequence of characters, '?' matches any single
+// character and '[...]' matches a character class.
+func (t FST) GlobSearch(glob string) (keys []string, outputs [][]int32, err error) {
+	return t.RegexpSearch(pattern.GlobToRegexp(glob))
+}
@@ -0,0 +1,98 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFSTRegexpSearch01(t *testing.T) {
+	inp := PairSlice{
+		{"apple", 1},
+		{"apply", 2},
+		{"ample", 3},
+		{"maple", 4},
+		{"app", 5},
+		{"東京都", 6},
+		{"京都府", 7},
+		{"a.b", 8},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testdata := []struct {
+		pattern string
+		keys    []string
+		outs    [][]int32
+	}{
+		{pattern: "app.*", keys: []string{"app", "apple", "apply"}, outs: [][]int32{{5}, {1}, {2}}},
+		{pattern: "a[mp]ple", keys: []string{"ample", "apple"}, outs: [][]int32{{3}, {1}}},
+		{pattern: "^.{3}$", keys: []string{"a.b", "app", "京都府", "東京都"}, outs: [][]int32{{8}, {5}, {7}, {6}}},
+		{pattern: `.*e\b`, keys: []string{"ample", "apple", "maple"}, outs: [][]int32{{3}, {1}, {4}}},
+		{pattern: `ap\Bp.*`, keys: []string{"app", "apple", "apply"}, outs: [][]int32{{5}, {1}, {2}}},
+		{pattern: "(?i)APPLY", keys: []string{"apply"}, outs: [][]int32{{2}}},
+		{pattern: "ple"},
+	}
+	for _, d := range testdata {
+		keys, outs, err := fst.RegexpSearch(d.pattern)
+		if err != nil {
+			t.Errorf("pattern %q: unexpected error: %v", d.pattern, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, d.keys) || !reflect.DeepEqual(outs, d.outs) {
+			t.Errorf("pattern %q: got %v %v, expected %v %v", d.pattern, keys, outs, d.keys, d.outs)
+		}
+	}
+	if _, _, err := fst.RegexpSearch("a("); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
+
+func TestFSTGlobSearch01(t *testing.T) {
+	inp := PairSlice{
+		{"apple", 1},
+		{"apply", 2},
+		{"ample", 3},
+		{"maple", 4},
+		{"app", 5},
+		{"東京都", 6},
+		{"a.b", 8},
+		{"a*b", 9},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testdata := []struct {
+		pattern string
+		keys    []string
+	}{
+		{pattern: "app*", keys: []string{"app", "apple", "apply"}},
+		{pattern: "?pple", keys: []string{"apple"}},
+		{pattern: "appl[!e]", keys: []string{"apply"}},
+		{pattern: "a[l-n]ple", keys: []string{"ample"}},
+		{pattern: "東?都", keys: []string{"東京都"}},
+		{pattern: "a.b", keys: []string{"a.b"}},
+		{pattern: `a\*b`, keys: []string{"a*b"}},
+		{pattern: "*", keys: []string{"a*b", "a.b", "ample", "app", "apple", "apply", "maple", "東京都"}},
+	}
+	for _, d := range testdata {
+		keys, _, err := fst.GlobSearch(d.pattern)
+		if err != nil {
+			t.Errorf("pattern %q: unexpected error: %v", d.pattern, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, d.keys) {
+			t.Errorf("pattern %q: got %v, expected %v", d.pattern, keys, d.keys)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrNotMonotone is returned by ReverseLookup for a transducer which is not built by BuildMonotone.
+var ErrNotMonotone = errors.New("transducer is not monotone")
+
+// flagMonotone marks a file of a transducer built by BuildMonotone.
+const flagMonotone = 1 << 1
+
+func (t FST) flags() uint8 {
+	if t.monotone {
+		return flagMonotone
+	}
+	return 0
+}
+
+// MonotoneError is returned by BuildMonotone when the outputs decrease in lexicographic order of
+// the inputs.
+type MonotoneError struct {
+	Prev Pair // the last pair in order
+	Pair Pair // the pair whose output is less than the previous one
+}
+
+// Error returns a description of the violation.
+func (e *MonotoneError) Error() string {
+	return fmt.Sprintf("output %d of input %q is less than output %d of the previous input %q",
+		e.Pair.Out, e.Pair.In, e.Prev.Out, e.Prev.In)
+}
+
+// BuildMonotone constructs a finite state transducer like Build, but returns a MonotoneError
+// unless the outputs are non-decreasing in lexicographic order of the inputs. ReverseLookup
+// requires such a transducer, the property is kept by WriteTo and WriteRawTo.
+func BuildMonotone(input PairSlice) (t FST, err error) {
+	sort.Sort(input)
+	for i := 1; i < len(input); i++ {
+		if input[i].Out < input[i-1].Out {
+			return t, &MonotoneError{Prev: input[i-1], Pair: input[i]}
+		}
+	}
+	if t, err = Build(input); err != nil {
+		return
+	}
+	t.monotone = true
+	return
+}
+
+// lowerBound returns the least output of the keys accepted from the state at pc, which is the output
+// of the leftmost key if the transducer is monotone.
+func (t FST) lowerBound(pc int, out int32) (int32, bool) {
+	for {
+		n := t.node(pc)
+		if n.final {
+			outs := n.outputs(out)
+			if len(outs) == 0 {
+				return 0, false
+			}
+			return outs[0], true
+		}
+		if len(n.arcs) == 0 {
+			return 0, false
+		}
+		a := n.arcs[0]
+		if a.hasOut {
+			out = a.out
+		}
+		pc = a.next
+	}
+}
+
+// ReverseLookup returns a key which has a given output. The transducer must be built by
+// BuildMonotone, so that the search descends to the last edge whose lower bound does not exceed
+// the output, otherwise ErrNotMonotone is returned. It returns false if no key has the output.
+func (t FST) ReverseLookup(out int32) (string, bool, error) {
+	if !t.monotone {
+		return "", false, ErrNotMonotone
+	}
+	var (
+		key []byte
+		reg int32
+		pc  int
+	)
+	for {
+		n := t.node(pc)
+		if n.final {
+			outs := n.outputs(reg)
+			for _, o := range outs {
+				if o == out {
+					return string(key), true, nil
+				}
+			}
+			if len(outs) > 0 && outs[len(outs)-1] > out {
+				return "", false, nil
+			}
+		}
+		next := -1
+		var (
+			ch byte
+			o  int32
+		)
+		for _, a := range n.arcs {
+			x := reg
+			if a.hasOut {
+				x = a.out
+			}
+			lb, ok := t.lowerBound(a.next, x)
+			if !ok || lb > out {
+				break
+			}
+			next, ch, o = a.next, a.ch, x
+		}
+		if next < 0 {
+			return "", false, nil
+		}
+		key = append(key, ch)
+		pc, reg = next, o
+	}
+}
@@ -0,0 +1,168 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestFSTReverseLookup01(t *testing.T) {
+	inp := PairSlice{
+		{"apr", 4},
+		{"aug", 8},
+		{"dec", 12},
+		{"feb", 2},
+		{"jan", 1},
+		{"jul", 7},
+		{"jun", 6},
+		{"mar", 3},
+		{"may", 5},
+		{"nov", 11},
+		{"oct", 10},
+		{"sep", 9},
+	}
+	sort.Sort(inp)
+	for i := range inp {
+		inp[i].Out = int32(i * 10)
+	}
+	fst, err := BuildMonotone(append(PairSlice(nil), inp...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range inp {
+		if got, ok, _ := fst.ReverseLookup(p.Out); !ok || got != p.In {
+			t.Errorf("ReverseLookup(%d): got %q %v, expected %q true", p.Out, got, ok, p.In)
+		}
+		if got, ok, _ := fst.ReverseLookup(p.Out + 1); ok {
+			t.Errorf("ReverseLookup(%d): got %q %v, expected false", p.Out+1, got, ok)
+		}
+	}
+	if got, ok, _ := fst.ReverseLookup(-1); ok {
+		t.Errorf("ReverseLookup(-1): got %q %v, expected false", got, ok)
+	}
+}
+
+func TestFSTReverseLookup02(t *testing.T) {
+	inp := PairSlice{
+		{"a", 1},
+		{"ab", 1},
+		{"abc", 2},
+		{"abc", 3},
+		{"abd", 3},
+		{"b", 5},
+	}
+	fst, err := BuildMonotone(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, out := range []int32{1, 2, 3, 5} {
+		key, ok, _ := fst.ReverseLookup(out)
+		if !ok {
+			t.Errorf("ReverseLookup(%d): got %q %v, expected true", out, key, ok)
+			continue
+		}
+		var found bool
+		for _, o := range fst.Search(key) {
+			found = found || o == out
+		}
+		if !found {
+			t.Errorf("ReverseLookup(%d): got %q, which has %v", out, key, fst.Search(key))
+		}
+	}
+	for _, out := range []int32{0, 4, 6} {
+		if got, ok, _ := fst.ReverseLookup(out); ok {
+			t.Errorf("ReverseLookup(%d): got %q %v, expected false", out, got, ok)
+		}
+	}
+}
+
+func TestFSTReverseLookup03(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	inp := randomPairs(r, 30000, 8)
+	sort.Sort(inp)
+	var keys []string
+	for i := range inp {
+		if i == 0 || inp[i].In != inp[i-1].In {
+			keys = append(keys, inp[i].In)
+		}
+		inp[i].Out = int32(len(keys)-1) * 3
+	}
+	fst, err := BuildMonotone(append(PairSlice(nil), inp...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, k := range keys {
+		if got, ok, _ := fst.ReverseLookup(int32(i) * 3); !ok || got != k {
+			t.Fatalf("ReverseLookup(%d): got %q %v, expected %q true", i*3, got, ok, k)
+		}
+		if got, ok, _ := fst.ReverseLookup(int32(i)*3 + 1); ok {
+			t.Fatalf("ReverseLookup(%d): got %q %v, expected false", i*3+1, got, ok)
+		}
+	}
+}
+
+func TestBuildMonotone01(t *testing.T) {
+	_, err := BuildMonotone(PairSlice{{"a", 2}, {"b", 1}})
+	var e *MonotoneError
+	if !errors.As(err, &e) {
+		t.Fatalf("got %v, expected a monotone error", err)
+	}
+	if e.Prev != (Pair{"a", 2}) || e.Pair != (Pair{"b", 1}) {
+		t.Errorf("got %+v", e)
+	}
+}
+
+func TestFSTReverseLookup04(t *testing.T) {
+	inp := PairSlice{{"a", 1}, {"b", 2}}
+	fst, err := Build(append(PairSlice(nil), inp...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := fst.ReverseLookup(1); err != ErrNotMonotone {
+		t.Errorf("got %v, expected %v", err, ErrNotMonotone)
+	}
+	if fst, err = BuildMonotone(inp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, write := range []func(FST, io.Writer) (int64, error){FST.WriteTo, FST.WriteRawTo} {
+		var b bytes.Buffer
+		if _, err := write(fst, &b); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		rst, err := Read(&b)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, ok, err := rst.ReverseLookup(2); err != nil || !ok || got != "b" {
+			t.Errorf("got %q %v %v, expected b true <nil>", got, ok, err)
+		}
+	}
+}
+
+func TestFSTReverseLookup05(t *testing.T) {
+	fst, err := BuildMonotone(PairSlice{{"a", 1}, {"a", 2}, {"b", 3}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for pc, code := range fst.prog { // empty the range of the outputs of "a"
+		if op := operation(code[0]); (op == opAccept || op == opAcceptBreak) && code[1] != 0 {
+			fst.prog[pc+2] = fst.prog[pc+1]
+			break
+		}
+	}
+	for _, out := range []int32{1, 2, 3} {
+		fst.ReverseLookup(out) // must not panic
+	}
+}
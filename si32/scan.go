@@ -0,0 +1,82 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"sort"
+
+	"github.com/ikawaha/mast/internal/aho"
+)
+
+// Match represents an occurrence of a keyword in a text, text[Start:End] is the keyword.
+type Match struct {
+	Start   int
+	End     int
+	Outputs []int32
+}
+
+// Boundary restricts the positions where matches may start and end.
+type Boundary = aho.Boundary
+
+const (
+	// AnyBoundary allows matches at any byte offset.
+	AnyBoundary = aho.AnyBoundary
+	// RuneBoundary allows matches which start and end at UTF-8 rune boundaries.
+	RuneBoundary = aho.RuneBoundary
+	// WordBoundary allows matches which start and end at rune boundaries that are not
+	// between two word characters (letters, digits or '_').
+	WordBoundary = aho.WordBoundary
+)
+
+// Scanner finds the keywords of a transducer occurring anywhere in a text. It keeps an
+// Aho-Corasick automaton of the keywords, so a text is scanned in one pass whatever the length
+// of the keywords is.
+type Scanner struct {
+	ac   *aho.Automaton
+	outs [][]int32
+}
+
+// NewScanner constructs a scanner of the non-empty keywords of a transducer. It enumerates the
+// whole dictionary and holds a trie of all the keywords and a copy of their outputs, which takes
+// time and memory proportional to the total length of the keywords and the outputs, several
+// times the size of the transducer. Construct it once and reuse it for many texts.
+func NewScanner(t FST) *Scanner {
+	var keys []string
+	s := &Scanner{}
+	it := t.All()
+	for key, outs, ok := it.Next(); ok; key, outs, ok = it.Next() {
+		keys = append(keys, key)
+		s.outs = append(s.outs, outs)
+	}
+	s.ac = aho.New(keys)
+	return s
+}
+
+// FindAllFunc finds the keywords occurring in the text and calls fn for each of them in order of
+// their end offsets, the longer ones first for the same end offset. It stops when fn returns
+// false. The outputs of the matches are shared by the scanner and must not be modified.
+func (s *Scanner) FindAllFunc(text string, b Boundary, fn func(m Match) bool) {
+	s.ac.Scan(text, b, func(start, end, key int) bool {
+		return fn(Match{Start: start, End: end, Outputs: s.outs[key]})
+	})
+}
+
+// FindAll returns all the occurrences of the keywords in the text in order of their start and
+// end offsets.
+func (s *Scanner) FindAll(text string) []Match {
+	var ms []Match
+	s.FindAllFunc(text, AnyBoundary, func(m Match) bool {
+		m.Outputs = append([]int32(nil), m.Outputs...)
+		ms = append(ms, m)
+		return true
+	})
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].Start < ms[j].Start })
+	return ms
+}
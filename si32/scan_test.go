@@ -0,0 +1,129 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestScannerFindAll02(t *testing.T) {
+	inp := PairSlice{
+		{"he", 1},
+		{"she", 2},
+		{"his", 3},
+		{"hers", 4},
+		{"hers", 5},
+		{"東京", 6},
+		{"京都", 7},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text := "ushers 東京都"
+	got := NewScanner(fst).FindAll(text)
+	for _, m := range got {
+		sort.Sort(int32Slice(m.Outputs))
+	}
+	exp := []Match{
+		{Start: 1, End: 4, Outputs: []int32{2}},
+		{Start: 2, End: 4, Outputs: []int32{1}},
+		{Start: 2, End: 6, Outputs: []int32{4, 5}},
+		{Start: 7, End: 13, Outputs: []int32{6}},
+		{Start: 10, End: 16, Outputs: []int32{7}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+
+	// same as CommonPrefixSearch at every offset
+	var naive []Match
+	for i := range text {
+		lens, outs := fst.CommonPrefixSearch(text[i:])
+		for j := range lens {
+			sort.Sort(int32Slice(outs[j]))
+			naive = append(naive, Match{Start: i, End: i + lens[j], Outputs: outs[j]})
+		}
+	}
+	if !reflect.DeepEqual(got, naive) {
+		t.Errorf("got %v, expected %v", got, naive)
+	}
+}
+
+func TestScannerFindAllFunc01(t *testing.T) {
+	inp := PairSlice{
+		{"cat", 1},
+		{"cats", 2},
+		{"at", 3},
+		{"\x81", 4},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := NewScanner(fst)
+	text := "cats,cat あ"
+	testdata := []struct {
+		b   Boundary
+		exp []Match
+	}{
+		{b: AnyBoundary, exp: []Match{{0, 3, []int32{1}}, {1, 3, []int32{3}}, {0, 4, []int32{2}}, {5, 8, []int32{1}}, {6, 8, []int32{3}}, {10, 11, []int32{4}}}},
+		{b: RuneBoundary, exp: []Match{{0, 3, []int32{1}}, {1, 3, []int32{3}}, {0, 4, []int32{2}}, {5, 8, []int32{1}}, {6, 8, []int32{3}}}},
+		{b: WordBoundary, exp: []Match{{0, 4, []int32{2}}, {5, 8, []int32{1}}}},
+	}
+	for _, d := range testdata {
+		var got []Match
+		s.FindAllFunc(text, d.b, func(m Match) bool {
+			got = append(got, m)
+			return true
+		})
+		if !reflect.DeepEqual(got, d.exp) {
+			t.Errorf("boundary %v: got %v, expected %v", d.b, got, d.exp)
+		}
+	}
+
+	var n int
+	s.FindAllFunc(text, AnyBoundary, func(m Match) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("got %v calls, expected 2", n)
+	}
+}
+
+func TestScannerFindAll01(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	fst, err := Build(randomPairs(r, 500, 3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := NewScanner(fst)
+	for i := 0; i < 20; i++ {
+		b := make([]byte, r.Intn(200))
+		for j := range b {
+			b[j] = byte('a' + r.Intn(26))
+		}
+		text := string(b)
+		var naive []Match
+		for i := range text {
+			lens, outs := fst.CommonPrefixSearch(text[i:])
+			for j := range lens {
+				naive = append(naive, Match{Start: i, End: i + lens[j], Outputs: outs[j]})
+			}
+		}
+		if got := s.FindAll(text); !reflect.DeepEqual(got, naive) {
+			t.Fatalf("text:%q, got %v, expected %v", text, got, naive)
+		}
+	}
+}
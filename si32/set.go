@@ -0,0 +1,92 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+// product enumerates the keys of a in lexicographic order together with the outputs of the keys in
+// b by walking the product of the programs, outsB is nil if b does not have the key. The keys which
+// b does not have are skipped unless all.
+func product(a, b FST, all bool, fn func(key string, outsA, outsB []int32) error) error {
+	var key []byte
+	var visit func(pa int, oa int32, pb int, ob int32) error
+	visit = func(pa int, oa int32, pb int, ob int32) error {
+		na := a.node(pa)
+		var nb node
+		if pb >= 0 {
+			nb = b.node(pb)
+		}
+		if na.final {
+			var outsB []int32
+			if nb.final {
+				outsB = nb.outputs(ob)
+			}
+			if outsB != nil || all {
+				if err := fn(string(key), na.outputs(oa), outsB); err != nil {
+					return err
+				}
+			}
+		}
+		var j int
+		for _, x := range na.arcs {
+			next, out := -1, ob
+			for j < len(nb.arcs) && nb.arcs[j].ch < x.ch {
+				j++
+			}
+			if j < len(nb.arcs) && nb.arcs[j].ch == x.ch {
+				next = nb.arcs[j].next
+				if nb.arcs[j].hasOut {
+					out = nb.arcs[j].out
+				}
+			}
+			if next < 0 && !all {
+				continue
+			}
+			o := oa
+			if x.hasOut {
+				o = x.out
+			}
+			key = append(key, x.ch)
+			if err := visit(x.next, o, next, out); err != nil {
+				return err
+			}
+			key = key[:len(key)-1]
+		}
+		return nil
+	}
+	return visit(0, 0, 0, 0)
+}
+
+// Intersect constructs a minimal finite state transducer of the keys which both transducers have.
+// The outputs of a key are given by a policy, e.g. PreferLeft keeps the outputs of a.
+func Intersect(a, b FST, policy MergePolicy) (FST, error) {
+	bd := NewBuilder()
+	err := product(a, b, false, func(key string, outsA, outsB []int32) error {
+		return bd.addOutputs(key, policy(key, outsA, outsB))
+	})
+	if err != nil {
+		return FST{}, err
+	}
+	return bd.Finish()
+}
+
+// Difference constructs a minimal finite state transducer of the keys and outputs of a which b does
+// not have.
+func Difference(a, b FST) (FST, error) {
+	bd := NewBuilder()
+	err := product(a, b, true, func(key string, outsA, outsB []int32) error {
+		if outsB != nil {
+			return nil
+		}
+		return bd.addOutputs(key, outsA)
+	})
+	if err != nil {
+		return FST{}, err
+	}
+	return bd.Finish()
+}
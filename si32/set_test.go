@@ -0,0 +1,93 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestIntersect01(t *testing.T) {
+	a, err := Build(PairSlice{{"apr", 30}, {"feb", 28}, {"jan", 31}, {"ju", 1}, {"jun", 30}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := Build(PairSlice{{"dec", 31}, {"feb", 29}, {"jul", 31}, {"jun", 6}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fst, err := Intersect(a, b, PreferLeft)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, outs := fst.PredictiveSearch("", 0)
+	if exp := []string{"feb", "jun"}; !reflect.DeepEqual(keys, exp) || !reflect.DeepEqual(outs, [][]int32{{28}, {30}}) {
+		t.Errorf("got %v %v, expected %v [[28] [30]]", keys, outs, exp)
+	}
+	if fst, err = Intersect(a, b, KeepBoth); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keys, outs = fst.PredictiveSearch("", 0); !reflect.DeepEqual(outs, [][]int32{{28, 29}, {6, 30}}) {
+		t.Errorf("got %v %v, expected [[28 29] [6 30]]", keys, outs)
+	}
+	if fst, err = Difference(a, b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, outs = fst.PredictiveSearch("", 0)
+	if exp := []string{"apr", "jan", "ju"}; !reflect.DeepEqual(keys, exp) || !reflect.DeepEqual(outs, [][]int32{{30}, {31}, {1}}) {
+		t.Errorf("got %v %v, expected %v [[30] [31] [1]]", keys, outs, exp)
+	}
+}
+
+func TestIntersect02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	inpA, inpB := randomPairs(r, 5000, 4), randomPairs(r, 5000, 4)
+	a, err := Build(append(PairSlice(nil), inpA...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := Build(append(PairSlice(nil), inpB...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	inB := map[string]bool{}
+	for _, p := range inpB {
+		inB[p.In] = true
+	}
+	var both, diff PairSlice
+	for _, p := range inpA {
+		if inB[p.In] {
+			both = append(both, p)
+		} else {
+			diff = append(diff, p)
+		}
+	}
+	for _, d := range []struct {
+		name string
+		op   func() (FST, error)
+		inp  PairSlice
+	}{
+		{name: "Intersect", op: func() (FST, error) { return Intersect(a, b, PreferLeft) }, inp: both},
+		{name: "Difference", op: func() (FST, error) { return Difference(a, b) }, inp: diff},
+	} {
+		fst, err := d.op()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", d.name, err)
+		}
+		exp, err := Build(d.inp)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", d.name, err)
+		}
+		if !reflect.DeepEqual(fst.prog, exp.prog) || !reflect.DeepEqual(fst.data, exp.data) {
+			t.Errorf("%s: got\n%v\nexpected\n%v", d.name, fst, exp)
+		}
+	}
+}
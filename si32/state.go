@@ -9,7 +9,11 @@
 
 package si32
 
-import "fmt"
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
 
 type int32Set map[int32]bool
 
@@ -18,8 +22,11 @@ type state struct {
 	Trans   map[byte]*state
 	Output  map[byte]int32
 	Tail    int32Set
+	order   []int32 // tails in the order of addition
 	IsFinal bool
 	hcode   int64
+	addr    int // address of the compiled codes, 0 if not compiled yet
+	keys    int // number of the keys accepted from the compiled state, counted if emitted
 }
 
 func newState() (n *state) {
@@ -35,15 +42,27 @@ func (n *state) hasTail() bool {
 }
 
 func (n *state) addTail(t int32) {
+	if n.Tail[t] {
+		return
+	}
 	n.Tail[t] = true
+	n.order = append(n.order, t)
 }
 
+// tails returns the tails in the order of addition.
 func (n *state) tails() []int32 {
-	t := make([]int32, 0, len(n.Tail))
-	for item := range n.Tail {
-		t = append(t, item)
-	}
-	return t
+	return append([]int32(nil), n.order...)
+}
+
+// sortTails sorts the tails in ascending order.
+func (n *state) sortTails() {
+	sort.Sort(int32Slice(n.order))
+}
+
+// clearTails removes the tails.
+func (n *state) clearTails() {
+	n.Tail = make(int32Set)
+	n.order = nil
 }
 
 func (n *state) removeOutput(ch byte) {
@@ -71,7 +90,7 @@ func (n *state) setTransition(ch byte, next *state) {
 func (n *state) renew() {
 	n.Trans = make(map[byte]*state)
 	n.Output = make(map[byte]int32)
-	n.Tail = make(int32Set)
+	n.clearTails()
 	n.IsFinal = false
 	n.hcode = 0
 }
@@ -107,9 +126,51 @@ func (n *state) eq(dst *state) bool {
 			return false
 		}
 	}
+	if len(n.order) != len(dst.order) {
+		return false
+	}
+	for i := range n.order {
+		if n.order[i] != dst.order[i] {
+			return false
+		}
+	}
 	return true
 }
 
+// signature returns a key which identifies the state by its transitions, outputs and tails in order.
+// Destination states are identified by their IDs.
+func (n *state) signature() string {
+	edges := make([]byte, 0, len(n.Trans))
+	for ch := range n.Trans {
+		edges = append(edges, ch)
+	}
+	sort.Sort(byteSlice(edges))
+	tails := n.order
+
+	b := make([]byte, 0, 1+len(edges)*(2+2*binary.MaxVarintLen32)+len(tails)*binary.MaxVarintLen32)
+	var tmp [binary.MaxVarintLen64]byte
+	if n.IsFinal {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(edges)))]...)
+	for _, ch := range edges {
+		b = append(b, ch)
+		b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(n.Trans[ch].ID))]...)
+		if out, ok := n.Output[ch]; ok {
+			b = append(b, 1)
+			b = append(b, tmp[:binary.PutVarint(tmp[:], int64(out))]...)
+		} else {
+			b = append(b, 0)
+		}
+	}
+	for _, t := range tails {
+		b = append(b, tmp[:binary.PutVarint(tmp[:], int64(t))]...)
+	}
+	return string(b)
+}
+
 // String returns a string representaion of a node for debug.
 func (n *state) String() string {
 	ret := ""
@@ -125,3 +186,69 @@ func (n *state) String() string {
 	}
 	return ret
 }
+
+// clone returns a copy of the state which shares the destinations.
+func (n *state) clone() *state {
+	c := newState()
+	for ch, next := range n.Trans {
+		c.Trans[ch] = next
+	}
+	for ch, out := range n.Output {
+		c.Output[ch] = out
+	}
+	for _, t := range n.order {
+		c.addTail(t)
+	}
+	c.IsFinal = n.IsFinal
+	return c
+}
+
+// rehash recalculates the hash code of the state.
+func (n *state) rehash() {
+	trans, outs := n.Trans, n.Output
+	n.Trans, n.Output, n.hcode = make(map[byte]*state), make(map[byte]int32), 0
+	for ch, next := range trans {
+		n.setTransition(ch, next)
+	}
+	for ch, out := range outs {
+		n.setOutput(ch, out)
+	}
+}
+
+// uniform returns the output of the state if all the keys accepted from it have the same single
+// output. The edges to the uniform states must have their outputs, see Mutable.
+func (n *state) uniform() (out int32, ok bool) {
+	if n.IsFinal {
+		if len(n.Tail) != 1 {
+			return 0, false
+		}
+		for t := range n.Tail {
+			out, ok = t, true
+		}
+	}
+	for ch := range n.Trans {
+		o, has := n.Output[ch]
+		if !has || ok && o != out {
+			return 0, false
+		}
+		out, ok = o, true
+	}
+	return
+}
+
+// strip removes the outputs of a uniform state, they are given by the edge to the state.
+func (n *state) strip() {
+	n.Output = make(map[byte]int32)
+	n.clearTails()
+}
+
+// expand restores the outputs of a uniform state reached with an output.
+func (n *state) expand(out int32) {
+	for ch := range n.Trans {
+		n.Output[ch] = out
+	}
+	if n.IsFinal {
+		n.clearTails()
+		n.addTail(out)
+	}
+}
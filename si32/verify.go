@@ -0,0 +1,102 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import "fmt"
+
+// Verify checks that the program is well formed so that searches never fail on it: every operation
+// is defined and complete, every state without edges breaks, every output range is non-empty and
+// lies within the data section, and every jump goes forward to the beginning of a state. Read
+// verifies the loaded program.
+func (t FST) Verify() error {
+	starts := make([]bool, len(t.prog))
+	if err := t.verify(starts, false); err != nil {
+		return err
+	}
+	return t.verify(starts, true)
+}
+
+func invalidProgram(pc int, format string, a ...interface{}) error {
+	return fmt.Errorf("invalid program: pc %d: %s", pc, fmt.Sprintf(format, a...))
+}
+
+// verify parses the program and marks the beginnings of states, the jumps are checked if jumps is true.
+func (t FST) verify(starts []bool, jumps bool) error {
+	for pc := 0; pc < len(t.prog); {
+		starts[pc] = true
+		code := t.prog[pc]
+		if op := operation(code[0]); op == opCount {
+			if code.jump() == 0 {
+				pc++
+				if pc >= len(t.prog) {
+					return invalidProgram(pc-1, "incomplete %v", op)
+				}
+				if n := t.prog[pc].value(); n <= 0 {
+					return invalidProgram(pc-1, "invalid count %d", n)
+				}
+			}
+			pc++
+			if pc >= len(t.prog) {
+				return invalidProgram(pc, "missing break")
+			}
+			code = t.prog[pc]
+		}
+		if op := operation(code[0]); op == opAccept || op == opAcceptBreak {
+			if code[1] != 0 {
+				if pc+2 >= len(t.prog) {
+					return invalidProgram(pc, "incomplete %v", op)
+				}
+				to, from := t.prog[pc+1].value(), t.prog[pc+2].value()
+				if from < 0 || from > to || int(to) > len(t.data) {
+					return invalidProgram(pc, "outputs [%d:%d] out of data", from, to)
+				}
+				if from == to {
+					return invalidProgram(pc, "empty outputs [%d:%d]", from, to)
+				}
+				pc += 2
+			}
+			pc++
+			if op == opAcceptBreak {
+				continue
+			}
+		}
+		for last := false; !last; {
+			if pc >= len(t.prog) {
+				return invalidProgram(pc, "missing break")
+			}
+			code = t.prog[pc]
+			op := operation(code[0])
+			p := pc
+			switch op {
+			case opMatch, opBreak:
+			case opOutput, opOutputBreak:
+				p++
+			default:
+				return invalidProgram(pc, "undefined operation %v", op)
+			}
+			last = op == opBreak || op == opOutputBreak
+			jump := int(code.jump())
+			if jump == 0 {
+				p++
+				if p < len(t.prog) {
+					jump = int(t.prog[p].value())
+				}
+			}
+			if p >= len(t.prog) {
+				return invalidProgram(pc, "incomplete %v", op)
+			}
+			if to := p + jump; jumps && (jump <= 0 || to >= len(t.prog) || !starts[to]) {
+				return invalidProgram(pc, "invalid jump to %d", to)
+			}
+			pc = p + 1
+		}
+	}
+	return nil
+}
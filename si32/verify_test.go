@@ -0,0 +1,187 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+func randomPairs(r *rand.Rand, n, maxLen int) PairSlice {
+	ps := make(PairSlice, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, r.Intn(maxLen)+1)
+		for j := range b {
+			b[j] = byte('a' + r.Intn(26))
+		}
+		ps = append(ps, Pair{In: string(b), Out: int32(r.Intn(1 << 20))})
+	}
+	return ps
+}
+
+func TestFSTVerify01(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 10, 1000, 30000} {
+		fst, err := Build(randomPairs(r, n, 12))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := fst.Verify(); err != nil {
+			t.Errorf("%d pairs: unexpected error: %v", n, err)
+		}
+	}
+}
+
+func TestFSTVerify02(t *testing.T) {
+	fst, err := Build(PairSlice{{"ab", 1}, {"ab", 300}, {"b", 70000}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	//   0 MTC a 4, 1 OUB b 1, 2 [70000], 3 ACB, 4 BRK b 1, 5 ACB 1, 6 [2], 7 [0]
+	testdata := []struct {
+		name string
+		fn   func(t *FST)
+	}{
+		{name: "truncated", fn: func(t *FST) { t.prog = t.prog[:7] }},
+		{name: "undefined operation", fn: func(t *FST) { t.prog[0][0] = 8 }},
+		{name: "jump out of program", fn: func(t *FST) { t.prog[0] = newInstruction(opMatch, 'a', 100) }},
+		{name: "jump into a state", fn: func(t *FST) { t.prog[0] = newInstruction(opMatch, 'a', 2) }},
+		{name: "backward jump", fn: func(t *FST) { t.prog[4] = newInstruction(opBreak, 'b', 0); t.prog[5] = newValue(-5) }},
+		{name: "data out of range", fn: func(t *FST) { t.prog[6] = newValue(3) }},
+		{name: "reversed data range", fn: func(t *FST) { t.prog[7] = newValue(2); t.prog[6] = newValue(1) }},
+		{name: "empty data range", fn: func(t *FST) { t.prog[7] = newValue(2) }},
+		{name: "missing break", fn: func(t *FST) { t.prog[1][0] = byte(opOutput) }},
+	}
+	for _, d := range testdata {
+		c := FST{prog: append([]instruction(nil), fst.prog...), data: fst.data}
+		d.fn(&c)
+		if err := c.Verify(); err == nil {
+			t.Errorf("%v: expected an error", d.name)
+		}
+	}
+}
+
+// exercise runs the searches which must not panic on a verified program.
+func exercise(t FST) {
+	s := NewScanner(t)
+	for _, in := range []string{"", "a", "ab", "abc", "b", "ba", "\x00", "\xff\xff"} {
+		t.Search(in)
+		t.PrefixSearch(in)
+		t.CommonPrefixSearch(in)
+		t.PredictiveSearch(in, 10)
+		t.FuzzySearch(in, 1)
+		s.FindAll(in)
+	}
+	t.RegexpSearch("a.*")
+	for i := -1; i <= 3; i++ {
+		t.Index(t.Key(i))
+	}
+	m := t
+	m.monotone = true // the lookup must not fail on a program which is not monotone
+	for out := int32(-1); out <= 3; out++ {
+		m.ReverseLookup(out)
+	}
+	it := t.All()
+	var n int
+	for ; n < 100; n++ {
+		if _, _, ok := it.Next(); !ok {
+			break
+		}
+	}
+	small, _ := Build(PairSlice{{"a", 1}, {"b", 2}})
+	o := NewOverlay(Layer{FST: t}, Layer{FST: small, Shadowing: Append})
+	for _, in := range []string{"", "a", "ab", "b"} {
+		o.Search(in)
+		o.PrefixSearch(in)
+		o.CommonPrefixSearch(in)
+	}
+	if n < 100 { // the number of the keys of a small program may be exponential
+		Merge(t, small, KeepBoth)
+	}
+}
+
+// seal replaces the checksum at the end of a file so that mutations of the payload are read.
+func seal(b []byte) []byte {
+	if len(b) < 4 {
+		return b
+	}
+	b = append([]byte(nil), b...)
+	binary.LittleEndian.PutUint32(b[len(b)-4:], crc32.Checksum(b[:len(b)-4], crc32.MakeTable(crc32.Castagnoli)))
+	return b
+}
+
+// addMutations adds the seeds of a file and its mutations with valid checksums.
+func addMutations(f *testing.F, b []byte) {
+	f.Add(b)
+	for i := 10; i < len(b)-4; i++ {
+		for _, x := range []byte{0x01, 0x80, 0xff} {
+			m := append([]byte(nil), b...)
+			m[i] ^= x
+			f.Add(seal(m))
+		}
+	}
+}
+
+func FuzzRead(f *testing.F) {
+	fst, err := Build(PairSlice{{"ab", 1}, {"ab", 300}, {"b", 70000}, {"abc", 0}})
+	if err != nil {
+		f.Fatalf("unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	fst.WriteTo(&b)
+	addMutations(f, b.Bytes())
+	b.Reset()
+	fst.WriteRawTo(&b)
+	addMutations(f, b.Bytes())
+	if fst, err = BuildMonotone(PairSlice{{"ab", 1}, {"ab", 300}, {"b", 70000}, {"abc", 300}}); err != nil {
+		f.Fatalf("unexpected error: %v", err)
+	}
+	b.Reset()
+	fst.WriteTo(&b)
+	addMutations(f, b.Bytes())
+	if fst, err = BuildPerfectHash(PairSlice{{"ab", 1}, {"ab", 300}, {"b", 70000}, {"abc", 0}}); err != nil {
+		f.Fatalf("unexpected error: %v", err)
+	}
+	b.Reset()
+	fst.WriteTo(&b)
+	addMutations(f, b.Bytes())
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, in := range [][]byte{b, seal(b)} {
+			if fst, err := Read(bytes.NewReader(in)); err == nil {
+				exercise(fst)
+			}
+		}
+	})
+}
+
+func FuzzVerify(f *testing.F) {
+	fst, err := Build(PairSlice{{"ab", 1}, {"ab", 300}, {"b", 70000}, {"abc", 0}})
+	if err != nil {
+		f.Fatalf("unexpected error: %v", err)
+	}
+	var seed []byte
+	for _, code := range fst.prog {
+		seed = append(seed, code[:]...)
+	}
+	f.Add(seed)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		c := FST{data: []int32{1, 2, 3}}
+		for i := 0; i+4 <= len(b); i += 4 {
+			c.prog = append(c.prog, instruction{b[i], b[i+1], b[i+2], b[i+3]})
+		}
+		if c.Verify() != nil {
+			return
+		}
+		exercise(c)
+	})
+}
@@ -0,0 +1,88 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+	"github.com/ikawaha/mast/container"
+)
+
+// mapRaw returns a finite state transducer which refers to b if it is in the raw layout, otherwise
+// b is decoded. The checksum and the program are verified. The instructions of b are reinterpreted
+// in place, they are bytes and need no alignment, and so are the data on little endian hosts,
+// which are aligned by the raw layout.
+func mapRaw(b []byte) (t FST, mapped bool, err error) {
+	r, err := container.NewReader(bytes.NewReader(b), container.KindSI32)
+	if err != nil {
+		return
+	}
+	if len(r.Sections) != 2 || r.Sections[0] < 0 || r.Sections[1] < 0 {
+		return t, false, fmt.Errorf("invalid format: sections %v", r.Sections)
+	}
+	if r.Flags&flagRaw == 0 {
+		t, err = Read(bytes.NewReader(b))
+		return t, false, err
+	}
+	dataLen, progLen := r.Sections[0], r.Sections[1]
+	off := int64(r.Len() + rawPadding(r.Header))
+	if size := off + 4*dataLen + 4*progLen + 4; dataLen > int64(len(b)) || progLen > int64(len(b)) || size != int64(len(b)) {
+		return t, false, fmt.Errorf("invalid format: file size %d", len(b))
+	}
+	if err = container.VerifyChecksum(b); err != nil {
+		return
+	}
+	if dataLen > 0 && hostLittleEndian {
+		t.data = unsafe.Slice((*int32)(unsafe.Pointer(&b[off])), dataLen)
+	} else if dataLen > 0 {
+		t.data = make([]int32, dataLen)
+		for i := range t.data {
+			t.data[i] = int32(binary.LittleEndian.Uint32(b[off+4*int64(i):]))
+		}
+	}
+	off += 4 * dataLen
+	t.monotone = r.Flags&flagMonotone != 0
+	if progLen > 0 {
+		t.prog = unsafe.Slice((*instruction)(unsafe.Pointer(&b[off])), progLen)
+	}
+	if err = t.Verify(); err != nil {
+		return FST{}, false, err
+	}
+	return t, true, nil
+}
+
+// Open maps a file saved by WriteRawTo into memory and returns the finite state transducer which
+// runs on the mapping without decoding it, the mapping is shared with other processes which open
+// the same file. The instructions and, on little endian hosts, the data are reinterpreted in place
+// by the unsafe package; on big endian hosts the data of outputs is copied. ReadFile decodes a
+// file into memory without unsafe instead.
+// The checksum and the program are verified as Read does, which reads the whole mapping once.
+// A file saved by WriteTo is decoded into memory.
+// The transducer must not be used after Close.
+func Open(path string) (t *FST, err error) {
+	b, err := mapFile(path)
+	if err != nil {
+		return
+	}
+	t = &FST{}
+	var mapped bool
+	if *t, mapped, err = mapRaw(b); err != nil || !mapped {
+		munmap(b)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	t.mapping = b
+	return t, nil
+}
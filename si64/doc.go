@@ -0,0 +1,4 @@
+// Package si64 implements string to int64 and string to uint64 transducers. The transducers are
+// the generic transducers of the package mast of the outputs mast.Int64 and mast.Uint64, which sum
+// up the outputs along a path.
+package si64
@@ -0,0 +1,163 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si64
+
+import (
+	"io"
+
+	"github.com/ikawaha/mast"
+)
+
+// FST represents a finite state transducer of int64 outputs.
+type FST struct {
+	fst mast.FST[mast.Int64]
+}
+
+// Order represents the order of the outputs of an input.
+type Order = mast.Order
+
+const (
+	// Sorted orders the outputs of an input in ascending order.
+	Sorted = mast.Sorted
+	// InsertionOrder orders the outputs of an input in the order of the given pairs.
+	InsertionOrder = mast.InsertionOrder
+)
+
+// Build constructs a minimal finite state transducer from a set of pairs.
+// The outputs of an input are sorted in ascending order.
+func Build(input PairSlice) (FST, error) {
+	return BuildWithOrder(input, Sorted)
+}
+
+// BuildWithOrder constructs a minimal finite state transducer which returns the outputs of an
+// input in the given order.
+func BuildWithOrder(input PairSlice, order Order) (FST, error) {
+	ps := make(mast.PairSlice[mast.Int64], len(input))
+	for i, p := range input {
+		ps[i] = mast.Pair[mast.Int64]{In: p.In, Out: mast.Int64(p.Out)}
+	}
+	t, err := mast.BuildWithOrder(ps, order)
+	return FST{fst: t}, err
+}
+
+// Search runs a finite state transducer for a given input and returns outputs if accepted otherwise nil.
+func (t FST) Search(input string) []int64 {
+	return values[int64](t.fst.Search(input))
+}
+
+// PrefixSearch returns the longest commom prefix keyword and it's length in given input
+// if detected otherwise -1, nil.
+func (t FST) PrefixSearch(input string) (length int, output []int64) {
+	length, outs := t.fst.PrefixSearch(input)
+	return length, values[int64](outs)
+}
+
+// CommonPrefixSearch finds keywords sharing common prefix in given input
+// and returns it's lengths and outputs. Returns nil, nil if there does not common prefix keywords.
+func (t FST) CommonPrefixSearch(input string) (lens []int, outputs [][]int64) {
+	lens, outs := t.fst.CommonPrefixSearch(input)
+	return lens, valueLists[int64](outs)
+}
+
+// WriteTo saves a finite state transducer.
+func (t FST) WriteTo(w io.Writer) (int64, error) {
+	return t.fst.WriteTo(w)
+}
+
+// Read loads a finite state transducer saved by FST.WriteTo and verifies it.
+func Read(r io.Reader) (FST, error) {
+	t, err := mast.Read[mast.Int64](r)
+	return FST{fst: t}, err
+}
+
+// Verify checks that the transducer is well formed.
+func (t FST) Verify() error {
+	return t.fst.Verify()
+}
+
+// Uint64FST represents a finite state transducer of uint64 outputs.
+type Uint64FST struct {
+	fst mast.FST[mast.Uint64]
+}
+
+// BuildUint64 constructs a minimal finite state transducer from a set of pairs of unsigned outputs.
+// The outputs of an input are sorted in ascending order.
+func BuildUint64(input Uint64PairSlice) (Uint64FST, error) {
+	return BuildUint64WithOrder(input, Sorted)
+}
+
+// BuildUint64WithOrder constructs a minimal finite state transducer of unsigned outputs which
+// returns the outputs of an input in the given order.
+func BuildUint64WithOrder(input Uint64PairSlice, order Order) (Uint64FST, error) {
+	ps := make(mast.PairSlice[mast.Uint64], len(input))
+	for i, p := range input {
+		ps[i] = mast.Pair[mast.Uint64]{In: p.In, Out: mast.Uint64(p.Out)}
+	}
+	t, err := mast.BuildWithOrder(ps, order)
+	return Uint64FST{fst: t}, err
+}
+
+// Search runs a finite state transducer for a given input and returns outputs if accepted otherwise nil.
+func (t Uint64FST) Search(input string) []uint64 {
+	return values[uint64](t.fst.Search(input))
+}
+
+// PrefixSearch returns the longest commom prefix keyword and it's length in given input
+// if detected otherwise -1, nil.
+func (t Uint64FST) PrefixSearch(input string) (length int, output []uint64) {
+	length, outs := t.fst.PrefixSearch(input)
+	return length, values[uint64](outs)
+}
+
+// CommonPrefixSearch finds keywords sharing common prefix in given input
+// and returns it's lengths and outputs. Returns nil, nil if there does not common prefix keywords.
+func (t Uint64FST) CommonPrefixSearch(input string) (lens []int, outputs [][]uint64) {
+	lens, outs := t.fst.CommonPrefixSearch(input)
+	return lens, valueLists[uint64](outs)
+}
+
+// WriteTo saves a finite state transducer.
+func (t Uint64FST) WriteTo(w io.Writer) (int64, error) {
+	return t.fst.WriteTo(w)
+}
+
+// ReadUint64 loads a finite state transducer saved by Uint64FST.WriteTo and verifies it.
+func ReadUint64(r io.Reader) (Uint64FST, error) {
+	t, err := mast.Read[mast.Uint64](r)
+	return Uint64FST{fst: t}, err
+}
+
+// Verify checks that the transducer is well formed.
+func (t Uint64FST) Verify() error {
+	return t.fst.Verify()
+}
+
+// values converts the outputs of the generic transducer, nil is kept.
+func values[T int64 | uint64, O mast.Int64 | mast.Uint64](outs []O) []T {
+	if outs == nil {
+		return nil
+	}
+	ret := make([]T, len(outs))
+	for i, v := range outs {
+		ret[i] = T(v)
+	}
+	return ret
+}
+
+func valueLists[T int64 | uint64, O mast.Int64 | mast.Uint64](outs [][]O) [][]T {
+	if outs == nil {
+		return nil
+	}
+	ret := make([][]T, len(outs))
+	for i, v := range outs {
+		ret[i] = values[T](v)
+	}
+	return ret
+}
@@ -0,0 +1,276 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si64
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFSTSearch01(t *testing.T) {
+	inp := PairSlice{
+		{"こんにちは", 111},
+		{"世界", 1 << 31},
+		{"すもももももも", 333},
+		{"すもも", -1 << 40},
+		{"すもも", 444},
+		{"big", math.MaxInt64},
+		{"small", math.MinInt64},
+		{"zero", 0},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crs := []struct {
+		in  string
+		out []int64
+	}{
+		{"すもも", []int64{-1 << 40, 444}},
+		{"こんにちわ", nil},
+		{"こんにちは", []int64{111}},
+		{"世界", []int64{1 << 31}},
+		{"すもももももも", []int64{333}},
+		{"すももももももも", nil},
+		{"big", []int64{math.MaxInt64}},
+		{"small", []int64{math.MinInt64}},
+		{"zero", []int64{0}},
+		{"すも", nil},
+		{"", nil},
+	}
+	for _, cr := range crs {
+		if outs := fst.Search(cr.in); !reflect.DeepEqual(outs, cr.out) {
+			t.Errorf("input:%v, got %v, expected %v\n", cr.in, outs, cr.out)
+		}
+	}
+}
+
+func TestFSTSearch02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 300; n++ {
+		var inp PairSlice
+		exp := map[string]map[int64]bool{}
+		for i := r.Intn(60); i > 0; i-- {
+			k := make([]byte, 1+r.Intn(4))
+			for j := range k {
+				k[j] = byte('a' + r.Intn(3))
+			}
+			v := int64(r.Intn(4)) << uint(r.Intn(3)*20)
+			inp = append(inp, Pair{In: string(k), Out: v})
+			if exp[string(k)] == nil {
+				exp[string(k)] = map[int64]bool{}
+			}
+			exp[string(k)][v] = true
+		}
+		fst, err := Build(inp)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for k, v := range exp {
+			var e []int64
+			for x := range v {
+				e = append(e, x)
+			}
+			sort.Slice(e, func(i, j int) bool { return e[i] < e[j] })
+			if outs := fst.Search(k); !reflect.DeepEqual(outs, e) {
+				t.Fatalf("input:%q, got %v, expected %v", k, outs, e)
+			}
+		}
+	}
+}
+
+func TestFSTSearch03(t *testing.T) {
+	inp := PairSlice{{"a", 3}, {"b", 2}, {"a", -1}, {"a", 2}, {"b", 1 << 40}, {"a", -1}}
+	crs := []struct {
+		order Order
+		outs  map[string][]int64
+	}{
+		{Sorted, map[string][]int64{"a": {-1, 2, 3}, "b": {2, 1 << 40}}},
+		{InsertionOrder, map[string][]int64{"a": {3, -1, 2}, "b": {2, 1 << 40}}},
+	}
+	for _, cr := range crs {
+		fst, err := BuildWithOrder(append(PairSlice(nil), inp...), cr.order)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for in, exp := range cr.outs {
+			if outs := fst.Search(in); !reflect.DeepEqual(outs, exp) {
+				t.Errorf("order %v, input: %v, got %v, expected %v\n", cr.order, in, outs, exp)
+			}
+		}
+	}
+	u, err := BuildUint64WithOrder(Uint64PairSlice{{"a", 3}, {"a", 1}}, InsertionOrder)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outs := u.Search("a"); !reflect.DeepEqual(outs, []uint64{3, 1}) {
+		t.Errorf("got %v, expected [3 1]", outs)
+	}
+}
+
+func TestFSTSearch04(t *testing.T) {
+	inp := PairSlice{{"c", 0}, {"b", -1 << 40}, {"bb", 2}, {"bc", -3}, {"a", -3}, {"a", math.MaxInt64}, {"a", math.MinInt64}}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if outs, exp := fst.Search("a"), []int64{math.MinInt64, -3, math.MaxInt64}; !reflect.DeepEqual(outs, exp) {
+		t.Errorf("got %v, expected %v", outs, exp)
+	}
+}
+
+func TestFSTPrefixSearch01(t *testing.T) {
+	inp := PairSlice{
+		{"こんにちは", 111},
+		{"世界", 222},
+		{"すもももももも", 1 << 33},
+		{"すもも", 333},
+		{"すもも", 444},
+	}
+	fst, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crs := []struct {
+		in   string
+		lens []int
+		outs [][]int64
+	}{
+		{"すもも", []int{9}, [][]int64{{333, 444}}},
+		{"こんにちわ", nil, nil},
+		{"こんにちは", []int{15}, [][]int64{{111}}},
+		{"すもももももももものうち", []int{9, 21}, [][]int64{{333, 444}, {1 << 33}}},
+		{"すもう", nil, nil},
+	}
+	for _, cr := range crs {
+		lens, outs := fst.CommonPrefixSearch(cr.in)
+		if !reflect.DeepEqual(lens, cr.lens) || !reflect.DeepEqual(outs, cr.outs) {
+			t.Errorf("input:%v, got %v %v, expected %v %v\n", cr.in, lens, outs, cr.lens, cr.outs)
+		}
+		pos, out := fst.PrefixSearch(cr.in)
+		expPos, expOut := -1, []int64(nil)
+		if len(cr.lens) > 0 {
+			expPos, expOut = cr.lens[len(cr.lens)-1], cr.outs[len(cr.outs)-1]
+		}
+		if pos != expPos || !reflect.DeepEqual(out, expOut) {
+			t.Errorf("input:%v, got %v %v, expected %v %v\n", cr.in, pos, out, expPos, expOut)
+		}
+	}
+}
+
+func TestFSTSaveAndLoad01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", 28},
+		{"feb", 1 << 40},
+		{"apr", 30},
+		{"jan", -31},
+		{"jun", 30},
+		{"jul", 1 << 50},
+		{"dec", 31},
+	}
+	org, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	n, err := org.WriteTo(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("write len: got %v, expected %v", n, b.Len())
+	}
+	rst, err := Read(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(org, rst) {
+		t.Errorf("got %+v, expected %+v\n", rst, org)
+	}
+	for _, p := range inp {
+		outs := rst.Search(p.In)
+		if i := sort.Search(len(outs), func(i int) bool { return outs[i] >= p.Out }); i == len(outs) || outs[i] != p.Out {
+			t.Errorf("input:%v, got %v, expected %v in it", p.In, outs, p.Out)
+		}
+	}
+}
+
+func TestFSTRead01(t *testing.T) {
+	org, err := Build(PairSlice{{"feb", 28}, {"feb", 29}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	if _, err := org.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := Read(bytes.NewReader(b.Bytes()[:b.Len()-1])); err == nil {
+		t.Errorf("expected error for a truncated program")
+	}
+}
+
+func TestUint64FSTSearch01(t *testing.T) {
+	inp := Uint64PairSlice{
+		{"こんにちは", 111},
+		{"世界", 1 << 63},
+		{"すもも", math.MaxUint64},
+		{"すもも", 444},
+		{"zero", 0},
+	}
+	fst, err := BuildUint64(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	crs := []struct {
+		in  string
+		out []uint64
+	}{
+		{"すもも", []uint64{444, math.MaxUint64}},
+		{"こんにちは", []uint64{111}},
+		{"世界", []uint64{1 << 63}},
+		{"zero", []uint64{0}},
+		{"すも", nil},
+	}
+	for _, cr := range crs {
+		if outs := fst.Search(cr.in); !reflect.DeepEqual(outs, cr.out) {
+			t.Errorf("input:%v, got %v, expected %v\n", cr.in, outs, cr.out)
+		}
+	}
+	lens, outs := fst.CommonPrefixSearch("すももも")
+	if !reflect.DeepEqual(lens, []int{9}) || !reflect.DeepEqual(outs, [][]uint64{{444, math.MaxUint64}}) {
+		t.Errorf("got %v %v, expected [9] [[444 %v]]", lens, outs, uint64(math.MaxUint64))
+	}
+}
+
+func TestUint64FSTSaveAndLoad01(t *testing.T) {
+	inp := Uint64PairSlice{{"feb", 28}, {"feb", 1 << 63}, {"jan", 31}}
+	org, err := BuildUint64(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	if _, err := org.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := Read(bytes.NewReader(b.Bytes())); err == nil {
+		t.Errorf("expected error for a transducer of unsigned outputs")
+	}
+	rst, err := ReadUint64(&b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(org, rst) {
+		t.Errorf("got %+v, expected %+v\n", rst, org)
+	}
+}
@@ -0,0 +1,46 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si64
+
+// Pair implements a pair of input and output.
+type Pair struct {
+	In  string
+	Out int64
+}
+
+// PairSlice implements a slice of input and output pairs.
+type PairSlice []Pair
+
+func (ps PairSlice) Len() int      { return len(ps) }
+func (ps PairSlice) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps PairSlice) Less(i, j int) bool {
+	if ps[i].In == ps[j].In {
+		return ps[i].Out < ps[j].Out
+	}
+	return ps[i].In < ps[j].In
+}
+
+// Uint64Pair implements a pair of input and unsigned output.
+type Uint64Pair struct {
+	In  string
+	Out uint64
+}
+
+// Uint64PairSlice implements a slice of input and unsigned output pairs.
+type Uint64PairSlice []Uint64Pair
+
+func (ps Uint64PairSlice) Len() int      { return len(ps) }
+func (ps Uint64PairSlice) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps Uint64PairSlice) Less(i, j int) bool {
+	if ps[i].In == ps[j].In {
+		return ps[i].Out < ps[j].Out
+	}
+	return ps[i].In < ps[j].In
+}
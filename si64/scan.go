@@ -0,0 +1,99 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si64
+
+import "github.com/ikawaha/mast"
+
+// Match represents an occurrence of a keyword in a text, text[Start:End] is the keyword.
+type Match struct {
+	Start   int
+	End     int
+	Outputs []int64
+}
+
+// Uint64Match represents an occurrence of a keyword of a transducer of unsigned outputs.
+type Uint64Match struct {
+	Start   int
+	End     int
+	Outputs []uint64
+}
+
+// Boundary restricts the positions where matches may start and end.
+type Boundary = mast.Boundary
+
+const (
+	// AnyBoundary allows matches at any byte offset.
+	AnyBoundary = mast.AnyBoundary
+	// RuneBoundary allows matches which start and end at UTF-8 rune boundaries.
+	RuneBoundary = mast.RuneBoundary
+	// WordBoundary allows matches which start and end at rune boundaries that are not
+	// between two word characters (letters, digits or '_').
+	WordBoundary = mast.WordBoundary
+)
+
+// Scanner finds the keywords of a transducer occurring anywhere in a text in one pass.
+type Scanner struct {
+	s *mast.Scanner[mast.Int64]
+}
+
+// NewScanner constructs a scanner of the non-empty keywords of a transducer. It holds a trie of
+// all the keywords and a copy of their outputs as mast.NewScanner does, construct it once and
+// reuse it for many texts.
+func NewScanner(t FST) *Scanner {
+	return &Scanner{s: mast.NewScanner(t.fst)}
+}
+
+// FindAllFunc finds the keywords occurring in the text and calls fn for each of them in order of
+// their end offsets, the longer ones first for the same end offset. It stops when fn returns false.
+func (s *Scanner) FindAllFunc(text string, b Boundary, fn func(m Match) bool) {
+	s.s.FindAllFunc(text, b, func(m mast.Match[mast.Int64]) bool {
+		return fn(Match{Start: m.Start, End: m.End, Outputs: values[int64](m.Outputs)})
+	})
+}
+
+// FindAll returns all the occurrences of the keywords in the text in order of their start and
+// end offsets.
+func (s *Scanner) FindAll(text string) []Match {
+	var ms []Match
+	for _, m := range s.s.FindAll(text) {
+		ms = append(ms, Match{Start: m.Start, End: m.End, Outputs: values[int64](m.Outputs)})
+	}
+	return ms
+}
+
+// Uint64Scanner finds the keywords of a transducer of unsigned outputs occurring anywhere in a
+// text in one pass.
+type Uint64Scanner struct {
+	s *mast.Scanner[mast.Uint64]
+}
+
+// NewUint64Scanner constructs a scanner of the non-empty keywords of a transducer. Its memory
+// cost is that of NewScanner.
+func NewUint64Scanner(t Uint64FST) *Uint64Scanner {
+	return &Uint64Scanner{s: mast.NewScanner(t.fst)}
+}
+
+// FindAllFunc finds the keywords occurring in the text and calls fn for each of them in order of
+// their end offsets, the longer ones first for the same end offset. It stops when fn returns false.
+func (s *Uint64Scanner) FindAllFunc(text string, b Boundary, fn func(m Uint64Match) bool) {
+	s.s.FindAllFunc(text, b, func(m mast.Match[mast.Uint64]) bool {
+		return fn(Uint64Match{Start: m.Start, End: m.End, Outputs: values[uint64](m.Outputs)})
+	})
+}
+
+// FindAll returns all the occurrences of the keywords in the text in order of their start and
+// end offsets.
+func (s *Uint64Scanner) FindAll(text string) []Uint64Match {
+	var ms []Uint64Match
+	for _, m := range s.s.FindAll(text) {
+		ms = append(ms, Uint64Match{Start: m.Start, End: m.End, Outputs: values[uint64](m.Outputs)})
+	}
+	return ms
+}
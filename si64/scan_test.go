@@ -0,0 +1,56 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package si64
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestScannerFindAll01(t *testing.T) {
+	fst, err := Build(PairSlice{{"he", -1}, {"she", 1 << 40}, {"hers", -7}, {"hers", 5}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := NewScanner(fst)
+	exp := []Match{
+		{Start: 1, End: 4, Outputs: []int64{1 << 40}},
+		{Start: 2, End: 4, Outputs: []int64{-1}},
+		{Start: 2, End: 6, Outputs: []int64{-7, 5}},
+	}
+	if got := s.FindAll("ushers"); !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+	var got []Match
+	s.FindAllFunc("she, hers", WordBoundary, func(m Match) bool {
+		got = append(got, m)
+		return true
+	})
+	exp = []Match{{Start: 0, End: 3, Outputs: []int64{1 << 40}}, {Start: 5, End: 9, Outputs: []int64{-7, 5}}}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestUint64ScannerFindAll01(t *testing.T) {
+	fst, err := BuildUint64(Uint64PairSlice{{"he", 1}, {"she", math.MaxUint64}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := NewUint64Scanner(fst)
+	exp := []Uint64Match{
+		{Start: 1, End: 4, Outputs: []uint64{math.MaxUint64}},
+		{Start: 2, End: 4, Outputs: []uint64{1}},
+	}
+	if got := s.FindAll("ushers"); !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v", got, exp)
+	}
+}
@@ -0,0 +1,203 @@
+package ss
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrBuilderFinished is returned when a pair is added to a finished builder.
+var ErrBuilderFinished = errors.New("builder is already finished")
+
+// ErrEmptyInput is returned by Builder.Add for an empty input, which a transducer cannot accept.
+var ErrEmptyInput = errors.New("empty input")
+
+// OrderError is returned by Builder.Add when an input is not given in lexicographic order.
+type OrderError struct {
+	Prev string // the last input accepted by the builder
+	In   string // the rejected input
+}
+
+// Error returns a description of the order violation.
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("input %q is out of order, previous input is %q", e.In, e.Prev)
+}
+
+// register keeps the frozen states of a transducer under construction.
+type register interface {
+	// freeze returns a frozen state equivalent to a given state.
+	freeze(n *state) (*state, error)
+	// finish registers the initial state.
+	finish(initial *state) error
+}
+
+// mastRegister keeps all frozen states in memory to build a mast.
+type mastRegister struct {
+	m   *mast
+	dic map[uint][]*state
+}
+
+func newMastRegister() *mastRegister {
+	const initialMastSize = 1024
+	r := &mastRegister{
+		m:   new(mast),
+		dic: make(map[uint][]*state),
+	}
+	r.m.states = make([]*state, 0, initialMastSize)
+	r.m.finalStates = make([]*state, 0, initialMastSize)
+	return r
+}
+
+func (r *mastRegister) freeze(n *state) (*state, error) {
+	if cs, ok := r.dic[n.hcode]; ok {
+		for _, c := range cs {
+			if c.eq(n) {
+				c.setInvTransition()
+				return c, nil
+			}
+		}
+	}
+	s := &state{}
+	*s = *n
+	r.m.addState(s)
+	r.dic[s.hcode] = append(r.dic[s.hcode], s)
+	s.setInvTransition()
+	return s, nil
+}
+
+func (r *mastRegister) finish(initial *state) error {
+	r.m.initialState = initial
+	r.m.addState(initial)
+	return nil
+}
+
+// builder implements the construction of a mast from pairs sorted by input.
+type builder struct {
+	reg   register
+	buf   []*state
+	prev  string
+	order Order
+}
+
+// freeze returns a frozen state equivalent to the state of the buffer at i, and renews the buffer.
+func (b *builder) freeze(i int) (*state, error) {
+	n := b.buf[i]
+	if b.order == Sorted {
+		n.sortTails()
+	}
+	s, err := b.reg.freeze(n)
+	n.renew()
+	return s, err
+}
+
+func (b *builder) add(in, out string) error {
+	for len(b.buf) <= len(in) {
+		b.buf = append(b.buf, newState())
+	}
+	buf, prev := b.buf, b.prev
+	prefixLen := commonPrefixLen(in, prev)
+	for i := len(prev); i > prefixLen; i-- {
+		s, err := b.freeze(i)
+		if err != nil {
+			return err
+		}
+		buf[i-1].setTransition(prev[i-1], s)
+	}
+	for i, size := prefixLen+1, len(in); i <= size; i++ {
+		buf[i-1].setTransition(in[i-1], buf[i])
+	}
+	if in != prev {
+		buf[len(in)].IsFinal = true
+	}
+	for j := 1; j < prefixLen+1; j++ {
+		outPref := commonPrefix(buf[j-1].Output[in[j-1]], out)
+		outSuff := strings.TrimPrefix(buf[j-1].Output[in[j-1]], outPref)
+		buf[j-1].setOutput(in[j-1], outPref)
+		for ch := range buf[j].Trans {
+			buf[j].setOutput(ch, outSuff+buf[j].Output[ch])
+		}
+		if buf[j].IsFinal {
+			tails := []string{outSuff}
+			if buf[j].hasTail() {
+				tails = buf[j].tails()
+				for i := range tails {
+					tails[i] = outSuff + tails[i]
+				}
+			}
+			buf[j].setTail(tails)
+		}
+		out = strings.TrimPrefix(out, outPref)
+	}
+	if in == prev {
+		buf[len(in)].addTail(out)
+	} else {
+		buf[prefixLen].setOutput(in[prefixLen], out)
+	}
+	b.prev = in
+	return nil
+}
+
+func (b *builder) flush() error {
+	if len(b.buf) == 0 {
+		b.buf = append(b.buf, newState())
+	}
+	for i := len(b.prev); i > 0; i-- {
+		s, err := b.freeze(i)
+		if err != nil {
+			return err
+		}
+		b.buf[i-1].setTransition(b.prev[i-1], s)
+	}
+	if b.order == Sorted {
+		b.buf[0].sortTails()
+	}
+	return b.reg.finish(b.buf[0])
+}
+
+// Builder constructs a finite state transducer incrementally from pairs sorted by input.
+// The suffix states are frozen as soon as they are no longer reachable from the last input,
+// so the pairs never have to be kept in memory.
+type Builder struct {
+	b        *builder
+	mast     *mastRegister
+	finished bool
+}
+
+// NewBuilder returns a new builder of a finite state transducer, the outputs of an input are sorted.
+func NewBuilder() *Builder {
+	return NewBuilderWithOrder(Sorted)
+}
+
+// NewBuilderWithOrder returns a new builder of a finite state transducer which returns the outputs
+// of an input in the given order.
+func NewBuilderWithOrder(order Order) *Builder {
+	r := newMastRegister()
+	return &Builder{b: &builder{reg: r, order: order}, mast: r}
+}
+
+// Add appends a pair of input and output. Inputs must be added in lexicographic (byte) order,
+// outputs of the same input may be added in any order.
+func (b *Builder) Add(in, out string) error {
+	if b.finished {
+		return ErrBuilderFinished
+	}
+	if in == "" {
+		return ErrEmptyInput
+	}
+	if in < b.b.prev {
+		return &OrderError{Prev: b.b.prev, In: in}
+	}
+	return b.b.add(in, out)
+}
+
+// Finish freezes the remaining states and returns the compiled finite state transducer.
+func (b *Builder) Finish() (FstVM, error) {
+	if b.finished {
+		return FstVM{}, ErrBuilderFinished
+	}
+	b.finished = true
+	if err := b.b.flush(); err != nil {
+		return FstVM{}, err
+	}
+	return b.mast.m.compile()
+}
@@ -0,0 +1,96 @@
+package ss
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestBuilderAdd01(t *testing.T) {
+	inp := PairSlice{
+		{"apr", "30"},
+		{"aug", "31"},
+		{"dec", "31"},
+		{"feb", "29"},
+		{"feb", "28"},
+		{"jan", "31"},
+		{"jul", "31"},
+		{"jun", "30"},
+	}
+	for _, order := range []Order{Sorted, InsertionOrder} {
+		b := NewBuilderWithOrder(order)
+		for _, p := range inp {
+			if err := b.Add(p.In, p.Out); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		vm, err := b.Finish()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		org, err := BuildWithOrder(append(PairSlice(nil), inp...), order)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(vm, org) {
+			t.Errorf("order:%v, got %v, expected %v", order, vm, org)
+		}
+		exp := []string{"28", "29"}
+		if order == InsertionOrder {
+			exp = []string{"29", "28"}
+		}
+		if outs := vm.Search("feb"); !reflect.DeepEqual(outs, exp) {
+			t.Errorf("order:%v, got %v, expected %v", order, outs, exp)
+		}
+	}
+}
+
+func TestBuilderAdd02(t *testing.T) {
+	b := NewBuilder()
+	if err := b.Add("feb", "28"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := b.Add("dec", "31")
+	oe, ok := err.(*OrderError)
+	if !ok {
+		t.Fatalf("got %v, expected *OrderError", err)
+	}
+	if oe.Prev != "feb" || oe.In != "dec" {
+		t.Errorf("got %+v, expected {Prev:feb In:dec}", oe)
+	}
+	if err := b.Add("", "0"); err != ErrEmptyInput {
+		t.Errorf("got %v, expected %v", err, ErrEmptyInput)
+	}
+	if _, err := b.Finish(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Add("mar", "31"); err != ErrBuilderFinished {
+		t.Errorf("got %v, expected %v", err, ErrBuilderFinished)
+	}
+	if _, err := b.Finish(); err != ErrBuilderFinished {
+		t.Errorf("got %v, expected %v", err, ErrBuilderFinished)
+	}
+}
+
+func TestBuilderAdd03(t *testing.T) {
+	inp := randomPairs(rand.New(rand.NewSource(1)), 3000, 5)
+	sort.Stable(inp)
+	b := NewBuilder()
+	for _, p := range inp {
+		if err := b.Add(p.In, p.Out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	vm, err := b.Finish()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	org, err := Build(inp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(vm, org) {
+		t.Errorf("got %v, expected %v", vm, org)
+	}
+}
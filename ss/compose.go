@@ -0,0 +1,69 @@
+package ss
+
+// step follows the path of s from the state at pc reached with the output out, and returns the
+// destination and the output on the way. ok is false if the path breaks.
+func (vm FstVM) step(pc int, out, s string) (next int, o string, ok bool) {
+	for i := 0; i < len(s); i++ {
+		ok = false
+		for _, a := range vm.node(pc).arcs {
+			if a.ch == s[i] {
+				pc, out, ok = a.next, out+a.out, true
+				break
+			}
+		}
+		if !ok {
+			return 0, "", false
+		}
+	}
+	return pc, out, true
+}
+
+// Compose constructs a minimal finite state transducer equivalent to applying a and then b, i.e.
+// it maps a key of a to the outputs of b for the outputs of the key. The keys whose outputs b does
+// not accept are dropped. The states of a are walked together with the states of b reached by the
+// outputs on the way, so the subtrees of a whose outputs b rejects are never entered, and the pairs
+// found are added to a builder in lexicographic order as the walk goes. The walk visits every path
+// of a which b accepts, so the time grows with the number of keys of a, while the memory is bounded
+// by the builder, i.e. the longest key and the states of the result.
+//
+// The composition of acyclic transducers is acyclic, so the result is always representable as a
+// mast. An error is returned only if the result cannot be compiled, e.g. an output exceeds the
+// operand limit of the program.
+func Compose(a, b FstVM) (FstVM, error) {
+	var (
+		bd  = NewBuilder()
+		key []byte
+		err error
+	)
+	var visit func(pa, pb int, ob string)
+	visit = func(pa, pb int, ob string) {
+		na := a.node(pa)
+		if na.final {
+			for _, t := range na.outputs("") {
+				if next, o, ok := b.step(pb, ob, t); ok {
+					if nb := b.node(next); nb.final {
+						for _, out := range nb.outputs(o) {
+							if err == nil {
+								err = bd.Add(string(key), out)
+							}
+						}
+					}
+				}
+			}
+		}
+		for _, x := range na.arcs {
+			next, o, ok := b.step(pb, ob, x.out)
+			if !ok {
+				continue
+			}
+			key = append(key, x.ch)
+			visit(x.next, next, o)
+			key = key[:len(key)-1]
+		}
+	}
+	visit(0, 0, "")
+	if err != nil {
+		return FstVM{}, err
+	}
+	return bd.Finish()
+}
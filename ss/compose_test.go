@@ -0,0 +1,107 @@
+package ss
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCompose01(t *testing.T) {
+	a, e := Build(PairSlice{
+		{"a", "x"},
+		{"ab", "xy"},
+		{"b", "y"},
+		{"c", "z"},
+		{"d", "x"},
+		{"d", "y"},
+	})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	b, e := Build(PairSlice{
+		{"x", "1"},
+		{"xy", "12"},
+		{"y", "2"},
+		{"y", "3"},
+	})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	vm, e := Compose(a, b)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	keys, outs := vm.PredictiveSearch("", 0)
+	for _, v := range outs {
+		sort.Strings(v)
+	}
+	expKeys := []string{"a", "ab", "b", "d"}
+	expOuts := [][]string{{"1"}, {"12"}, {"2", "3"}, {"1", "2", "3"}}
+	if !reflect.DeepEqual(keys, expKeys) || !reflect.DeepEqual(outs, expOuts) {
+		t.Errorf("got %v %v, expected %v %v\n", keys, outs, expKeys, expOuts)
+	}
+}
+
+func TestCompose02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	inpA := randomPairs(r, 1000, 4)
+	for i := range inpA {
+		inpA[i].Out = strings.Map(func(r rune) rune { return 'a' + (r-'0')%4 }, inpA[i].Out[:len(inpA[i].Out)%4])
+	}
+	inpB := randomPairs(r, 300, 3)
+	for i := range inpB {
+		inpB[i].In = strings.Map(func(r rune) rune { return 'a' + (r-'a')%4 }, inpB[i].In)
+	}
+	a, e := Build(append(PairSlice(nil), inpA...))
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	b, e := Build(append(PairSlice(nil), inpB...))
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	var ps PairSlice
+	for it := a.All(); ; {
+		key, outs, ok := it.Next()
+		if !ok {
+			break
+		}
+		for _, o := range outs {
+			for _, out := range b.Search(o) {
+				ps = append(ps, Pair{In: key, Out: out})
+			}
+		}
+	}
+	if len(ps) == 0 {
+		t.Fatalf("expected some pairs\n")
+	}
+	vm, e := Compose(a, b)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	exp, e := Build(ps)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	if !reflect.DeepEqual(vm.prog, exp.prog) || !reflect.DeepEqual(vm.data, exp.data) {
+		t.Errorf("got\n%v\nexpected\n%v\n", vm, exp)
+	}
+}
+
+func TestCompose03(t *testing.T) {
+	a, e := Build(PairSlice{{"a", "x"}})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	b, e := Build(PairSlice{{"x", strings.Repeat("long", 100)}})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	defer func(x int) { maxOperand = x }(maxOperand)
+	maxOperand = 100
+	if _, e := Compose(a, b); e == nil {
+		t.Errorf("expected an error\n")
+	}
+}
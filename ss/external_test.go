@@ -0,0 +1,86 @@
+package ss
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildExternal01(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 1000} {
+		inp := randomPairs(r, n, 6)
+		var src bytes.Buffer
+		for _, p := range inp {
+			fmt.Fprintf(&src, "%s\t%s\n", p.In, p.Out)
+		}
+		dir := t.TempDir()
+		var got bytes.Buffer
+		stats, err := BuildExternal(&got, &src, ExternalConfig{TempDir: dir, ChunkSize: 100})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stats.Written != int64(got.Len()) || stats.Pairs != n || !stats.Minimal() {
+			t.Errorf("pairs:%v, got %+v, written %v", n, stats, got.Len())
+		}
+		vm, err := Build(inp)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var exp bytes.Buffer
+		if err := vm.Save(&exp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got.Bytes(), exp.Bytes()) {
+			t.Errorf("pairs:%v, got %v, expected %v", n, got.Bytes(), exp.Bytes())
+		}
+		if fs, _ := os.ReadDir(dir); len(fs) != 0 {
+			t.Errorf("temporary files are left: %v", fs)
+		}
+	}
+}
+
+func TestBuildExternal02(t *testing.T) {
+	const size = 30000
+	var src bytes.Buffer
+	for i := size - 1; i >= 0; i-- {
+		fmt.Fprintf(&src, "%x\t%d\n", i*7919, i)
+	}
+	for _, rs := range []int{0, 128} {
+		var b bytes.Buffer
+		stats, err := BuildExternal(&b, bytes.NewReader(src.Bytes()), ExternalConfig{
+			TempDir:      t.TempDir(),
+			ChunkSize:    1000,
+			RegisterSize: rs,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if minimal := rs == 0; stats.Minimal() != minimal {
+			t.Errorf("register size:%v, got %+v, expected minimal=%v", rs, stats, minimal)
+		}
+		var vm FstVM
+		if err := vm.Load(&b); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for i := 0; i < size; i++ {
+			in := fmt.Sprintf("%x", i*7919)
+			if outs, exp := vm.Search(in), []string{fmt.Sprint(i)}; !reflect.DeepEqual(outs, exp) {
+				t.Fatalf("register size:%v, input:%v, got %v, expected %v", rs, in, outs, exp)
+			}
+		}
+	}
+}
+
+func TestBuildExternal03(t *testing.T) {
+	for _, src := range []string{"feb 28\n", "feb\tXX\n" + strings.Repeat("x", 1<<21)} {
+		var b bytes.Buffer
+		if _, err := BuildExternal(&b, strings.NewReader(src), ExternalConfig{TempDir: t.TempDir()}); err == nil {
+			t.Errorf("input:%q, expected error", src)
+		}
+	}
+}
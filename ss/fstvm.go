@@ -1,9 +1,14 @@
 package ss
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
+
+	"github.com/ikawaha/mast/container"
 )
 
 type instOp byte
@@ -15,11 +20,15 @@ const (
 	valMask   byte = 0xFF >> instBits
 	instMask  byte = 0xFF - valMask
 
+	// operandFlag marks the instructions followed by operands, the other bits of valMask are zero.
+	operandFlag byte = 0x01
+
 	instAccept      instOp = 0x01 << instShift
 	instMatch              = 0x02 << instShift
 	instBreak              = 0x03 << instShift
 	instOutput             = 0x04 << instShift
 	instOutputBreak        = 0x05 << instShift
+	instAcceptBreak        = 0x06 << instShift
 )
 
 var instOpName = [2 << instBits]string{
@@ -29,7 +38,7 @@ var instOpName = [2 << instBits]string{
 	"BRK",
 	"OUT",
 	"OTB",
-	"OP6",
+	"ACB",
 	"OP7",
 }
 
@@ -39,6 +48,7 @@ func (op instOp) String() string {
 }
 
 // FstVM represents a virtual machine of finite state transducers.
+// The outputs are stored with their lengths, so they may contain any bytes including NUL.
 type FstVM struct {
 	prog []byte
 	data string
@@ -50,69 +60,142 @@ type configuration struct {
 	tape int
 }
 
-func toInt(b []byte) int {
-	var x int
-	for i, size := 0, len(b); i < size; i++ {
-		x <<= 8
-		x += int(b[i])
+// maxOperand is the limit of the operands of instructions, i.e. jumps and addresses of the data.
+// It keeps the programs portable to the platforms with 32-bit ints.
+var maxOperand = math.MaxInt32
+
+// appendOperand appends the operand x as an unsigned varint to the program built in reverse order.
+func appendOperand(prog []byte, x int) ([]byte, error) {
+	if x < 0 || x > maxOperand {
+		return prog, fmt.Errorf("operand %d exceeds the limit %d", x, maxOperand)
+	}
+	var buf [binary.MaxVarintLen64]byte
+	for i := binary.PutUvarint(buf[:], uint64(x)) - 1; i >= 0; i-- {
+		prog = append(prog, buf[i])
+	}
+	return prog, nil
+}
+
+// operand decodes the operand at pc and returns it and its size, the size is 0 if the operand is broken.
+func (vm FstVM) operand(pc int) (x, n int) {
+	if pc > len(vm.prog) {
+		return 0, 0
+	}
+	v, n := binary.Uvarint(vm.prog[pc:])
+	if n <= 0 || v > uint64(maxOperand) {
+		return 0, 0
+	}
+	return int(v), n
+}
+
+// writeOutput writes the output prefixed by its length as an unsigned varint to the data, so the
+// outputs may contain any bytes.
+func writeOutput(data *bytes.Buffer, out string) error {
+	if len(out) > maxOperand {
+		return fmt.Errorf("output length %d exceeds the limit %d", len(out), maxOperand)
+	}
+	var buf [binary.MaxVarintLen64]byte
+	data.Write(buf[:binary.PutUvarint(buf[:], uint64(len(out)))])
+	data.WriteString(out)
+	return nil
+}
+
+// readOutput decodes the output at data[p] and returns it and the address of the next output.
+func (vm FstVM) readOutput(p int) (out string, next int, ok bool) {
+	if p < 0 || p >= len(vm.data) {
+		return
+	}
+	end := p + binary.MaxVarintLen64
+	if end > len(vm.data) {
+		end = len(vm.data)
+	}
+	l, n := binary.Uvarint([]byte(vm.data[p:end]))
+	if n <= 0 || l > uint64(len(vm.data)-p-n) {
+		return
+	}
+	next = p + n + int(l)
+	return vm.data[p+n : next], next, true
+}
+
+// accept decodes the accept instruction at pc and returns the range of the tails in the data and
+// the address of the next instruction, the range is empty if the state has no tails.
+func (vm FstVM) accept(pc int) (s, e, next int, ok bool) {
+	next = pc + 1
+	if vm.prog[pc]&operandFlag == 0 {
+		return 0, 0, next, true
+	}
+	s, n := vm.operand(next)
+	if n == 0 {
+		return
+	}
+	next += n
+	l, n := vm.operand(next)
+	if n == 0 {
+		return
 	}
-	return x
+	return s, s + l, next + n, true
+}
+
+// edge decodes the edge instruction at pc and returns the character, the jump, the address of the
+// output in the data and the address of the end of the edge, the destination of the edge is
+// end + jump. The address of the output is 0 unless the instruction outputs.
+func (vm FstVM) edge(pc int) (ch byte, jump, out, end int, ok bool) {
+	end = pc + 2
+	if end > len(vm.prog) {
+		return
+	}
+	ch = vm.prog[pc+1]
+	var n int
+	if vm.prog[pc]&operandFlag != 0 {
+		if jump, n = vm.operand(end); n == 0 {
+			return
+		}
+		end += n
+	}
+	if op := instOp(vm.prog[pc] & instMask); op == instOutput || op == instOutputBreak {
+		if out, n = vm.operand(end); n == 0 {
+			return
+		}
+		end += n
+	}
+	return ch, jump, out, end, true
 }
 
 // String returns a string representation of a program.
 func (vm FstVM) String() string {
 	ret := ""
-	for pc, end := 0, len(vm.prog); pc < end; {
+	for pc := 0; pc < len(vm.prog); {
 		p := pc
 		op := instOp(vm.prog[pc] & instMask)
-		sz := int(vm.prog[pc] & valMask)
-		pc++
-		if op == instAccept {
-			if sz == 0 {
+		if op == instAccept || op == instAcceptBreak {
+			s, e, next, ok := vm.accept(pc)
+			if !ok {
+				return ret + fmt.Sprintf("%3d  %v ?\n", p, op)
+			}
+			if next == pc+1 {
 				ret += fmt.Sprintf("%3d  %v\n", p, op)
 			} else {
-				s := toInt(vm.prog[pc : pc+sz])
-				pc += sz
-				sz = int(vm.prog[pc])
-				pc++
-				e := toInt(vm.prog[pc : pc+sz])
-				var tails []string
-				for i := s; i < e; i++ {
-					h := i
-					for vm.data[i] != 0 {
-						i++
-					}
-					tails = append(tails, vm.data[h:i])
-				}
-				ret += fmt.Sprintf("%3d  %v %v\n", p, op, tails)
-				for j := p + 1; j <= pc; j++ {
+				ret += fmt.Sprintf("%3d  %v %v\n", p, op, vm.tails(s, e))
+				for j := p + 1; j < next; j++ {
 					ret += fmt.Sprintf("%3d [TIL addr=%d:%d]\n", j, s, e)
 				}
-				pc += sz
 			}
+			pc = next
 			continue
 		}
-		inp := vm.prog[pc]
-		pc++
-		buf := vm.prog[pc : pc+sz]
-		v := toInt(buf)
-		ret += fmt.Sprintf("%3d  %v %X %d (sz:%d)\n", p, op, inp, v, sz)
-		for j := p + 1; j < pc+sz; j++ {
-			ret += fmt.Sprintf("%3d [%v %X %d]\n", j, op, inp, v)
+		ch, jump, out, end, ok := vm.edge(pc)
+		if !ok {
+			return ret + fmt.Sprintf("%3d  %v ?\n", p, op)
 		}
-		pc += sz
+		ret += fmt.Sprintf("%3d  %v %X %d", p, op, ch, jump)
 		if op == instOutput || op == instOutputBreak {
-			p := pc
-			sz := int(vm.prog[pc])
-			pc++
-			buf := vm.prog[pc : pc+sz]
-			v := toInt(buf)
-			pc += sz
-			for j := p; j < pc; j++ {
-				ret += fmt.Sprintf("%3d [OUT addr=%d]\n", j, v)
-			}
-
+			ret += fmt.Sprintf(" %q", vm.output(out))
+		}
+		ret += fmt.Sprintf(" (sz:%d)\n", end-p-2)
+		for j := p + 1; j < end; j++ {
+			ret += fmt.Sprintf("%3d [%v %X %d]\n", j, op, ch, jump)
 		}
+		pc = end
 	}
 	return ret
 }
@@ -132,29 +215,7 @@ func (vm *FstVM) Search(input string) []string {
 	if !acc || len(snap) == 0 {
 		return nil
 	}
-	c := snap[len(snap)-1]
-	pc := c.pc
-	sz := int(vm.prog[pc] & valMask)
-	pc++
-	if sz == 0 {
-		return []string{string(tape[0:c.tape])}
-	}
-	s := toInt(vm.prog[pc : pc+sz])
-	pc += sz
-	sz = int(vm.prog[pc])
-	pc++
-	e := toInt(vm.prog[pc : pc+sz])
-	var outs []string
-	for i := s; i < e; i++ {
-		h := i
-		for vm.data[i] != 0 {
-			i++
-		}
-		t := append(tape[0:c.tape], vm.data[h:i]...)
-		outs = append(outs, string(t))
-	}
-	pc += sz
-	return outs
+	return vm.outputs(tape, snap[len(snap)-1])
 }
 
 // PrefixSearch returns the longest commom prefix keyword and it's length in given input if detected otherwise -1, nil.
@@ -164,28 +225,7 @@ func (vm *FstVM) PrefixSearch(input string) (int, []string) {
 		return -1, nil
 	}
 	c := snap[len(snap)-1]
-	pc := c.pc
-	sz := int(vm.prog[pc] & valMask)
-	pc++
-	if sz == 0 {
-		return c.inp, []string{string(tape[0:c.tape])}
-	}
-	s := toInt(vm.prog[pc : pc+sz])
-	pc += sz
-	sz = int(vm.prog[pc])
-	pc++
-	e := toInt(vm.prog[pc : pc+sz])
-	var outs []string
-	for i := s; i < e; i++ {
-		h := i
-		for vm.data[i] != 0 {
-			i++
-		}
-		t := append(tape[0:c.tape], vm.data[h:i]...)
-		outs = append(outs, string(t))
-	}
-	pc += sz
-	return c.inp, outs
+	return c.inp, vm.outputs(tape, c)
 }
 
 // CommonPrefixSearch finds keywords sharing common prefix in given input
@@ -197,120 +237,85 @@ func (vm *FstVM) CommonPrefixSearch(input string) (lens []int, outputs [][]strin
 	}
 	for _, c := range snap {
 		lens = append(lens, c.inp)
-		pc := c.pc
-		sz := int(vm.prog[pc] & valMask)
-		pc++
-		if sz == 0 {
-			outputs = append(outputs, []string{string(tape[0:c.tape])})
-			continue
-		}
-		s := toInt(vm.prog[pc : pc+sz])
-		pc += sz
-		sz = int(vm.prog[pc])
-		pc++
-		e := toInt(vm.prog[pc : pc+sz])
-		var outs []string
-		for i := s; i < e; i++ {
-			h := i
-			for vm.data[i] != 0 {
-				i++
-			}
-			t := make([]byte, 0, c.tape+(i-h))
-			t = append(t, tape[0:c.tape]...)
-			t = append(t, vm.data[h:i]...)
-			outs = append(outs, string(t))
-		}
-		outputs = append(outputs, outs)
+		outputs = append(outputs, vm.outputs(tape, c))
+	}
+	return
+}
+
+// SearchBytes is like Search but takes the input and returns the outputs as byte slices.
+func (vm *FstVM) SearchBytes(input []byte) [][]byte {
+	return byteSlices(vm.Search(string(input)))
+}
+
+// PrefixSearchBytes is like PrefixSearch but takes the input and returns the outputs as byte slices.
+func (vm *FstVM) PrefixSearchBytes(input []byte) (int, [][]byte) {
+	n, outs := vm.PrefixSearch(string(input))
+	return n, byteSlices(outs)
+}
+
+// CommonPrefixSearchBytes is like CommonPrefixSearch but takes the input and returns the outputs as byte slices.
+func (vm *FstVM) CommonPrefixSearchBytes(input []byte) (lens []int, outputs [][][]byte) {
+	lens, outs := vm.CommonPrefixSearch(string(input))
+	for _, o := range outs {
+		outputs = append(outputs, byteSlices(o))
 	}
 	return
 }
 
+func byteSlices(s []string) [][]byte {
+	if s == nil {
+		return nil
+	}
+	b := make([][]byte, len(s))
+	for i := range s {
+		b[i] = []byte(s[i])
+	}
+	return b
+}
+
+// outputs returns the outputs of the accepted configuration c.
+func (vm *FstVM) outputs(tape []byte, c configuration) []string {
+	if vm.prog[c.pc]&operandFlag == 0 {
+		return []string{string(tape[0:c.tape])}
+	}
+	s, e, _, _ := vm.accept(c.pc)
+	var outs []string
+	for _, t := range vm.tails(s, e) {
+		outs = append(outs, string(tape[0:c.tape])+t)
+	}
+	return outs
+}
+
 func (vm *FstVM) run(input string) (tape []byte, snap []configuration, accept bool) {
 	var (
 		pc int    // program counter
 		op instOp // operation
-		sz int    // size
-		ch byte   // char
 		hd int    // input head
-		va int    // value
 	)
 	for pc < len(vm.prog) && hd < len(input) {
 		op = instOp(vm.prog[pc] & instMask)
-		sz = int(vm.prog[pc] & valMask)
-		//fmt.Printf("pc:%v,op:%v,hd:%v,sz:%v\n", pc, op, hd, sz) //XXX
 		switch op {
-		case instMatch:
-			fallthrough
-		case instBreak:
-			pc++
-			ch = vm.prog[pc]
-			pc++
-			if ch != input[hd] {
-				if op == instBreak {
-					return
-				}
-				if sz > 0 {
-					pc += sz
-				}
-				continue
-			}
-			if sz > 0 {
-				va = toInt(vm.prog[pc : pc+sz])
-				pc += sz + va
-			} else {
-				va = 0
-			}
-			hd++
-			continue
-		case instOutput:
-			fallthrough
-		case instOutputBreak:
-			pc++
-			ch = vm.prog[pc]
-			pc++
-			//fmt.Println("ch:", ch, "input[hd]", input[hd]) //XXX
+		case instMatch, instBreak, instOutput, instOutputBreak:
+			ch, jump, out, end, _ := vm.edge(pc)
 			if ch != input[hd] {
-				if op == instOutputBreak {
+				if op == instBreak || op == instOutputBreak {
 					return
 				}
-				if sz > 0 {
-					pc += sz
-				}
-				s := int(vm.prog[pc])
-				pc += s + 1
+				pc = end
 				continue
 			}
-			if sz > 0 {
-				va = toInt(vm.prog[pc : pc+sz])
-				pc += sz
-			} else {
-				va = 0
+			if op == instOutput || op == instOutputBreak {
+				tape = append(tape, vm.output(out)...)
 			}
+			pc = end + jump
 			hd++
-			s := int(vm.prog[pc])
-			v := toInt(vm.prog[pc+1 : pc+1+s])
-			//fmt.Println("pc:", pc, "s:", s, "v:", v) //XXX
-			for p := v; ; {
-				if p >= len(vm.data) || vm.data[p] == 0 {
-					//fmt.Printf("out>>%s(%v, %v)\n", vm.data[v:p], v, p) //XXX
-					tape = append(tape, vm.data[v:p]...)
-					break
-				}
-				p++
-			}
-			//fmt.Println("pc:", pc, "s:", s, "va:", va)
-			pc += s + va + 1
-		case instAccept:
+		case instAccept, instAcceptBreak:
 			snap = append(snap, configuration{pc, hd, len(tape)})
-			pc++
-			if sz > 0 {
-				pc += sz
-				sz = int(vm.prog[pc])
-				pc += sz + 1
+			if op == instAcceptBreak {
+				return
 			}
-			continue
+			_, _, pc, _ = vm.accept(pc)
 		default:
-			//fmt.Printf("unknown op:%v\n", op)
 			return
 		}
 	}
@@ -318,10 +323,8 @@ func (vm *FstVM) run(input string) (tape []byte, snap []configuration, accept bo
 	if pc >= len(vm.prog) || hd != len(input) {
 		return
 	}
-	if op = instOp(vm.prog[pc] & instMask); op != instAccept {
-		//fmt.Printf("[[FINAL]]pc:%d, op:%s, ch:[%X], sz:%d, v:%d\n", pc, op, ch, sz, va) //XXX
+	if op = instOp(vm.prog[pc] & instMask); op != instAccept && op != instAcceptBreak {
 		return
-
 	}
 	accept = true
 	snap = append(snap, configuration{pc, hd, len(tape)})
@@ -330,37 +333,43 @@ func (vm *FstVM) run(input string) (tape []byte, snap []configuration, accept bo
 
 // Save FstVM
 func (vm FstVM) Save(w io.Writer) (err error) {
-	if err = binary.Write(w, binary.LittleEndian, int64(len(vm.prog))); err != nil {
-		return
-	}
-	if _, err = w.Write(vm.prog); err != nil { //TODO compress
+	cw, err := container.NewWriter(w, container.Header{
+		Kind:     container.KindSS,
+		Sections: []int64{int64(len(vm.prog)), int64(len(vm.data))},
+	})
+	if err != nil {
 		return
 	}
-	if err = binary.Write(w, binary.LittleEndian, int64(len(vm.data))); err != nil {
+	if _, err = cw.Write(vm.prog); err != nil { //TODO compress
 		return
 	}
-	if _, err = w.Write([]byte(vm.data)); err != nil { //XXX
+	if _, err = io.WriteString(cw, vm.data); err != nil {
 		return
 	}
-	return
+	return cw.Close()
 }
 
 // Load FstVM
 func (vm *FstVM) Load(r io.Reader) (err error) {
-	var n int64
-	if err = binary.Read(r, binary.LittleEndian, &n); err != nil {
+	cr, err := container.NewReader(bufio.NewReader(r), container.KindSS)
+	if err != nil {
+		return
+	}
+	if len(cr.Sections) != 2 || cr.Sections[0] < 0 || cr.Sections[1] < 0 {
+		return fmt.Errorf("invalid format: sections %v", cr.Sections)
+	}
+	prog, err := cr.ReadSection(cr.Sections[0], 1)
+	if err != nil {
 		return
 	}
-	prog := make([]byte, n, n)
-	if _, err = r.Read(prog); err != nil {
+	data, err := cr.ReadSection(cr.Sections[1], 1)
+	if err != nil {
 		return
 	}
-	if err = binary.Read(r, binary.LittleEndian, &n); err != nil {
+	if err = cr.Close(); err != nil {
 		return
 	}
-	data := make([]byte, n, n)
-
-	if _, err = r.Read(data); err != nil {
+	if err = (FstVM{prog: prog, data: string(data)}).Verify(); err != nil {
 		return
 	}
 	vm.prog = prog
@@ -231,22 +231,120 @@ func TestFstVMCommonPrefixSearch01(t *testing.T) {
 	}
 }
 
-func TestToInt01(t *testing.T) {
+func TestFstVMSearch07(t *testing.T) {
+	inp := PairSlice{
+		{"a", "\x00\x00\x01"},
+		{"ab", "\x00\x00\x02"},
+		{"ab", "\x00"},
+		{"abc", ""},
+		{"b", "\x00\xff\x00"},
+		{"c", string(bytes.Repeat([]byte{0}, 300))},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	var b bytes.Buffer
+	if e := vm.Save(&b); e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	var v2 FstVM
+	if e := v2.Load(&b); e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	exp := map[string][]string{}
+	for _, p := range inp {
+		exp[p.In] = append(exp[p.In], p.Out)
+	}
+	for in, outs := range exp {
+		sort.Strings(outs)
+		for _, vm := range []FstVM{vm, v2} {
+			r := vm.Search(in)
+			sort.Strings(r)
+			if !reflect.DeepEqual(r, outs) {
+				t.Errorf("input: %v, got %q, expected %q\n", in, r, outs)
+			}
+		}
+	}
+}
+
+func TestFstVMSearch08(t *testing.T) {
+	inp := PairSlice{
+		{"a", "xb"},
+		{"b", "y"},
+		{"a", "xa"},
+		{"b", "yy"},
+		{"a", "xc"},
+		{"b", ""},
+		{"a", "xa"},
+		{"c", "y"},
+		{"c", "x"},
+		{"ab", "xy"},
+		{"ab", "xx"},
+	}
+	crs := []struct {
+		order Order
+		outs  map[string][]string
+	}{
+		{Sorted, map[string][]string{"a": {"xa", "xb", "xc"}, "b": {"", "y", "yy"}, "c": {"x", "y"}, "ab": {"xx", "xy"}}},
+		{InsertionOrder, map[string][]string{"a": {"xb", "xa", "xc"}, "b": {"y", "yy", ""}, "c": {"y", "x"}, "ab": {"xy", "xx"}}},
+	}
+	for _, cr := range crs {
+		for i := 0; i < 10; i++ {
+			vm, e := BuildWithOrder(append(PairSlice(nil), inp...), cr.order)
+			if e != nil {
+				t.Fatalf("unexpected error: %v\n", e)
+			}
+			for in, exp := range cr.outs {
+				if outs := vm.Search(in); !reflect.DeepEqual(outs, exp) {
+					t.Errorf("order %v, input: %v, got %q, expected %q\n", cr.order, in, outs, exp)
+				}
+			}
+		}
+	}
+}
+
+func TestFstVMSearchBytes01(t *testing.T) {
+	vm, e := Build(PairSlice{
+		{"\x00", "\x00\x01"},
+		{"\x00\x00", "\x00\x02"},
+	})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	if r := vm.SearchBytes([]byte{0}); !reflect.DeepEqual(r, [][]byte{{0, 1}}) {
+		t.Errorf("got %v, expected %v\n", r, [][]byte{{0, 1}})
+	}
+	if r := vm.SearchBytes([]byte{1}); r != nil {
+		t.Errorf("got %v, expected nil\n", r)
+	}
+	if n, r := vm.PrefixSearchBytes([]byte{0, 0, 0}); n != 2 || !reflect.DeepEqual(r, [][]byte{{0, 2}}) {
+		t.Errorf("got %v %v, expected 2 %v\n", n, r, [][]byte{{0, 2}})
+	}
+	lens, outs := vm.CommonPrefixSearchBytes([]byte{0, 0})
+	if exp := [][][]byte{{{0, 1}}, {{0, 2}}}; !reflect.DeepEqual(lens, []int{1, 2}) || !reflect.DeepEqual(outs, exp) {
+		t.Errorf("got %v %v, expected [1 2] %v\n", lens, outs, exp)
+	}
+}
+
+func TestOperand01(t *testing.T) {
 	cr := []struct {
 		in  []byte
 		out int
+		n   int
 	}{
-		{[]byte{7}, 7},
-		{[]byte{255}, 255},
-		{[]byte{0, 1}, 1},
-		{[]byte{1, 0}, 256},
-		{[]byte{2, 0}, 512},
-		{[]byte{4, 0}, 1024},
-		{[]byte{255, 255, 255}, 0xFFFFFF},
+		{[]byte{7}, 7, 1},
+		{[]byte{0x7F, 0xFF}, 0x7F, 1},
+		{[]byte{0xAC, 0x02}, 300, 2},
+		{[]byte{0xFF, 0xFF, 0xFF, 0x07}, 0xFFFFFF, 4},
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x07}, 0x7FFFFFFF, 5},
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x0F}, 0, 0},
+		{[]byte{0x80}, 0, 0},
+		{[]byte{}, 0, 0},
 	}
 	for _, s := range cr {
-		if r := toInt(s.in); !reflect.DeepEqual(s.out, r) {
-			t.Errorf("got %v, expected %v\n", r, s.out)
+		if r, n := (FstVM{prog: s.in}).operand(0); r != s.out || n != s.n {
+			t.Errorf("%v: got %v, %v, expected %v, %v\n", s.in, r, n, s.out, s.n)
 		}
 	}
 }
@@ -0,0 +1,202 @@
+package ss
+
+import "sort"
+
+// arc represents a transition of a compiled state.
+type arc struct {
+	ch   byte
+	out  string
+	next int // address of the destination state
+}
+
+// node represents a compiled state.
+type node struct {
+	final bool
+	tails []string // outputs of the final state, nil if the state has no tails
+	arcs  []arc
+}
+
+// outputs returns the outputs of a final state reached with the output out.
+func (n node) outputs(out string) []string {
+	if n.tails == nil {
+		return []string{out}
+	}
+	outs := make([]string, 0, len(n.tails))
+	for _, t := range n.tails {
+		outs = append(outs, out+t)
+	}
+	return outs
+}
+
+// tails returns the outputs stored in data[s:e].
+func (vm FstVM) tails(s, e int) (t []string) {
+	for p := s; p < e; {
+		out, next, ok := vm.readOutput(p)
+		if !ok {
+			break
+		}
+		t = append(t, out)
+		p = next
+	}
+	return
+}
+
+// output returns the output stored at data[p].
+func (vm FstVM) output(p int) string {
+	out, _, _ := vm.readOutput(p)
+	return out
+}
+
+// node decodes the compiled state at pc.
+func (vm FstVM) node(pc int) (n node) {
+	if pc >= len(vm.prog) {
+		return
+	}
+	op := instOp(vm.prog[pc] & instMask)
+	if op == instAccept || op == instAcceptBreak {
+		n.final = true
+		s, e, next, _ := vm.accept(pc)
+		if next > pc+1 {
+			n.tails = vm.tails(s, e)
+		}
+		if op == instAcceptBreak {
+			return
+		}
+		pc = next
+	}
+	for pc < len(vm.prog) {
+		op = instOp(vm.prog[pc] & instMask)
+		if op != instMatch && op != instBreak && op != instOutput && op != instOutputBreak {
+			return
+		}
+		ch, jump, out, end, _ := vm.edge(pc)
+		a := arc{ch: ch, next: end + jump}
+		if op == instOutput || op == instOutputBreak {
+			a.out = vm.output(out)
+		}
+		n.arcs = append(n.arcs, a)
+		if op == instBreak || op == instOutputBreak {
+			return
+		}
+		pc = end
+	}
+	return
+}
+
+type frame struct {
+	node    node
+	out     string // output on the path to the state
+	arc     int    // index of the next arc to visit
+	visited bool
+}
+
+// Iterator enumerates the keys and outputs of a finite state transducer in lexicographic order.
+type Iterator struct {
+	vm    FstVM
+	key   []byte
+	stack []frame
+	to    string // upper bound of the keys, exclusive
+	limit bool   // to is given
+}
+
+// All returns an iterator over all the keys and outputs of the finite state transducer.
+func (vm FstVM) All() *Iterator {
+	return &Iterator{
+		vm:    vm,
+		stack: []frame{{node: vm.node(0)}},
+	}
+}
+
+// Next returns the next key and its outputs. ok is false if there are no more keys.
+func (it *Iterator) Next() (key string, outs []string, ok bool) {
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+		if !top.visited {
+			top.visited = true
+			if top.node.final {
+				if it.limit && string(it.key) >= it.to {
+					it.key, it.stack = nil, nil
+					break
+				}
+				return string(it.key), top.node.outputs(top.out), true
+			}
+		}
+		if top.arc >= len(top.node.arcs) {
+			it.stack = it.stack[:len(it.stack)-1]
+			if len(it.stack) > 0 {
+				it.key = it.key[:len(it.key)-1]
+			}
+			continue
+		}
+		a := top.node.arcs[top.arc]
+		top.arc++
+		it.key = append(it.key, a.ch)
+		it.stack = append(it.stack, frame{node: it.vm.node(a.next), out: top.out + a.out})
+	}
+	return "", nil, false
+}
+
+// prefix returns an iterator over the keys which start with the given prefix.
+func (vm FstVM) prefix(prefix string) *Iterator {
+	var out string
+	n := vm.node(0)
+	for i := 0; i < len(prefix); i++ {
+		var ok bool
+		for _, a := range n.arcs {
+			if a.ch != prefix[i] {
+				continue
+			}
+			out += a.out
+			n, ok = vm.node(a.next), true
+			break
+		}
+		if !ok {
+			return &Iterator{vm: vm}
+		}
+	}
+	return &Iterator{
+		vm:    vm,
+		key:   []byte(prefix),
+		stack: []frame{{node: n, out: out}},
+	}
+}
+
+// PredictiveSearch finds keywords which start with a given prefix and returns them and their outputs
+// in lexicographic order. If limit > 0, at most limit keywords are returned.
+func (vm FstVM) PredictiveSearch(prefix string, limit int) (keys []string, outputs [][]string) {
+	it := vm.prefix(prefix)
+	for limit <= 0 || len(keys) < limit {
+		key, outs, ok := it.Next()
+		if !ok {
+			break
+		}
+		keys = append(keys, key)
+		outputs = append(outputs, outs)
+	}
+	return
+}
+
+// Range returns an iterator over the keys in [from, to) and their outputs in lexicographic order.
+// If to is empty, the keys are not bounded above.
+func (vm FstVM) Range(from, to string) *Iterator {
+	it := &Iterator{
+		vm:    vm,
+		stack: []frame{{node: vm.node(0)}},
+		to:    to,
+		limit: to != "",
+	}
+	for i := 0; i < len(from); i++ {
+		top := &it.stack[len(it.stack)-1]
+		top.visited = true // the key is a proper prefix of from
+		arcs := top.node.arcs
+		j := sort.Search(len(arcs), func(j int) bool { return arcs[j].ch >= from[i] })
+		top.arc = j
+		if j == len(arcs) || arcs[j].ch != from[i] {
+			break
+		}
+		top.arc++
+		it.key = append(it.key, arcs[j].ch)
+		it.stack = append(it.stack, frame{node: vm.node(arcs[j].next), out: top.out + arcs[j].out})
+	}
+	return it
+}
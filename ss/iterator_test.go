@@ -0,0 +1,202 @@
+package ss
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIteratorNext01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", "28"},
+		{"feb", "29"},
+		{"apr", "30"},
+		{"jan", "31"},
+		{"jun", "30"},
+		{"jul", "31"},
+		{"dec", "31"},
+		{"july", "7"},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	type entry struct {
+		key  string
+		outs []string
+	}
+	var got []entry
+	it := vm.All()
+	for {
+		key, outs, ok := it.Next()
+		if !ok {
+			break
+		}
+		sort.Strings(outs)
+		got = append(got, entry{key, outs})
+	}
+	exp := []entry{
+		{"apr", []string{"30"}},
+		{"dec", []string{"31"}},
+		{"feb", []string{"28", "29"}},
+		{"jan", []string{"31"}},
+		{"jul", []string{"31"}},
+		{"july", []string{"7"}},
+		{"jun", []string{"30"}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %v, expected %v\n", got, exp)
+	}
+}
+
+func TestIteratorNext02(t *testing.T) {
+	vm, e := Build(PairSlice{})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	if key, outs, ok := vm.All().Next(); ok {
+		t.Errorf("unexpected key: %v, %v\n", key, outs)
+	}
+}
+
+func TestFstVMSearchFinalLeaf01(t *testing.T) {
+	inp := PairSlice{
+		{"ab", "1"},
+		{"cd", "2"},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	for _, in := range []string{"abd", "cdb", "abab"} {
+		if outs := vm.Search(in); outs != nil {
+			t.Errorf("input: %v, got %v, expected nil\n", in, outs)
+		}
+	}
+}
+
+func TestFstVMPredictiveSearch01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", "28"},
+		{"feb", "29"},
+		{"apr", "30"},
+		{"jan", "31"},
+		{"jun", "30"},
+		{"jul", "31"},
+		{"ju", "3"},
+		{"july", "7"},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	testdata := []struct {
+		prefix string
+		limit  int
+		keys   []string
+		outs   [][]string
+	}{
+		{prefix: "ju", keys: []string{"ju", "jul", "july", "jun"}, outs: [][]string{{"3"}, {"31"}, {"7"}, {"30"}}},
+		{prefix: "ju", limit: 2, keys: []string{"ju", "jul"}, outs: [][]string{{"3"}, {"31"}}},
+		{prefix: "f", keys: []string{"feb"}, outs: [][]string{{"28", "29"}}},
+		{prefix: "july", keys: []string{"july"}, outs: [][]string{{"7"}}},
+		{prefix: "julyx"},
+		{prefix: "x"},
+		{prefix: "", limit: 1, keys: []string{"apr"}, outs: [][]string{{"30"}}},
+	}
+	for _, d := range testdata {
+		keys, outs := vm.PredictiveSearch(d.prefix, d.limit)
+		for _, v := range outs {
+			sort.Strings(v)
+		}
+		if !reflect.DeepEqual(keys, d.keys) || !reflect.DeepEqual(outs, d.outs) {
+			t.Errorf("prefix %q: got %v %v, expected %v %v\n", d.prefix, keys, outs, d.keys, d.outs)
+		}
+	}
+}
+
+func TestFstVMRange01(t *testing.T) {
+	inp := PairSlice{
+		{"feb", "28"},
+		{"feb", "29"},
+		{"apr", "30"},
+		{"jan", "31"},
+		{"jun", "30"},
+		{"jul", "31"},
+		{"ju", "3"},
+		{"july", "7"},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	testdata := []struct {
+		from, to string
+		keys     []string
+	}{
+		{from: "", to: "", keys: []string{"apr", "feb", "jan", "ju", "jul", "july", "jun"}},
+		{from: "b", to: "jul", keys: []string{"feb", "jan", "ju"}},
+		{from: "ju", to: "jun", keys: []string{"ju", "jul", "july"}},
+		{from: "jua", to: "", keys: []string{"jul", "july", "jun"}},
+		{from: "julz", to: "k", keys: []string{"jun"}},
+		{from: "feb", to: "feb"},
+		{from: "k", to: "z"},
+		{from: "jun", to: "a"},
+	}
+	for _, d := range testdata {
+		var keys []string
+		it := vm.Range(d.from, d.to)
+		for {
+			key, outs, ok := it.Next()
+			if !ok {
+				break
+			}
+			if exp := vm.Search(key); !reflect.DeepEqual(outs, exp) {
+				t.Errorf("[%q, %q) %q: got %v, expected %v\n", d.from, d.to, key, outs, exp)
+			}
+			keys = append(keys, key)
+		}
+		if !reflect.DeepEqual(keys, d.keys) {
+			t.Errorf("[%q, %q): got %v, expected %v\n", d.from, d.to, keys, d.keys)
+		}
+		if _, _, ok := it.Next(); ok {
+			t.Errorf("[%q, %q): expected the end of the iteration\n", d.from, d.to)
+		}
+	}
+}
+
+func TestFstVMRange02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	vm, e := Build(randomPairs(r, 3000, 4))
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	var all []string
+	for it := vm.All(); ; {
+		key, _, ok := it.Next()
+		if !ok {
+			break
+		}
+		all = append(all, key)
+	}
+	for i := 0; i < 300; i++ {
+		from, to := randomPairs(r, 1, 4)[0].In, randomPairs(r, 1, 4)[0].In
+		var exp, got []string
+		for _, k := range all {
+			if k >= from && k < to {
+				exp = append(exp, k)
+			}
+		}
+		for it := vm.Range(from, to); ; {
+			key, _, ok := it.Next()
+			if !ok {
+				break
+			}
+			got = append(got, key)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("[%q, %q): got %v, expected %v\n", from, to, got, exp)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"sort"
-	"strings"
 )
 
 // mast represents Minimal Acyclic Subsequential Transeducers.
@@ -23,9 +22,25 @@ func (m *mast) addState(n *state) {
 	}
 }
 
-// Build returns a virtual machine of a finite state transducer.
+// Order represents the order of the outputs of an input.
+type Order int
+
+const (
+	// Sorted orders the outputs of an input in ascending order.
+	Sorted Order = iota
+	// InsertionOrder orders the outputs of an input in the order of the given pairs.
+	InsertionOrder
+)
+
+// Build returns a virtual machine of a finite state transducer, the outputs of an input are sorted.
 func Build(input PairSlice) (vm FstVM, err error) {
-	m := buildMast(input)
+	return BuildWithOrder(input, Sorted)
+}
+
+// BuildWithOrder returns a virtual machine of a finite state transducer which returns the outputs
+// of an input in the given order.
+func BuildWithOrder(input PairSlice, order Order) (vm FstVM, err error) {
+	m := buildMast(input, order)
 	return m.compile()
 }
 
@@ -53,101 +68,15 @@ func commonPrefixLen(a, b string) int {
 	return i
 }
 
-func buildMast(input PairSlice) (m *mast) {
-	sort.Sort(input)
-
-	const initialMastSize = 1024
-	m = new(mast)
-	dic := make(map[uint][]*state)
-	m.states = make([]*state, 0, initialMastSize)
-	m.finalStates = make([]*state, 0, initialMastSize)
-
-	buf := make([]*state, input.maxInputWordLen()+1)
-	for i := range buf {
-		buf[i] = newState()
-	}
-	prev := ""
+func buildMast(input PairSlice, order Order) (m *mast) {
+	sort.Stable(input)
+	r := newMastRegister()
+	b := builder{reg: r, order: order}
 	for _, pair := range input {
-		in, out := pair.In, pair.Out
-		prefixLen := commonPrefixLen(in, prev)
-		for i := len(prev); i > prefixLen; i-- {
-			var s *state
-			if cs, ok := dic[buf[i].hcode]; ok {
-				for _, c := range cs {
-					if c.eq(buf[i]) {
-						s = c
-						break
-					}
-				}
-			}
-			if s == nil {
-				s = &state{}
-				*s = *buf[i]
-				m.addState(s)
-				dic[s.hcode] = append(dic[s.hcode], s)
-			}
-			buf[i].renew()
-			buf[i-1].setTransition(prev[i-1], s)
-			s.setInvTransition()
-		}
-		for i, size := prefixLen+1, len(in); i <= size; i++ {
-			buf[i-1].setTransition(in[i-1], buf[i])
-		}
-		if in != prev {
-			buf[len(in)].IsFinal = true
-		}
-		for j := 1; j < prefixLen+1; j++ {
-			outPref := commonPrefix(buf[j-1].Output[in[j-1]], out)
-			outSuff := strings.TrimPrefix(buf[j-1].Output[in[j-1]], outPref)
-			buf[j-1].setOutput(in[j-1], outPref)
-			for ch := range buf[j].Trans {
-				buf[j].setOutput(ch, outSuff+buf[j].Output[ch])
-			}
-			if buf[j].IsFinal {
-				set := make(map[string]bool)
-				if !buf[j].hasTail() {
-					set[outSuff] = true
-				} else {
-					for _, s := range buf[j].tails() {
-						s = outSuff + s
-						set[s] = true
-					}
-				}
-				buf[j].setTail(set)
-			}
-			out = strings.TrimPrefix(out, outPref)
-		}
-		if in == prev {
-			buf[len(in)].addTail(out)
-		} else {
-			buf[prefixLen].setOutput(in[prefixLen], out)
-		}
-		prev = in
-	}
-	// flush the buf
-	for i := len(prev); i > 0; i-- {
-		var s *state
-		if cs, ok := dic[buf[i].hcode]; ok {
-			for _, c := range cs {
-				if c.eq(buf[i]) {
-					s = c
-					break
-				}
-			}
-		}
-		if s == nil {
-			s = &state{}
-			*s = *buf[i]
-			buf[i].renew()
-			m.addState(s)
-		}
-		buf[i-1].setTransition(prev[i-1], s)
-		s.setInvTransition()
+		b.add(pair.In, pair.Out)
 	}
-	m.initialState = buf[0]
-	m.addState(buf[0])
-
-	return
+	b.flush()
+	return r.m
 }
 
 func (m *mast) run(input string) (out []string, ok bool) {
@@ -207,92 +136,109 @@ func (p byteSlice) Len() int           { return len(p) }
 func (p byteSlice) Less(i, j int) bool { return p[i] < p[j] }
 func (p byteSlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
-func toBytes(x int) []byte {
-	var (
-		b   byte
-		buf []byte
-	)
-	for x >= 256 {
-		b = byte(x % 256)
-		x >>= 8
-		buf = append(buf, b)
-	}
-	buf = append(buf, byte(x))
-	return buf
-}
-
 func (m *mast) compile() (vm FstVM, err error) {
-	var tape bytes.Buffer
-	var edges []byte
-	addrMap := make(map[int]int)
+	c := new(compiler)
 	for _, s := range m.states {
-		edges = edges[:0]
-		for ch := range s.Trans {
-			edges = append(edges, ch)
+		if err = c.compile(s); err != nil {
+			return
 		}
-		if len(edges) > 0 {
-			sort.Sort(byteSlice(edges))
+	}
+	vm.prog = invert(c.prog)
+	vm.data = c.data.String()
+	return
+}
+
+// compiler compiles states into a program built in reverse order.
+type compiler struct {
+	prog     []byte
+	data     bytes.Buffer
+	progBase int // number of bytes already spilled out of prog
+	dataBase int // number of bytes already spilled out of data
+	edges    []byte
+}
+
+func (c *compiler) progLen() int {
+	return c.progBase + len(c.prog)
+}
+
+func (c *compiler) dataLen() int {
+	return c.dataBase + c.data.Len()
+}
+
+// compile appends the codes of a state and sets the address of them to the state. The codes of
+// the states reachable from the state must be compiled in advance.
+func (c *compiler) compile(s *state) (err error) {
+	edges := c.edges[:0]
+	for ch := range s.Trans {
+		edges = append(edges, ch)
+	}
+	if len(edges) > 0 {
+		sort.Sort(byteSlice(edges))
+	}
+	c.edges = edges
+	for i, size := 0, len(edges); i < size; i++ {
+		inp := edges[size-1-i]
+		next := s.Trans[inp]
+		out := s.Output[inp]
+		if next.addr == 0 && !next.IsFinal {
+			return fmt.Errorf("next addr is undefined: state(%v), input(%X)", s.ID, inp)
 		}
-		for i, size := 0, len(edges); i < size; i++ {
-			inp := edges[size-1-i]
-			next := s.Trans[inp]
-			out := s.Output[inp]
-			addr, ok := addrMap[next.ID]
-			if !ok && !next.IsFinal {
-				err = fmt.Errorf("next addr is undefined: state(%v), input(%X)", s.ID, inp)
-				return
-			}
-			var op instOp
-			if len(out) > 0 {
-				if i == 0 {
-					op = instOutputBreak
-				} else {
-					op = instOutput
-				}
-			} else if i == 0 {
-				op = instBreak
+		var op instOp
+		if len(out) > 0 {
+			if i == 0 {
+				op = instOutputBreak
 			} else {
-				op = instMatch
+				op = instOutput
 			}
-			inst := byte(op)
-			jump := len(vm.prog) - addr
-			if len(out) != 0 {
-				adr := tape.Len()
-				dst := toBytes(adr)
-				sz := byte(len(dst))
-				vm.prog = append(vm.prog, dst...)
-				vm.prog = append(vm.prog, sz)
-
-				tape.WriteString(out)
-				tape.WriteByte(byte(0x00))
+		} else if i == 0 {
+			op = instBreak
+		} else {
+			op = instMatch
+		}
+		inst := byte(op)
+		jump := c.progLen() - next.addr
+		if len(out) != 0 {
+			if c.prog, err = appendOperand(c.prog, c.dataLen()); err == nil {
+				err = writeOutput(&c.data, out)
+			}
+			if err != nil {
+				return fmt.Errorf("state(%v), input(%X): %v", s.ID, inp, err)
 			}
-			if jump > 1 {
-				dst := toBytes(jump)
-				inst |= byte(len(dst))
-				vm.prog = append(vm.prog, dst...)
+		}
+		if jump > 0 {
+			if c.prog, err = appendOperand(c.prog, jump); err != nil {
+				return fmt.Errorf("state(%v), input(%X): %v", s.ID, inp, err)
 			}
-			vm.prog = append(vm.prog, inp)
-			vm.prog = append(vm.prog, inst)
+			inst |= operandFlag
 		}
-		if s.IsFinal {
-			inst := byte(instAccept)
-			if len(s.Tail) > 0 {
-				dst1 := toBytes(tape.Len())
-				inst |= byte(len(dst1))
-				for t := range s.Tail {
-					tape.WriteString(t)
-					tape.WriteByte(byte(0x00))
+		c.prog = append(c.prog, inp)
+		c.prog = append(c.prog, inst)
+	}
+	if s.IsFinal {
+		inst := byte(instAccept)
+		if len(s.Trans) == 0 {
+			inst = byte(instAcceptBreak)
+		}
+		if len(s.Tail) > 0 {
+			start := c.dataLen()
+			for _, t := range s.order {
+				if err = writeOutput(&c.data, t); err != nil {
+					break
 				}
-				dst2 := toBytes(tape.Len())
-				vm.prog = append(vm.prog, dst2...)
-				vm.prog = append(vm.prog, byte(len(dst2)))
-				vm.prog = append(vm.prog, dst1...)
 			}
-			vm.prog = append(vm.prog, inst)
+			if err == nil {
+				c.prog, err = appendOperand(c.prog, c.dataLen()-start)
+			}
+			if err == nil {
+				c.prog, err = appendOperand(c.prog, start)
+			}
+			if err != nil {
+				return fmt.Errorf("state(%v): %v", s.ID, err)
+			}
+			inst |= operandFlag
 		}
-		addrMap[s.ID] = len(vm.prog)
+		c.prog = append(c.prog, inst)
 	}
-	vm.prog = invert(vm.prog)
-	vm.data = tape.String()
+	s.addr = c.progLen()
 	return
 }
@@ -1,6 +1,7 @@
 package ss
 
 import (
+	"math/rand"
 	"os"
 	"reflect"
 	"sort"
@@ -9,7 +10,7 @@ import (
 
 func TestBuildMast01(t *testing.T) {
 	inp := PairSlice{}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	if m.initialState.ID != 0 {
 		t.Errorf("got initial state id %v, expected 0\n", m.initialState.ID)
 	}
@@ -30,7 +31,7 @@ func TestAccept01(t *testing.T) {
 		{"112", "abb"},
 		{"211", "cde"},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	for _, pair := range inp {
 		if ok := m.accept(pair.In); !ok {
 			t.Errorf("expected: accept [%v]\n", pair.In)
@@ -50,7 +51,7 @@ func TestRun01(t *testing.T) {
 		{"113", "abb"},
 		{"211", "aaa"},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	for _, pair := range inp {
 		out, ok := m.run(pair.In)
 		if !ok {
@@ -73,7 +74,7 @@ func TestMastRun02(t *testing.T) {
 		{"hello", "world"},
 		{"hello", "goodby"},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	for _, pair := range inp {
 		out, ok := m.run(pair.In)
 		if !ok {
@@ -102,25 +103,30 @@ func TestMastDot01(t *testing.T) {
 		{"feb", "28"},
 		{"feb", "29"},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	m.dot(os.Stdout)
 }
 
-func TestToBytes01(t *testing.T) {
+func TestAppendOperand01(t *testing.T) {
 	cr := []struct {
 		in  int
 		out []byte
 	}{
 		{7, []byte{7}},
-		{255, []byte{255}},
-		{256, []byte{0, 1}},
-		{512, []byte{0, 2}},
-		{1024, []byte{0, 4}},
-		{0xFFFFFF, []byte{255, 255, 255}},
+		{127, []byte{127}},
+		{128, []byte{0x01, 0x80}},
+		{300, []byte{0x02, 0xAC}},
+		{0xFFFFFF, []byte{0x07, 0xFF, 0xFF, 0xFF}},
+		{0x7FFFFFFF, []byte{0x07, 0xFF, 0xFF, 0xFF, 0xFF}},
 	}
 	for _, s := range cr {
-		if r := toBytes(s.in); !reflect.DeepEqual(s.out, r) {
-			t.Errorf("got %v, expected %v\n", r, s.out)
+		if r, e := appendOperand(nil, s.in); e != nil || !reflect.DeepEqual(s.out, r) {
+			t.Errorf("got %v, %v, expected %v\n", r, e, s.out)
+		}
+	}
+	for _, x := range []int{-1, maxOperand + 1} {
+		if _, e := appendOperand(nil, x); e == nil {
+			t.Errorf("%d: expected an error\n", x)
 		}
 	}
 }
@@ -130,7 +136,7 @@ func TestCompile01(t *testing.T) {
 		{"1a22xss", "world"},
 		{"1b22yss", "goodby"},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	_, e := m.compile()
 	if e != nil {
 		t.Errorf("unexpected error: %v", e)
@@ -142,7 +148,7 @@ func TestMastCompile02(t *testing.T) {
 		{"abc", "123"},
 		{"abc", "456"},
 	}
-	m := buildMast(inp)
+	m := buildMast(inp, Sorted)
 	vm, e := m.compile()
 	if e != nil {
 		t.Errorf("unexpected error: %v\n", e)
@@ -154,3 +160,38 @@ func TestMastCompile02(t *testing.T) {
 		t.Errorf("got %v, expected %v\n", outs, exp)
 	}
 }
+
+func TestMastCompile03(t *testing.T) {
+	inp := randomPairs(rand.New(rand.NewSource(1)), 60000, 16)
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	if len(vm.prog) < 1<<18 || len(vm.data) < 1<<18 {
+		t.Fatalf("expected a program with 3-byte operands, got %d bytes and %d bytes of data\n", len(vm.prog), len(vm.data))
+	}
+	exp := map[string][]string{}
+	for _, p := range inp {
+		exp[p.In] = append(exp[p.In], p.Out)
+	}
+	for k, v := range exp {
+		outs := vm.Search(k)
+		sort.Strings(outs)
+		sort.Strings(v)
+		if !reflect.DeepEqual(outs, v) {
+			t.Fatalf("%v: got %v, expected %v\n", k, outs, v)
+		}
+	}
+}
+
+func TestMastCompile04(t *testing.T) {
+	defer func(x int) { maxOperand = x }(maxOperand)
+	maxOperand = 1000
+	inp := randomPairs(rand.New(rand.NewSource(1)), 300, 16)
+	if _, e := Build(inp[:10]); e != nil {
+		t.Errorf("unexpected error: %v\n", e)
+	}
+	if _, e := Build(inp); e == nil {
+		t.Errorf("expected an error\n")
+	}
+}
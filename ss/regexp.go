@@ -0,0 +1,40 @@
+package ss
+
+import "github.com/ikawaha/mast/internal/pattern"
+
+// RegexpSearch finds keywords which match the regular expression as a whole and returns them
+// and their outputs in lexicographic order. The syntax of the pattern is the one accepted by
+// the regexp package, keywords are matched as UTF-8 rune sequences. The pattern is compiled to a
+// DFA which is built lazily while the transducer is walked, and the walk leaves the edges on which
+// the DFA fails, so the keys which cannot match are not enumerated.
+func (vm FstVM) RegexpSearch(expr string) (keys []string, outputs [][]string, err error) {
+	d, err := pattern.Compile(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	var key []byte
+	var walk func(n node, out string, c pattern.Cursor)
+	walk = func(n node, out string, c pattern.Cursor) {
+		if n.final && d.Match(c) {
+			keys = append(keys, string(key))
+			outputs = append(outputs, n.outputs(out))
+		}
+		for _, a := range n.arcs {
+			o := out + a.out
+			if next, ok := d.Step(c, a.ch); ok {
+				key = append(key, a.ch)
+				walk(vm.node(a.next), o, next)
+				key = key[:len(key)-1]
+			}
+		}
+	}
+	walk(vm.node(0), "", d.Start())
+	return keys, outputs, nil
+}
+
+// GlobSearch finds keywords which match the glob pattern and returns them and their outputs
+// in lexicographic order. '*' matches any sequence of characters, '?' matches any single
+// character and '[...]' matches a character class.
+func (vm FstVM) GlobSearch(glob string) (keys []string, outputs [][]string, err error) {
+	return vm.RegexpSearch(pattern.GlobToRegexp(glob))
+}
@@ -0,0 +1,76 @@
+package ss
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFstVMRegexpSearch01(t *testing.T) {
+	inp := PairSlice{
+		{"apple", "red"},
+		{"apply", "use"},
+		{"ample", "enough"},
+		{"app", "software"},
+		{"東京都", "とうきょうと"},
+		{"京都府", "きょうとふ"},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	testdata := []struct {
+		pattern string
+		keys    []string
+		outs    [][]string
+	}{
+		{pattern: "app.*", keys: []string{"app", "apple", "apply"}, outs: [][]string{{"software"}, {"red"}, {"use"}}},
+		{pattern: "a[mp]ple", keys: []string{"ample", "apple"}, outs: [][]string{{"enough"}, {"red"}}},
+		{pattern: ".都.", keys: []string{"京都府"}, outs: [][]string{{"きょうとふ"}}},
+		{pattern: "ple"},
+	}
+	for _, d := range testdata {
+		keys, outs, err := vm.RegexpSearch(d.pattern)
+		if err != nil {
+			t.Errorf("pattern %q: unexpected error: %v\n", d.pattern, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, d.keys) || !reflect.DeepEqual(outs, d.outs) {
+			t.Errorf("pattern %q: got %v %v, expected %v %v\n", d.pattern, keys, outs, d.keys, d.outs)
+		}
+	}
+	if _, _, err := vm.RegexpSearch("a("); err == nil {
+		t.Errorf("expected an error for an invalid pattern\n")
+	}
+}
+
+func TestFstVMGlobSearch01(t *testing.T) {
+	inp := PairSlice{
+		{"apple", "red"},
+		{"apply", "use"},
+		{"ample", "enough"},
+		{"app", "software"},
+		{"東京都", "とうきょうと"},
+	}
+	vm, e := Build(inp)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	testdata := []struct {
+		pattern string
+		keys    []string
+	}{
+		{pattern: "app*", keys: []string{"app", "apple", "apply"}},
+		{pattern: "appl[!e]", keys: []string{"apply"}},
+		{pattern: "東?都", keys: []string{"東京都"}},
+	}
+	for _, d := range testdata {
+		keys, _, err := vm.GlobSearch(d.pattern)
+		if err != nil {
+			t.Errorf("pattern %q: unexpected error: %v\n", d.pattern, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, d.keys) {
+			t.Errorf("pattern %q: got %v, expected %v\n", d.pattern, keys, d.keys)
+		}
+	}
+}
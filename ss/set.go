@@ -0,0 +1,101 @@
+package ss
+
+// MergePolicy returns the outputs of a key which both of the given transducers have, given the
+// outputs of the left and the right one. The key is dropped if no outputs are returned.
+type MergePolicy func(key string, left, right []string) []string
+
+// KeepBoth is a merge policy which keeps the outputs of both transducers.
+func KeepBoth(key string, left, right []string) []string {
+	return append(append([]string(nil), left...), right...)
+}
+
+// PreferLeft is a merge policy which keeps the outputs of the left transducer.
+func PreferLeft(key string, left, right []string) []string {
+	return left
+}
+
+// PreferRight is a merge policy which keeps the outputs of the right transducer.
+func PreferRight(key string, left, right []string) []string {
+	return right
+}
+
+// product enumerates the keys of a in lexicographic order together with the outputs of the keys in
+// b by walking the product of the programs, outsB is nil if b does not have the key. The keys which
+// b does not have are skipped unless all.
+func product(a, b FstVM, all bool, fn func(key string, outsA, outsB []string)) {
+	var key []byte
+	var visit func(pa int, oa string, pb int, ob string)
+	visit = func(pa int, oa string, pb int, ob string) {
+		na := a.node(pa)
+		var nb node
+		if pb >= 0 {
+			nb = b.node(pb)
+		}
+		if na.final {
+			var outsB []string
+			if nb.final {
+				outsB = nb.outputs(ob)
+			}
+			if outsB != nil || all {
+				fn(string(key), na.outputs(oa), outsB)
+			}
+		}
+		var j int
+		for _, x := range na.arcs {
+			next, out := -1, ob
+			for j < len(nb.arcs) && nb.arcs[j].ch < x.ch {
+				j++
+			}
+			if j < len(nb.arcs) && nb.arcs[j].ch == x.ch {
+				next, out = nb.arcs[j].next, ob+nb.arcs[j].out
+			}
+			if next < 0 && !all {
+				continue
+			}
+			key = append(key, x.ch)
+			visit(x.next, oa+x.out, next, out)
+			key = key[:len(key)-1]
+		}
+	}
+	visit(0, "", 0, "")
+}
+
+// Intersect constructs a minimal finite state transducer of the keys which both transducers have.
+// The outputs of a key are given by a policy, e.g. PreferLeft keeps the outputs of a. The keys are
+// enumerated in lexicographic order and added to a builder as they are found.
+func Intersect(a, b FstVM, policy MergePolicy) (FstVM, error) {
+	bd := NewBuilder()
+	var err error
+	product(a, b, false, func(key string, outsA, outsB []string) {
+		for _, out := range policy(key, outsA, outsB) {
+			if err == nil {
+				err = bd.Add(key, out)
+			}
+		}
+	})
+	if err != nil {
+		return FstVM{}, err
+	}
+	return bd.Finish()
+}
+
+// Difference constructs a minimal finite state transducer of the keys and outputs of a which b does
+// not have. The keys are enumerated in lexicographic order and added to a builder as they are found.
+func Difference(a, b FstVM) (FstVM, error) {
+	bd := NewBuilder()
+	var err error
+	product(a, b, true, func(key string, outsA, outsB []string) {
+		if outsB != nil {
+			return
+		}
+		for _, out := range outsA {
+			if err == nil {
+				err = bd.Add(key, out)
+			}
+		}
+	})
+	if err != nil {
+		return FstVM{}, err
+	}
+	return bd.Finish()
+}
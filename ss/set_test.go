@@ -0,0 +1,78 @@
+package ss
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestIntersect01(t *testing.T) {
+	a, e := Build(PairSlice{{"apr", "30"}, {"feb", "28"}, {"jan", "31"}, {"ju", "1"}, {"jun", "30"}})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	b, e := Build(PairSlice{{"dec", "31"}, {"feb", "29"}, {"jul", "31"}, {"jun", "6"}})
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	vm, e := Intersect(a, b, PreferRight)
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	keys, outs := vm.PredictiveSearch("", 0)
+	if exp := []string{"feb", "jun"}; !reflect.DeepEqual(keys, exp) || !reflect.DeepEqual(outs, [][]string{{"29"}, {"6"}}) {
+		t.Errorf("got %v %v, expected %v [[29] [6]]\n", keys, outs, exp)
+	}
+	if vm, e = Difference(a, b); e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	keys, outs = vm.PredictiveSearch("", 0)
+	if exp := []string{"apr", "jan", "ju"}; !reflect.DeepEqual(keys, exp) || !reflect.DeepEqual(outs, [][]string{{"30"}, {"31"}, {"1"}}) {
+		t.Errorf("got %v %v, expected %v [[30] [31] [1]]\n", keys, outs, exp)
+	}
+}
+
+func TestIntersect02(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	inpA, inpB := randomPairs(r, 5000, 4), randomPairs(r, 5000, 4)
+	a, e := Build(append(PairSlice(nil), inpA...))
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	b, e := Build(append(PairSlice(nil), inpB...))
+	if e != nil {
+		t.Fatalf("unexpected error: %v\n", e)
+	}
+	inB := map[string]bool{}
+	for _, p := range inpB {
+		inB[p.In] = true
+	}
+	var both, diff PairSlice
+	for _, p := range inpA {
+		if inB[p.In] {
+			both = append(both, p)
+		} else {
+			diff = append(diff, p)
+		}
+	}
+	for _, d := range []struct {
+		name string
+		op   func() (FstVM, error)
+		inp  PairSlice
+	}{
+		{name: "Intersect", op: func() (FstVM, error) { return Intersect(a, b, PreferLeft) }, inp: both},
+		{name: "Difference", op: func() (FstVM, error) { return Difference(a, b) }, inp: diff},
+	} {
+		vm, e := d.op()
+		if e != nil {
+			t.Fatalf("%s: unexpected error: %v\n", d.name, e)
+		}
+		exp, e := Build(d.inp)
+		if e != nil {
+			t.Fatalf("%s: unexpected error: %v\n", d.name, e)
+		}
+		if !reflect.DeepEqual(vm.prog, exp.prog) || !reflect.DeepEqual(vm.data, exp.data) {
+			t.Errorf("%s: got\n%v\nexpected\n%v\n", d.name, vm, exp)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package ss
 
 import (
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"sort"
 )
 
 type stringSet map[string]bool
@@ -12,9 +14,11 @@ type state struct {
 	Trans   map[byte]*state
 	Output  map[byte]string
 	Tail    stringSet
+	order   []string // tails in the order of addition
 	IsFinal bool
 	Prev    []*state
 	hcode   uint
+	addr    int // address of the compiled codes, 0 if not compiled yet
 }
 
 func newState() (n *state) {
@@ -30,19 +34,30 @@ func (n *state) hasTail() bool {
 }
 
 func (n *state) addTail(t string) {
+	if n.Tail[t] {
+		return
+	}
 	n.Tail[t] = true
+	n.order = append(n.order, t)
 }
 
-func (n *state) setTail(s stringSet) {
-	n.Tail = s
+// setTail replaces the tails with the given tails.
+func (n *state) setTail(t []string) {
+	n.Tail = make(stringSet, len(t))
+	n.order = nil
+	for _, item := range t {
+		n.addTail(item)
+	}
 }
 
+// tails returns the tails in the order of addition.
 func (n *state) tails() (t []string) {
-	t = make([]string, 0, len(n.Tail))
-	for item := range n.Tail {
-		t = append(t, item)
-	}
-	return
+	return append([]string(nil), n.order...)
+}
+
+// sortTails sorts the tails in ascending order.
+func (n *state) sortTails() {
+	sort.Strings(n.order)
 }
 
 func (n *state) setOutput(ch byte, out string) {
@@ -71,6 +86,7 @@ func (n *state) renew() {
 	n.Trans = make(map[byte]*state)
 	n.Output = make(map[byte]string)
 	n.Tail = make(stringSet)
+	n.order = nil
 	n.IsFinal = false
 	n.Prev = make([]*state, 0)
 	n.hcode = 0
@@ -89,6 +105,7 @@ func (n *state) eq(dst *state) bool {
 	if len(n.Trans) != len(dst.Trans) ||
 		len(n.Output) != len(dst.Output) ||
 		len(n.Tail) != len(dst.Tail) ||
+		len(n.order) != len(dst.order) ||
 		n.IsFinal != dst.IsFinal {
 		return false
 	}
@@ -107,9 +124,44 @@ func (n *state) eq(dst *state) bool {
 			return false
 		}
 	}
+	for i := range n.order {
+		if n.order[i] != dst.order[i] {
+			return false
+		}
+	}
 	return true
 }
 
+// signature returns a string which identifies the state by its finality, its transitions to the
+// frozen states with their outputs and its tails.
+func (n *state) signature() string {
+	edges := make([]byte, 0, len(n.Trans))
+	for ch := range n.Trans {
+		edges = append(edges, ch)
+	}
+	sort.Sort(byteSlice(edges))
+	var tmp [binary.MaxVarintLen64]byte
+	var b []byte
+	if n.IsFinal {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(edges)))]...)
+	for _, ch := range edges {
+		b = append(b, ch)
+		b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(n.Trans[ch].ID))]...)
+		out := n.Output[ch]
+		b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(out)))]...)
+		b = append(b, out...)
+	}
+	for _, t := range n.order {
+		b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(t)))]...)
+		b = append(b, t...)
+	}
+	return string(b)
+}
+
 // String returns a string representaion of a node for debug.
 func (n *state) String() string {
 	ret := ""
@@ -0,0 +1,151 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+import (
+	"fmt"
+	"sort"
+)
+
+// state represents a state of a transducer. A state is final iff it has tails,
+// the tail of an accepted input without a state output is the zero value.
+type state[O Output[O]] struct {
+	ID     int
+	Trans  map[byte]*state[O]
+	Output map[byte]O
+	Tail   map[string]O // tails keyed by their encodings
+	order  []O          // tails in the order of addition
+}
+
+func newState[O Output[O]]() (n *state[O]) {
+	n = new(state[O])
+	n.Trans = make(map[byte]*state[O])
+	n.Output = make(map[byte]O)
+	n.Tail = make(map[string]O)
+	return
+}
+
+func (n *state[O]) isFinal() bool {
+	return len(n.Tail) != 0
+}
+
+func (n *state[O]) addTail(t O) {
+	k := key(t)
+	if _, ok := n.Tail[k]; ok {
+		return
+	}
+	n.Tail[k] = t
+	n.order = append(n.order, t)
+}
+
+// tails returns the tails in the order of addition.
+func (n *state[O]) tails() []O {
+	return append([]O(nil), n.order...)
+}
+
+// sortTails sorts the tails in ascending order of the outputs of a path which reaches the state
+// with the output base. The tails are not compared by themselves since they are the remainders of
+// the outputs, which may wrap around, e.g. the sums of Int64. Every path reaching the state has an
+// output not greater than the outputs below it, so the order is the same for all of them.
+func (n *state[O]) sortTails(base O) {
+	sort.Slice(n.order, func(i, j int) bool { return base.Cat(n.order[i]).Less(base.Cat(n.order[j])) })
+}
+
+// setOutput sets the output of an edge, zero outputs are not kept.
+func (n *state[O]) setOutput(ch byte, out O) {
+	if isZero(out) {
+		delete(n.Output, ch)
+		return
+	}
+	n.Output[ch] = out
+}
+
+func (n *state[O]) setTransition(ch byte, next *state[O]) {
+	n.Trans[ch] = next
+}
+
+func (n *state[O]) renew() {
+	n.Trans = make(map[byte]*state[O])
+	n.Output = make(map[byte]O)
+	n.Tail = make(map[string]O)
+	n.order = nil
+}
+
+// hash returns a hash code of the state. Outputs are identified by ids of their encodings given
+// by a dictionary.
+func (n *state[O]) hash(ids func(string) uint64) (h uint64) {
+	const (
+		magicTrans  = 1001
+		magicOutput = 8191
+		magicTail   = 117709
+	)
+	for ch, next := range n.Trans {
+		h += (uint64(ch) + uint64(next.ID)) * magicTrans
+	}
+	for ch, out := range n.Output {
+		h += (uint64(ch) + ids(key(out))) * magicOutput
+	}
+	for k := range n.Tail {
+		h += ids(k) * magicTail
+	}
+	return
+}
+
+func (n *state[O]) eq(dst *state[O]) bool {
+	if n == nil || dst == nil {
+		return false
+	}
+	if n == dst {
+		return true
+	}
+	if len(n.Trans) != len(dst.Trans) ||
+		len(n.Output) != len(dst.Output) ||
+		len(n.Tail) != len(dst.Tail) ||
+		len(n.order) != len(dst.order) {
+		return false
+	}
+	for ch, next := range n.Trans {
+		if dst.Trans[ch] != next {
+			return false
+		}
+	}
+	for ch, out := range n.Output {
+		if o, ok := dst.Output[ch]; !ok || key(o) != key(out) {
+			return false
+		}
+	}
+	for k := range n.Tail {
+		if _, ok := dst.Tail[k]; !ok {
+			return false
+		}
+	}
+	for i := range n.order {
+		if key(n.order[i]) != key(dst.order[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a string representaion of a node for debug.
+func (n *state[O]) String() string {
+	ret := ""
+	if n == nil {
+		return "<nil>"
+	}
+	ret += fmt.Sprintf("%d[%p]:", n.ID, n)
+	for ch := range n.Trans {
+		ret += fmt.Sprintf("%02X/%v -->%p, ", ch, n.Output[ch], n.Trans[ch])
+	}
+	if n.isFinal() {
+		ret += fmt.Sprintf(" (tail:%v) ", n.tails())
+	}
+	return ret
+}
@@ -0,0 +1,48 @@
+//  Copyright (c) 2015 ikawaha.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mast
+
+import "fmt"
+
+// Verify checks that the transducer is well formed so that searches never fail on it: the ranges of
+// the edges and the tails of the nodes are contiguous, the edges of a node are sorted and every edge
+// goes forward to a node. Read verifies the loaded transducer.
+func (t FST[O]) Verify() error {
+	if len(t.nodes) == 0 {
+		if len(t.edges) != 0 || len(t.tails) != 0 {
+			return fmt.Errorf("invalid transducer: %d edges and %d tails without nodes", len(t.edges), len(t.tails))
+		}
+		return nil
+	}
+	if t.nodes[0] != (node{}) {
+		return fmt.Errorf("invalid transducer: node 0 starts at %+v", t.nodes[0])
+	}
+	last := t.nodes[len(t.nodes)-1]
+	if last.edge != len(t.edges) || last.tail != len(t.tails) {
+		return fmt.Errorf("invalid transducer: sentinel %+v, expected {edge:%d tail:%d}", last, len(t.edges), len(t.tails))
+	}
+	size := len(t.nodes) - 1
+	for n := 0; n < size; n++ {
+		from, to := t.nodes[n], t.nodes[n+1]
+		if from.edge > to.edge || from.tail > to.tail {
+			return fmt.Errorf("invalid transducer: node %d: ranges %+v to %+v", n, from, to)
+		}
+		for i := from.edge; i < to.edge; i++ {
+			e := t.edges[i]
+			if i > from.edge && t.edges[i-1].ch >= e.ch {
+				return fmt.Errorf("invalid transducer: node %d: edges are not sorted at %02X", n, e.ch)
+			}
+			if e.next <= n || e.next >= size {
+				return fmt.Errorf("invalid transducer: node %d: edge %02X to node %d", n, e.ch, e.next)
+			}
+		}
+	}
+	return nil
+}